@@ -96,6 +96,7 @@ class LoginActivity : AppCompatActivity() {
                 is LoginState.Success -> {
                     // Save login info
                     preferenceManager.saveToken(state.token)
+                    state.refreshToken?.let { preferenceManager.saveRefreshToken(it) }
                     preferenceManager.saveUsername(state.username)
                     state.user?.let { preferenceManager.saveUserInfo(it) }
 
@@ -70,9 +70,22 @@ class MyApplication : Application(), AuthHandler {
         }
     }
 
+    override fun getAccessToken(): String? {
+        return preferenceManager.getToken()
+    }
+
+    override fun getRefreshToken(): String? {
+        return preferenceManager.getRefreshToken()
+    }
+
+    override fun onTokensRefreshed(accessToken: String, refreshToken: String) {
+        preferenceManager.saveToken(accessToken)
+        preferenceManager.saveRefreshToken(refreshToken)
+    }
+
     /**
      * Handle 401 Unauthorized responses
-     * Called when API returns 401, indicating the token is invalid or expired
+     * Called when API returns 401 and the token could not be refreshed
      */
     override fun onUnauthorized() {
         Log.w(TAG, "401 Unauthorized: Clearing token and redirecting to login")
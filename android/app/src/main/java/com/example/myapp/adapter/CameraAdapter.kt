@@ -1,18 +1,13 @@
 package com.example.myapp.adapter
 
+import android.net.Uri
 import android.util.Log
 import android.view.LayoutInflater
 import android.view.ViewGroup
 import androidx.recyclerview.widget.RecyclerView
 import coil.request.ImageRequest
 import com.example.myapp.databinding.ItemCameraBinding
-import com.example.myapp.model.CameraSnapshotResponse
-import com.example.myapp.network.ApiClient
-import com.example.myapp.network.ApiService
 import com.example.myapp.utils.PreferenceManager
-import kotlinx.coroutines.CoroutineScope
-import kotlinx.coroutines.Dispatchers
-import kotlinx.coroutines.launch
 
 class CameraAdapter(
     private val cameras: List<String>,
@@ -36,39 +31,20 @@ class CameraAdapter(
         }
 
         private fun loadSnapshot(cameraName: String) {
-            val scope = CoroutineScope(Dispatchers.IO)
-            scope.launch {
-                try {
-                    val serverUrl = preferenceManager.getServerUrl()
-                    val token = preferenceManager.getToken()
+            val serverUrl = preferenceManager.getServerUrl()
+            val token = preferenceManager.getToken()
 
-                    if (serverUrl != null && token != null) {
-                        val apiService = ApiClient.createAuthorizedClient(serverUrl, token)
-                            .create(ApiService::class.java)
-
-                        val response = apiService.getCameraSnapshot(cameraName)
-
-                        if (response.isSuccessful && response.body()?.status == "success") {
-                            val snapshotUrl = response.body()!!.body.url
-                            val frigateToken = response.body()!!.body.frigateToken
-
-                            // 使用 frigate_token 加载图片
-                            loadImageWithToken(snapshotUrl, frigateToken)
-                        } else {
-                            Log.e("CameraAdapter", "Failed to load snapshot")
-                        }
-                    }
-                } catch (e: Exception) {
-                    Log.e("CameraAdapter", "Error loading snapshot", e)
-                }
+            if (serverUrl == null || token == null) {
+                Log.e("CameraAdapter", "Not logged in, cannot load snapshot")
+                return
             }
-        }
 
-        private fun loadImageWithToken(url: String, frigateToken: String) {
+            // 快照由服务器代理，使用本服务的 token 加载
+            val snapshotUrl = "${serverUrl.trimEnd('/')}/api/camera/${Uri.encode(cameraName)}/snapshot.jpg?h=360"
             val request = ImageRequest.Builder(binding.root.context)
-                .data(url)
+                .data(snapshotUrl)
                 .crossfade(true)
-                .setHeader("Authorization", "Bearer $frigateToken")
+                .setHeader("Authorization", "Bearer $token")
                 .target(binding.ivSnapshot)
                 .build()
 
@@ -42,10 +42,10 @@ data class CameraSnapshotResponse(
 data class CameraSnapshotBody(
     @SerializedName("camera_name")
     val cameraName: String,
+    @SerializedName("instance")
+    val instance: String,
     @SerializedName("url")
-    val url: String,
-    @SerializedName("frigate_token")
-    val frigateToken: String
+    val url: String // 服务器快照代理的路径，例如 /api/camera/front/snapshot.jpg?instance=home
 )
 
 /**
@@ -70,7 +70,5 @@ data class CameraStreamBody(
     @SerializedName("stream_type")
     val streamType: String,
     @SerializedName("url")
-    val url: String,
-    @SerializedName("frigate_token")
-    val frigateToken: String
+    val url: String // 服务器流代理的路径
 )
@@ -1,6 +1,15 @@
 package com.example.myapp.model
 
+import com.google.gson.annotations.SerializedName
+
 data class LoginRequest(
     val username: String,
-    val password: String
+    val password: String,
+    @SerializedName("device_name")
+    val deviceName: String? = null
+)
+
+data class RefreshRequest(
+    @SerializedName("refresh_token")
+    val refreshToken: String
 )
@@ -1,5 +1,10 @@
 package com.example.myapp.model
 
+import com.google.gson.annotations.SerializedName
+
+/**
+ * 登录和刷新 Token 共用的响应
+ */
 data class LoginResponse(
     val body: Body,
     val code: Int,
@@ -9,6 +14,8 @@ data class LoginResponse(
 ) {
     data class Body(
         val token: String,
+        @SerializedName("refresh_token")
+        val refreshToken: String? = null,
         val user: UserInfo? = null
     )
 }
@@ -2,6 +2,8 @@ package com.example.myapp.network
 
 import android.os.Handler
 import android.os.Looper
+import com.example.myapp.model.RefreshRequest
+import okhttp3.Authenticator
 import okhttp3.Interceptor
 import okhttp3.OkHttpClient
 import okhttp3.logging.HttpLoggingInterceptor
@@ -16,6 +18,9 @@ object ApiClient {
 
     var authHandler: AuthHandler? = null
 
+    // Refresh tokens are single-use, so concurrent 401s must not refresh twice
+    private val refreshLock = Any()
+
     private val loggingInterceptor = HttpLoggingInterceptor().apply {
         level = HttpLoggingInterceptor.Level.BODY
     }
@@ -39,6 +44,53 @@ object ApiClient {
         }
     }
 
+    /**
+     * 收到 401 时用 Refresh Token 换取新的 Access Token，并重试一次原请求
+     * 刷新失败时返回 null，401 交给 createUnauthorizedInterceptor 处理（退出登录）
+     */
+    private fun createTokenAuthenticator(baseUrl: String): Authenticator {
+        return Authenticator { _, response ->
+            // Only retry once; a second 401 means the session is gone
+            if (response.priorResponse != null) {
+                return@Authenticator null
+            }
+            val handler = authHandler ?: return@Authenticator null
+            val failedToken = response.request.header("Authorization")?.removePrefix("Bearer ")
+
+            val token = synchronized(refreshLock) {
+                // Another request may have refreshed the token while this one was in flight
+                val current = handler.getAccessToken()
+                if (current != null && current != failedToken) current else refreshTokens(baseUrl, handler)
+            } ?: return@Authenticator null
+
+            response.request.newBuilder()
+                .header("Authorization", "Bearer $token")
+                .build()
+        }
+    }
+
+    /**
+     * 调用 /api/auth/refresh，成功时保存新的 Token 对并返回新的 Access Token
+     */
+    private fun refreshTokens(baseUrl: String, handler: AuthHandler): String? {
+        val refreshToken = handler.getRefreshToken() ?: return null
+        return try {
+            val response = createClient(baseUrl).create(AuthService::class.java)
+                .refresh(RefreshRequest(refreshToken))
+                .execute()
+            val body = response.body()?.body
+            val newRefreshToken = body?.refreshToken
+            if (!response.isSuccessful || body == null || newRefreshToken == null) {
+                null
+            } else {
+                handler.onTokensRefreshed(body.token, newRefreshToken)
+                body.token
+            }
+        } catch (e: IOException) {
+            null
+        }
+    }
+
     private val okHttpClient = OkHttpClient.Builder()
         .addInterceptor(loggingInterceptor)
         .connectTimeout(30, TimeUnit.SECONDS)
@@ -56,7 +108,7 @@ object ApiClient {
 
     /**
      * 创建带 JWT Token 授权的 Retrofit 客户端
-     * 用于需要身份验证的 API 请求
+     * 用于需要身份验证的 API 请求，Access Token 过期时自动刷新
      */
     fun createAuthorizedClient(baseUrl: String, token: String): Retrofit {
         val authorizedClient = OkHttpClient.Builder()
@@ -69,6 +121,7 @@ object ApiClient {
                 chain.proceed(requestBuilder.build())
             }
             .addInterceptor(createUnauthorizedInterceptor()) // Add 401 handler
+            .authenticator(createTokenAuthenticator(baseUrl)) // Refresh before giving up on a 401
             .connectTimeout(30, TimeUnit.SECONDS)
             .readTimeout(30, TimeUnit.SECONDS)
             .writeTimeout(30, TimeUnit.SECONDS)
@@ -1,7 +1,7 @@
 package com.example.myapp.network
 
 /**
- * Interface for handling authentication failures (401 responses)
+ * Interface for handling authentication failures (401 responses) and token refresh
  * Implemented by Application class to globally handle unauthorized API responses
  */
 interface AuthHandler {
@@ -10,4 +10,20 @@ interface AuthHandler {
      * Implementations should clear the token and redirect to login
      */
     fun onUnauthorized()
+
+    /**
+     * Returns the stored access token, or null when logged out
+     */
+    fun getAccessToken(): String?
+
+    /**
+     * Returns the stored refresh token, or null when none was issued
+     */
+    fun getRefreshToken(): String?
+
+    /**
+     * Called after the access token was refreshed
+     * Implementations must persist both tokens: the old refresh token is used up
+     */
+    fun onTokensRefreshed(accessToken: String, refreshToken: String)
 }
@@ -5,6 +5,8 @@ import com.example.myapp.model.FcmTokenRequest
 import com.example.myapp.model.FcmTokenResponse
 import com.example.myapp.model.LoginRequest
 import com.example.myapp.model.LoginResponse
+import com.example.myapp.model.RefreshRequest
+import retrofit2.Call
 import retrofit2.Response
 import retrofit2.http.Body
 import retrofit2.http.GET
@@ -18,6 +20,12 @@ interface AuthService {
     @POST("api/auth/login")
     suspend fun login(@Body request: LoginRequest): Response<LoginResponse>
 
+    /**
+     * 用 Refresh Token 换取新的 Token 对（同步调用，供 OkHttp Authenticator 使用）
+     */
+    @POST("api/auth/refresh")
+    fun refresh(@Body request: RefreshRequest): Call<LoginResponse>
+
     @POST("api/fcm/tokens")
     suspend fun submitFcmToken(@Body request: FcmTokenRequest): Response<FcmTokenResponse>
 }
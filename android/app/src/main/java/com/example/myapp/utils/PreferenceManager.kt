@@ -14,6 +14,7 @@ class PreferenceManager(context: Context) {
         private const val PREFS_NAME = "MyAppPrefs"
         private const val KEY_SERVER_URL = "server_url"
         private const val KEY_TOKEN = "auth_token"
+        private const val KEY_REFRESH_TOKEN = "refresh_token"
         private const val KEY_USERNAME = "username"
         private const val KEY_USER_INFO = "user_info"
         private const val KEY_FCM_TOKEN = "fcm_token"
@@ -35,8 +36,16 @@ class PreferenceManager(context: Context) {
         return prefs.getString(KEY_TOKEN, null)
     }
 
+    fun saveRefreshToken(refreshToken: String) {
+        prefs.edit().putString(KEY_REFRESH_TOKEN, refreshToken).apply()
+    }
+
+    fun getRefreshToken(): String? {
+        return prefs.getString(KEY_REFRESH_TOKEN, null)
+    }
+
     fun clearToken() {
-        prefs.edit().remove(KEY_TOKEN).apply()
+        prefs.edit().remove(KEY_TOKEN).remove(KEY_REFRESH_TOKEN).apply()
     }
 
     fun saveUsername(username: String) {
@@ -1,5 +1,6 @@
 package com.example.myapp.viewmodel
 
+import android.net.Uri
 import android.util.Log
 import androidx.lifecycle.LiveData
 import androidx.lifecycle.MutableLiveData
@@ -15,7 +16,7 @@ sealed class CameraState {
     object Idle : CameraState()
     object Loading : CameraState()
     data class CamerasLoaded(val cameras: List<String>) : CameraState()
-    data class SnapshotLoaded(val snapshotUrl: String, val frigateToken: String) : CameraState()
+    data class SnapshotLoaded(val snapshotUrl: String) : CameraState()
     data class StreamLoaded(val streamUrl: String, val token: String) : CameraState()
     data class Error(val message: String) : CameraState()
 }
@@ -74,7 +75,7 @@ class CameraViewModel : ViewModel() {
     }
 
     /**
-     * 获取摄像头快照 URL 和 frigate token
+     * 获取摄像头快照代理 URL（使用 getSnapshotHeaders() 中的 token 加载）
      */
     fun loadCameraSnapshot(cameraName: String) {
         viewModelScope.launch {
@@ -92,8 +93,7 @@ class CameraViewModel : ViewModel() {
                         val body = response.body()
                         if (body?.status == "success") {
                             _state.value = CameraState.SnapshotLoaded(
-                                body.body.url,
-                                body.body.frigateToken
+                                serverUrl.trimEnd('/') + body.body.url
                             )
                             Log.d(TAG, "Snapshot loaded: ${body.body.url}")
                         } else {
@@ -129,8 +129,9 @@ class CameraViewModel : ViewModel() {
                     if (response.isSuccessful) {
                         val body = response.body()
                         if (body?.status == "success") {
-                            _state.value = CameraState.StreamLoaded(body.body.url, body.body.frigateToken)
-                            Log.d(TAG, "Stream loaded: ${body.body.url}")
+                            val streamUrl = "${serverUrl.trimEnd('/')}${body.body.url}"
+                            _state.value = CameraState.StreamLoaded(streamUrl, token)
+                            Log.d(TAG, "Stream loaded: $streamUrl")
                         } else {
                             _state.value = CameraState.Error(body?.message ?: "Failed to load stream")
                         }
@@ -148,12 +149,12 @@ class CameraViewModel : ViewModel() {
     }
 
     /**
-     * 获取摄像头快照 URL（旧方法，已弃用）
+     * 获取摄像头快照图片 URL（由服务器代理）
      */
     fun getSnapshotUrl(cameraName: String): String? {
         val serverUrl = preferenceManager?.getServerUrl()
         return if (serverUrl != null) {
-            "$serverUrl/api/camera/$cameraName/snapshot"
+            "${serverUrl.trimEnd('/')}/api/camera/${Uri.encode(cameraName)}/snapshot.jpg"
         } else {
             null
         }
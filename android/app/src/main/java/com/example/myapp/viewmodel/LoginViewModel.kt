@@ -16,7 +16,12 @@ import kotlinx.coroutines.launch
 sealed class LoginState {
     object Idle : LoginState()
     object Loading : LoginState()
-    data class Success(val token: String, val username: String, val user: com.example.myapp.model.UserInfo?) : LoginState()
+    data class Success(
+        val token: String,
+        val refreshToken: String?,
+        val username: String,
+        val user: com.example.myapp.model.UserInfo?
+    ) : LoginState()
     data class Error(val message: String) : LoginState()
 }
 
@@ -47,7 +52,7 @@ class LoginViewModel : ViewModel() {
                 val formattedUrl = if (baseUrl.endsWith("/")) baseUrl else "$baseUrl/"
 
                 val authService = ApiClient.createClient(formattedUrl).create(AuthService::class.java)
-                val request = LoginRequest(username, password)
+                val request = LoginRequest(username, password, DeviceInfoUtils.getDeviceName())
                 val response = authService.login(request)
 
                 if (response.isSuccessful) {
@@ -60,6 +65,7 @@ class LoginViewModel : ViewModel() {
 
                         _state.value = LoginState.Success(
                             jwtToken,
+                            responseBody.body.refreshToken,
                             username,
                             responseBody.body.user
                         )
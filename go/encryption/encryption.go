@@ -0,0 +1,267 @@
+// Package encryption implements envelope encryption for sensitive database fields.
+//
+// Every value is encrypted with its own random data key (AES-256-GCM), and the data
+// key is wrapped with a master key. Stored values look like
+//
+//	enc:v<version>:<wrapped data key>:<ciphertext>
+//
+// where version names the master key, so keys can be rotated without losing access
+// to rows written with an older one. Values without the prefix are treated as
+// plaintext written before encryption was enabled.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const prefix = "enc:v"
+
+// keyInfo is the HKDF context string binding derived keys to this use
+const keyInfo = "sotsukenn field encryption master key"
+
+// placeholderKey is the example secret from .env.example, which must never be used
+const placeholderKey = "change_me_to_a_random_secret"
+
+var (
+	ErrNoKeys     = errors.New("no encryption keys configured (ENCRYPTION_KEYS)")
+	ErrUnknownKey = errors.New("value was encrypted with an unknown key version")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+// Keyring holds the master keys by version and the version used for new values
+type Keyring struct {
+	keys   map[int][]byte
+	active int
+}
+
+var (
+	defaultKeyring *Keyring
+	keyringErr     error
+	keyringOnce    sync.Once
+)
+
+// LoadKeyring reads master keys from the environment.
+// ENCRYPTION_KEYS is a comma separated list of "version:key" pairs, e.g. "1:abc,2:def".
+// ENCRYPTION_ACTIVE_KEY picks the version for new values and defaults to the highest.
+// A key may be any secret string; the AES key is derived from it with HKDF-SHA256.
+func LoadKeyring() (*Keyring, error) {
+	kr := &Keyring{keys: make(map[int][]byte)}
+
+	raw := strings.TrimSpace(os.Getenv("ENCRYPTION_KEYS"))
+	if raw == "" {
+		return kr, nil
+	}
+
+	for _, entry := range strings.Split(raw, ",") {
+		version, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("invalid ENCRYPTION_KEYS entry %q, expected version:key", entry)
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid key version %q in ENCRYPTION_KEYS", version)
+		}
+		if secret == placeholderKey {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS version %d still uses the example secret, generate a random key", v)
+		}
+		key, err := deriveKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[v] = key
+		if v > kr.active {
+			kr.active = v
+		}
+	}
+
+	if active := os.Getenv("ENCRYPTION_ACTIVE_KEY"); active != "" {
+		v, err := strconv.Atoi(active)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENCRYPTION_ACTIVE_KEY %q", active)
+		}
+		if _, ok := kr.keys[v]; !ok {
+			return nil, fmt.Errorf("ENCRYPTION_ACTIVE_KEY %d is not listed in ENCRYPTION_KEYS", v)
+		}
+		kr.active = v
+	}
+
+	return kr, nil
+}
+
+// Default returns the keyring loaded from the environment on first use
+func Default() (*Keyring, error) {
+	keyringOnce.Do(func() {
+		defaultKeyring, keyringErr = LoadKeyring()
+		if keyringErr == nil && !defaultKeyring.Enabled() {
+			log.Println("[Encryption] ENCRYPTION_KEYS is not set, sensitive fields are stored in plaintext")
+		}
+	})
+	return defaultKeyring, keyringErr
+}
+
+// Enabled reports whether a master key is configured
+func (kr *Keyring) Enabled() bool {
+	return kr.active != 0
+}
+
+// ActiveVersion returns the key version used for new values
+func (kr *Keyring) ActiveVersion() int {
+	return kr.active
+}
+
+// Versions returns the configured key versions in ascending order
+func (kr *Keyring) Versions() []int {
+	versions := make([]int, 0, len(kr.keys))
+	for v := range kr.keys {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Encrypt seals plaintext with a fresh data key wrapped by the active master key.
+// Without a configured key the value is returned unchanged.
+func (kr *Keyring) Encrypt(plaintext string) (string, error) {
+	if !kr.Enabled() || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrappedKey, err := seal(kr.keys[kr.active], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%d:%s:%s", prefix, kr.active,
+		base64.RawStdEncoding.EncodeToString(wrappedKey),
+		base64.RawStdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// Decrypt opens a value produced by Encrypt; plaintext values are returned unchanged
+func (kr *Keyring) Decrypt(stored string) (string, error) {
+	version, ok := Version(stored)
+	if !ok {
+		return stored, nil
+	}
+
+	parts := strings.Split(stored, ":")
+	if len(parts) != 4 {
+		return "", ErrMalformed
+	}
+
+	masterKey, ok := kr.keys[version]
+	if !ok {
+		if !kr.Enabled() {
+			return "", ErrNoKeys
+		}
+		return "", fmt.Errorf("%w: v%d", ErrUnknownKey, version)
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := open(masterKey, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether a stored value is plaintext or uses an older key version
+func (kr *Keyring) NeedsRotation(stored string) bool {
+	if !kr.Enabled() || stored == "" {
+		return false
+	}
+	version, ok := Version(stored)
+	return !ok || version != kr.active
+}
+
+// Version returns the key version of an encrypted value
+func Version(stored string) (int, bool) {
+	if !strings.HasPrefix(stored, prefix) {
+		return 0, false
+	}
+	rest := stored[len(prefix):]
+	end := strings.IndexByte(rest, ':')
+	if end <= 0 {
+		return 0, false
+	}
+	v, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// seal encrypts data with AES-256-GCM and prepends the nonce
+func seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// open reverses seal
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// deriveKey turns a configured secret into a 256-bit master key
+func deriveKey(secret string) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, keyInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
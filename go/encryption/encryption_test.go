@@ -0,0 +1,143 @@
+package encryption
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func loadKeyring(t *testing.T, keys, active string) *Keyring {
+	t.Helper()
+	t.Setenv("ENCRYPTION_KEYS", keys)
+	t.Setenv("ENCRYPTION_ACTIVE_KEY", active)
+	kr, err := LoadKeyring()
+	if err != nil {
+		t.Fatalf("LoadKeyring(%q, %q): %v", keys, active, err)
+	}
+	return kr
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	kr := loadKeyring(t, "1:first-secret", "")
+
+	stored, err := kr.Encrypt("frigate-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, "enc:v1:") || strings.Contains(stored, "frigate-token") {
+		t.Fatalf("stored = %q, want an enc:v1 value without the plaintext", stored)
+	}
+
+	plaintext, err := kr.Decrypt(stored)
+	if err != nil || plaintext != "frigate-token" {
+		t.Fatalf("Decrypt = %q, %v", plaintext, err)
+	}
+	if kr.NeedsRotation(stored) {
+		t.Fatal("a value under the active key should not need rotation")
+	}
+
+	again, _ := kr.Encrypt("frigate-token")
+	if again == stored {
+		t.Fatal("every value should use a fresh data key and nonce")
+	}
+}
+
+func TestPlaintextPassesThrough(t *testing.T) {
+	kr := loadKeyring(t, "1:first-secret", "")
+	if got, err := kr.Decrypt("legacy-plaintext"); err != nil || got != "legacy-plaintext" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+	if !kr.NeedsRotation("legacy-plaintext") {
+		t.Fatal("plaintext should need rotation once a key is configured")
+	}
+
+	disabled := loadKeyring(t, "", "")
+	if got, _ := disabled.Encrypt("secret"); got != "secret" {
+		t.Fatalf("Encrypt without keys = %q, want plaintext", got)
+	}
+	if got, _ := kr.Encrypt(""); got != "" {
+		t.Fatalf("Encrypt of an empty value = %q, want empty", got)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := loadKeyring(t, "1:first-secret", "")
+	stored, err := old.Encrypt("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr := loadKeyring(t, "1:first-secret,2:second-secret", "")
+	if kr.ActiveVersion() != 2 {
+		t.Fatalf("active version = %d, want the highest", kr.ActiveVersion())
+	}
+	if !kr.NeedsRotation(stored) {
+		t.Fatal("a value under an older key should need rotation")
+	}
+	plaintext, err := kr.Decrypt(stored)
+	if err != nil || plaintext != "password" {
+		t.Fatalf("Decrypt with the old key still configured = %q, %v", plaintext, err)
+	}
+
+	rotated, err := kr.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := Version(rotated); v != 2 || kr.NeedsRotation(rotated) {
+		t.Fatalf("rotated value %q should use v2", rotated)
+	}
+
+	// Once rotated, the old key can be dropped
+	current := loadKeyring(t, "2:second-secret", "")
+	if got, err := current.Decrypt(rotated); err != nil || got != "password" {
+		t.Fatalf("Decrypt after dropping v1 = %q, %v", got, err)
+	}
+	if _, err := current.Decrypt(stored); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Decrypt of a v1 value without v1 = %v, want ErrUnknownKey", err)
+	}
+
+	pinned := loadKeyring(t, "1:first-secret,2:second-secret", "1")
+	if pinned.ActiveVersion() != 1 {
+		t.Fatalf("ENCRYPTION_ACTIVE_KEY=1 gave version %d", pinned.ActiveVersion())
+	}
+}
+
+func TestWrongKeyFails(t *testing.T) {
+	kr := loadKeyring(t, "1:first-secret", "")
+	stored, _ := kr.Encrypt("token")
+
+	other := loadKeyring(t, "1:another-secret", "")
+	if _, err := other.Decrypt(stored); err == nil {
+		t.Fatal("Decrypt with a different secret under the same version should fail")
+	}
+
+	disabled := loadKeyring(t, "", "")
+	if _, err := disabled.Decrypt(stored); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("Decrypt without keys = %v, want ErrNoKeys", err)
+	}
+
+	parts := strings.Split(stored, ":")
+	tampered := strings.Join(append(parts[:3], parts[3][:len(parts[3])-2]+"AA"), ":")
+	if _, err := kr.Decrypt(tampered); err == nil {
+		t.Fatal("Decrypt of a tampered ciphertext should fail")
+	}
+	if _, err := kr.Decrypt("enc:v1:only-three"); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Decrypt of a malformed value = %v, want ErrMalformed", err)
+	}
+}
+
+func TestLoadKeyringRejectsBadConfiguration(t *testing.T) {
+	for _, tc := range []struct{ keys, active string }{
+		{"1:change_me_to_a_random_secret", ""},
+		{"1", ""},
+		{"0:secret", ""},
+		{"x:secret", ""},
+		{"1:secret", "2"},
+	} {
+		t.Setenv("ENCRYPTION_KEYS", tc.keys)
+		t.Setenv("ENCRYPTION_ACTIVE_KEY", tc.active)
+		if _, err := LoadKeyring(); err == nil {
+			t.Errorf("LoadKeyring(%q, %q) succeeded, want an error", tc.keys, tc.active)
+		}
+	}
+}
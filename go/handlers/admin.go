@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// CreateUserRequest represents a request to create a local user
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password" binding:"required,min=8"`
+	Role     string `json:"role" binding:"omitempty,oneof=admin member viewer"`
+}
+
+// UpdateUserRequest represents a request to update a user as administrator
+type UpdateUserRequest struct {
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role" binding:"omitempty,oneof=admin member viewer"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// ResetPasswordRequest represents a request to set a new password for a user
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// ListUsers returns all users
+// GET /api/admin/users
+func ListUsers(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var users []models.User
+	if err := db.Order("id ASC").Find(&users).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve users", err.Error())
+		return
+	}
+
+	response := make([]gin.H, len(users))
+	for i, user := range users {
+		response[i] = adminUserResponse(&user)
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Users retrieved", "", response))
+}
+
+// GetUser returns a single user
+// GET /api/admin/users/:id
+func GetUser(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	user, ok := findUserByParam(ctx, db)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "User retrieved", "", adminUserResponse(user)))
+}
+
+// CreateUser creates a local user
+// POST /api/admin/users
+func CreateUser(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req CreateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	var existing int64
+	db.Model(&models.User{}).Unscoped().Where("username = ?", req.Username).Count(&existing)
+	if existing > 0 {
+		utils.RespondWithError(ctx, http.StatusConflict, "Username already exists", nil)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to hash password", nil)
+		return
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+
+	user := models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: string(hashedPassword),
+		IsActive: true,
+		Role:     role,
+	}
+
+	if err := db.Create(&user).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create user", err.Error())
+		return
+	}
+
+	log.Printf("[Admin] User %s created with role %s by user %v", user.Username, user.Role, ctx.MustGet("user_id"))
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "User created", "", adminUserResponse(&user)))
+}
+
+// UpdateUser updates email, role or active state of a user
+// Disabling a user revokes all of their sessions and push tokens; changing the role revokes
+// their sessions so tokens carrying the old role stop working
+// PUT /api/admin/users/:id
+func UpdateUser(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	user, ok := findUserByParam(ctx, db)
+	if !ok {
+		return
+	}
+
+	demoting := req.Role != "" && req.Role != models.RoleAdmin
+	previousRole := user.Role
+	roleChanged := req.Role != "" && req.Role != previousRole
+	disabling := req.IsActive != nil && !*req.IsActive
+
+	if user.ID == ctx.MustGet("user_id").(uint) && (demoting || disabling) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "You cannot demote or disable your own account", nil)
+		return
+	}
+
+	if user.Role == models.RoleAdmin && user.IsActive && (demoting || disabling) && isLastActiveAdmin(db, user.ID) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Cannot demote or disable the last active admin", nil)
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Email != "" {
+		updates["email"] = req.Email
+	}
+	if req.Role != "" {
+		updates["role"] = req.Role
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	if err := db.Model(user).Updates(updates).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to update user", err.Error())
+		return
+	}
+
+	if disabling {
+		revokeUserSessions(ctx, db, user.ID)
+		deactivateUserFCMTokens(db, user.ID)
+		log.Printf("[Admin] User %s disabled", user.Username)
+	} else if roleChanged {
+		revokeUserSessions(ctx, db, user.ID)
+		log.Printf("[Admin] User %s changed role from %s to %s", user.Username, previousRole, req.Role)
+	}
+
+	// Refresh from database
+	db.First(user, user.ID)
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "User updated", "", adminUserResponse(user)))
+}
+
+// DeleteUser deletes a user and revokes all of their sessions and push tokens
+// DELETE /api/admin/users/:id
+func DeleteUser(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	user, ok := findUserByParam(ctx, db)
+	if !ok {
+		return
+	}
+
+	if user.ID == ctx.MustGet("user_id").(uint) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "You cannot delete your own account", nil)
+		return
+	}
+
+	if user.Role == models.RoleAdmin && user.IsActive && isLastActiveAdmin(db, user.ID) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Cannot delete the last active admin", nil)
+		return
+	}
+
+	// Instances belong to the households, so they stay with the remaining members
+	if err := services.NewHouseholdService(db).RemoveUser(user.ID); err != nil {
+		if errors.Is(err, services.ErrLastOwner) {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Cannot delete the last owner of a household, transfer ownership or delete the household first", err.Error())
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to remove household memberships", err.Error())
+		return
+	}
+
+	revokeUserSessions(ctx, db, user.ID)
+	deactivateUserFCMTokens(db, user.ID)
+
+	if err := db.Delete(user).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to delete user", err.Error())
+		return
+	}
+
+	log.Printf("[Admin] User %s deleted", user.Username)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "User deleted", "", nil))
+}
+
+// ResetUserPassword sets a new password for a user and logs them out everywhere
+// POST /api/admin/users/:id/password
+func ResetUserPassword(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	user, ok := findUserByParam(ctx, db)
+	if !ok {
+		return
+	}
+
+	if err := services.NewPasswordService(db).SetPassword(user.ID, req.Password); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to reset password", err.Error())
+		return
+	}
+
+	revokeUserSessions(ctx, db, user.ID)
+
+	log.Printf("[Admin] Password reset for user %s", user.Username)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Password reset", "", nil))
+}
+
+// ListLoginLockouts returns login throttle entries; pass ?all=true to include entries that are not locked
+// GET /api/admin/lockouts
+func ListLoginLockouts(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	throttles, err := services.NewLoginThrottleService(db).List(ctx.Query("all") != "true")
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve lockouts", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Lockouts retrieved", "", throttles))
+}
+
+// ClearLoginLockout lifts a lockout and forgets its failed attempts
+// DELETE /api/admin/lockouts/:id
+func ClearLoginLockout(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid lockout ID", err.Error())
+		return
+	}
+
+	if err := services.NewLoginThrottleService(db).Clear(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Lockout not found", nil)
+		} else {
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to clear lockout", err.Error())
+		}
+		return
+	}
+
+	log.Printf("[Admin] Login lockout %d cleared by user %v", id, ctx.MustGet("user_id"))
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Lockout cleared", "", nil))
+}
+
+// findUserByParam loads the user referenced by the :id path parameter,
+// writing an error response and returning false if it cannot be found
+func findUserByParam(ctx *gin.Context, db *gorm.DB) (*models.User, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return nil, false
+	}
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.RespondWithError(ctx, http.StatusNotFound, "User not found", nil)
+		} else {
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		}
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// isLastActiveAdmin reports whether no other active admin exists besides userID
+func isLastActiveAdmin(db *gorm.DB, userID uint) bool {
+	var count int64
+	db.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id <> ?", models.RoleAdmin, true, userID).
+		Count(&count)
+	return count == 0
+}
+
+// revokeUserSessions drops every live access token and refresh token of a user
+func revokeUserSessions(ctx *gin.Context, db *gorm.DB, userID uint) {
+	utils.GetTokenStoreFromContext(ctx).DeleteUser(userID)
+	if err := services.NewRefreshTokenService(db).RevokeUser(userID); err != nil {
+		log.Printf("[Admin] Failed to revoke refresh tokens of user %d: %v", userID, err)
+	}
+}
+
+// deactivateUserFCMTokens stops push notifications to every device of a user
+func deactivateUserFCMTokens(db *gorm.DB, userID uint) {
+	if err := db.Model(&models.FCMToken{}).Where("user_id = ?", userID).Update("is_active", false).Error; err != nil {
+		log.Printf("[Admin] Failed to deactivate FCM tokens of user %d: %v", userID, err)
+	}
+}
+
+func adminUserResponse(user *models.User) gin.H {
+	return gin.H{
+		"id":           user.ID,
+		"username":     user.Username,
+		"email":        user.Email,
+		"role":         user.Role,
+		"is_active":    user.IsActive,
+		"totp_enabled": user.TOTPEnabled,
+		"created_at":   user.CreatedAt,
+		"updated_at":   user.UpdatedAt,
+	}
+}
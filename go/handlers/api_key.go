@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1"` // Omit for a key that never expires
+}
+
+// authenticateAPIKey authenticates a request carrying an X-API-Key header.
+// The key must hold at least one of scopes; endpoints that declare no scopes reject API keys.
+func authenticateAPIKey(ctx *gin.Context, rawKey string, scopes []string) {
+	if len(scopes) == 0 {
+		utils.RespondWithError(ctx, http.StatusForbidden, "API keys are not accepted on this endpoint", nil)
+		ctx.Abort()
+		return
+	}
+
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		ctx.Abort()
+		return
+	}
+
+	var apiKey models.APIKey
+	if err := db.Preload("User").Where("key_hash = ?", models.HashToken(rawKey)).First(&apiKey).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid API key", nil)
+		ctx.Abort()
+		return
+	}
+
+	if !apiKey.IsUsable() {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "API key revoked or expired", nil)
+		ctx.Abort()
+		return
+	}
+
+	if apiKey.User.ID == 0 || !apiKey.User.IsActive {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Account is inactive", nil)
+		ctx.Abort()
+		return
+	}
+
+	if !apiKey.HasAnyScope(scopes...) {
+		utils.RespondWithError(ctx, http.StatusForbidden, "API key is missing the required scope", scopes)
+		ctx.Abort()
+		return
+	}
+
+	now := time.Now()
+	db.Model(&apiKey).UpdateColumn("last_used_at", &now)
+
+	ctx.Set("user_id", apiKey.UserID)
+	ctx.Set("role", apiKey.User.Role)
+	ctx.Set("api_key_id", apiKey.ID)
+	ctx.Next()
+}
+
+// CreateAPIKey creates an API key for the authenticated user
+// The raw key is only returned in this response
+// POST /api/users/api-keys
+func CreateAPIKey(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	seen := make(map[string]bool)
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		scope = strings.TrimSpace(scope)
+		if !models.IsValidScope(scope) {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid scope", scope)
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate API key", nil)
+		return
+	}
+	rawKey := models.APIKeyPrefix + secret
+
+	apiKey := models.APIKey{
+		UserID:  userID.(uint),
+		Name:    req.Name,
+		Prefix:  rawKey[:len(models.APIKeyPrefix)+8],
+		KeyHash: models.HashToken(rawKey),
+		Scopes:  strings.Join(scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := db.Create(&apiKey).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create API key", err.Error())
+		return
+	}
+
+	log.Printf("[API Key] Key %s (%s) created for user %v", apiKey.Prefix, apiKey.Scopes, userID)
+
+	response := apiKeyResponse(&apiKey)
+	response["key"] = rawKey
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "API key created, store it now as it will not be shown again", "", response))
+}
+
+// GetAPIKeys lists the API keys of the authenticated user
+// GET /api/users/api-keys
+func GetAPIKeys(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var keys []models.APIKey
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&keys).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve API keys", err.Error())
+		return
+	}
+
+	response := make([]gin.H, len(keys))
+	for i, key := range keys {
+		response[i] = apiKeyResponse(&key)
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "API keys retrieved", "", response))
+}
+
+// RevokeAPIKey revokes an API key of the authenticated user
+// DELETE /api/users/api-keys/:id
+func RevokeAPIKey(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid API key ID", err.Error())
+		return
+	}
+
+	var apiKey models.APIKey
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&apiKey).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "API key not found", nil)
+		return
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		if err := db.Model(&apiKey).Update("revoked_at", &now).Error; err != nil {
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to revoke API key", err.Error())
+			return
+		}
+	}
+
+	log.Printf("[API Key] Key %s revoked by user %v", apiKey.Prefix, userID)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "API key revoked", "", nil))
+}
+
+func apiKeyResponse(key *models.APIKey) gin.H {
+	return gin.H{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scopes":       key.ScopeList(),
+		"last_used_at": key.LastUsedAt,
+		"expires_at":   key.ExpiresAt,
+		"revoked_at":   key.RevokedAt,
+		"created_at":   key.CreatedAt,
+	}
+}
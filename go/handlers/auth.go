@@ -3,8 +3,10 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,8 +20,27 @@ import (
 	"gorm.io/gorm"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware authenticates requests with a JWT in the Authorization header.
+// Machine clients may instead send an X-API-Key header; this is only accepted
+// when the endpoint lists scopes, and the key must hold one of them.
+// Guest sessions are refused; see GuestAuthMiddleware.
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
+	return authMiddleware(false, scopes)
+}
+
+// GuestAuthMiddleware is AuthMiddleware that also admits guest sessions redeemed from a
+// guest grant. Handlers behind it must check the grant with guestGrantFromContext.
+func GuestAuthMiddleware(scopes ...string) gin.HandlerFunc {
+	return authMiddleware(true, scopes)
+}
+
+func authMiddleware(allowGuest bool, scopes []string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if apiKey := ctx.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(ctx, apiKey, scopes)
+			return
+		}
+
 		authHeader := ctx.GetHeader("Authorization")
 		if authHeader == "" {
 			utils.RespondWithError(ctx, http.StatusUnauthorized, "Authorization header required", nil)
@@ -34,7 +55,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		_, err := utils.ValidateJWT(tokenString)
+		claims, err := utils.ValidateJWT(tokenString)
 		if err != nil {
 			utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid token", err.Error())
 			ctx.Abort()
@@ -49,16 +70,54 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if claims["typ"] == guestSessionType {
+			if !allowGuest {
+				utils.RespondWithError(ctx, http.StatusForbidden, "Guest sessions cannot access this endpoint", nil)
+				ctx.Abort()
+				return
+			}
+			authenticateGuest(ctx, tokenString, claims)
+			return
+		}
+
+		role, _ := claims["role"].(string)
+
+		if tokenInfo.FamilyID != "" {
+			if db, err := utils.GetDBFromContext(ctx); err == nil {
+				services.NewSessionService(db).Touch(tokenInfo.FamilyID, ctx.ClientIP())
+			}
+		}
+
 		ctx.Set("user_id", tokenInfo.UserID)
+		ctx.Set("role", role)
 		ctx.Set("token", tokenString)
+		ctx.Set("family_id", tokenInfo.FamilyID)
 		ctx.Next()
 	}
 }
 
+// RequireRole only lets requests through whose authenticated role is one of roles.
+// It must be registered after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		role := ctx.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				ctx.Next()
+				return
+			}
+		}
+
+		utils.RespondWithError(ctx, http.StatusForbidden, "Insufficient permissions", nil)
+		ctx.Abort()
+	}
+}
+
 type LoginRequest struct {
 	Username   string `json:"username" binding:"required"`
 	Password   string `json:"password" binding:"required"`
 	FrigateURL string `json:"frigate_url,omitempty"` // Required for new Frigate-only users
+	DeviceName string `json:"device_name,omitempty"` // Shown in the session list, e.g. "Pixel 6"
 }
 
 func Login(ctx *gin.Context) {
@@ -74,6 +133,12 @@ func Login(ctx *gin.Context) {
 		return
 	}
 
+	// Refuse to check any password while the IP or username is locked out
+	if lockedUntil, locked := services.NewLoginThrottleService(db).Check(ctx.ClientIP(), req.Username); locked {
+		respondLockedOut(ctx, lockedUntil)
+		return
+	}
+
 	// Scenario A: Local user exists
 	var user models.User
 	err = db.Where("username = ?", req.Username).First(&user).Error
@@ -99,7 +164,7 @@ func Login(ctx *gin.Context) {
 			return
 		}
 
-		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid credentials", nil)
+		loginFailed(ctx, db, req.Username, http.StatusUnauthorized, "Invalid credentials", nil)
 		return
 	}
 
@@ -107,11 +172,31 @@ func Login(ctx *gin.Context) {
 	utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
 }
 
+// loginFailed records a failed attempt for the client IP and username, then responds.
+// If this attempt triggered a lockout the client is told when to retry instead.
+func loginFailed(ctx *gin.Context, db *gorm.DB, username string, statusCode int, message string, details interface{}) {
+	if lockedUntil, locked := services.NewLoginThrottleService(db).RecordFailure(ctx.ClientIP(), username); locked {
+		respondLockedOut(ctx, lockedUntil)
+		return
+	}
+	utils.RespondWithError(ctx, statusCode, message, details)
+}
+
+// respondLockedOut responds with 429 and a Retry-After header
+func respondLockedOut(ctx *gin.Context, lockedUntil time.Time) {
+	retryAfter := int64(time.Until(lockedUntil).Seconds()) + 1
+	ctx.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	utils.RespondWithError(ctx, http.StatusTooManyRequests, "Too many failed login attempts", gin.H{
+		"retry_after":  retryAfter,
+		"locked_until": lockedUntil,
+	})
+}
+
 // handleLocalUserLogin handles authentication for existing local users
 func handleLocalUserLogin(ctx *gin.Context, db *gorm.DB, user *models.User, req *LoginRequest) {
-	// Step 1: Verify local password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid credentials", nil)
+	// Step 1: Verify local password (Frigate-managed passwords fall back to Frigate)
+	if !checkPassword(db, user, req.Password) {
+		loginFailed(ctx, db, req.Username, http.StatusUnauthorized, "Invalid credentials", nil)
 		return
 	}
 
@@ -120,17 +205,24 @@ func handleLocalUserLogin(ctx *gin.Context, db *gorm.DB, user *models.User, req
 		return
 	}
 
-	// Step 2: Check for Frigate integration
-	var frigateConnect models.FrigateConnect
-	err := db.Where("user_id = ? AND is_active = ?", user.ID, true).First(&frigateConnect).Error
+	// Step 2: Check for Frigate integrations - verify/refresh the token of each instance
+	var frigateConnects []models.FrigateConnect
+	db.Where("user_id = ? AND is_active = ?", user.ID, true).Order("id ASC").Find(&frigateConnects)
+	for i := range frigateConnects {
+		verifyOrRefreshFrigateToken(ctx, db, user, &frigateConnects[i], req.Password)
+	}
 
-	if err == nil {
-		// Frigate integration exists - verify/refresh token
-		verifyOrRefreshFrigateToken(ctx, db, user, &frigateConnect, req.Password)
+	// Step 3: With 2FA enabled the session is only issued after the code is verified.
+	// Failed attempts are kept until then so codes cannot be guessed between password logins.
+	if user.TOTPEnabled {
+		respondWithTwoFactorChallenge(ctx, user, req.DeviceName)
+		return
 	}
 
-	// Step 3: Generate local JWT and respond
-	generateLocalToken(ctx, user)
+	services.NewLoginThrottleService(db).RecordSuccess(req.Username)
+
+	// Step 4: Generate local JWT and respond
+	generateLocalToken(ctx, db, user, req.DeviceName)
 }
 
 // verifyOrRefreshFrigateToken verifies and refreshes Frigate tokens if needed
@@ -170,6 +262,7 @@ func verifyOrRefreshFrigateToken(ctx *gin.Context, db *gorm.DB, user *models.Use
 	now := time.Now()
 	fc.TokenCookie = newToken
 	fc.LastVerifiedAt = &now
+	services.RememberFrigatePassword(fc, password)
 	db.Save(fc)
 
 	fmt.Printf("Frigate token refreshed for user %d\n", user.ID)
@@ -182,10 +275,12 @@ func handleFrigateOnlyLogin(ctx *gin.Context, db *gorm.DB, req *LoginRequest) {
 	// Step 1: Authenticate with Frigate API
 	token, err := client.Login(req.Username, req.Password)
 	if err != nil {
-		utils.RespondWithError(ctx, http.StatusUnauthorized, "Frigate authentication failed", err.Error())
+		loginFailed(ctx, db, req.Username, http.StatusUnauthorized, "Frigate authentication failed", err.Error())
 		return
 	}
 
+	services.NewLoginThrottleService(db).RecordSuccess(req.Username)
+
 	// Step 2: Create local user record
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -193,11 +288,21 @@ func handleFrigateOnlyLogin(ctx *gin.Context, db *gorm.DB, req *LoginRequest) {
 		return
 	}
 
+	// The very first account becomes the administrator
+	role := models.RoleMember
+	var userCount int64
+	if err := db.Model(&models.User{}).Count(&userCount).Error; err == nil && userCount == 0 {
+		role = models.RoleAdmin
+	}
+
 	user := models.User{
 		Username: req.Username,
 		Email:    "", // Optional, leave empty
 		Password: string(hashedPassword),
 		IsActive: true,
+		Role:     role,
+
+		FrigateManagedPassword: true,
 	}
 
 	if err := db.Create(&user).Error; err != nil {
@@ -205,16 +310,34 @@ func handleFrigateOnlyLogin(ctx *gin.Context, db *gorm.DB, req *LoginRequest) {
 		return
 	}
 
-	// Step 3: Create FrigateConnect mapping
+	// Step 3: Create FrigateConnect mapping in a new household owned by the user
+	household, err := services.NewHouseholdService(db).DefaultHousehold(&user)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create household", nil)
+		return
+	}
+
+	// Another household may already receive events under the default prefix; the new
+	// instance then stays unrouted until its owner sets Frigate's actual topic prefix
+	topicPrefix := models.DefaultMQTTTopicPrefix
+	if err := services.CheckTopicPrefixAvailable(db, topicPrefix, 0); err != nil {
+		log.Printf("[Auth] MQTT topic prefix %q is taken, instance of user %s is not routed: %v", topicPrefix, user.Username, err)
+		topicPrefix = ""
+	}
+
 	now := time.Now()
 	frigateConnect := models.FrigateConnect{
 		UserID:          user.ID,
+		HouseholdID:     &household.ID,
+		Name:            models.DefaultFrigateInstanceName,
 		FrigateURL:      req.FrigateURL,
 		FrigateUsername: req.Username,
+		MQTTTopicPrefix: topicPrefix,
 		TokenCookie:     token,
 		LastVerifiedAt:  &now,
 		IsActive:        true,
 	}
+	services.RememberFrigatePassword(&frigateConnect, req.Password)
 
 	if err := db.Create(&frigateConnect).Error; err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create Frigate integration", nil)
@@ -222,41 +345,158 @@ func handleFrigateOnlyLogin(ctx *gin.Context, db *gorm.DB, req *LoginRequest) {
 	}
 
 	// Step 4: Generate local JWT and respond
-	generateLocalToken(ctx, &user)
+	generateLocalToken(ctx, db, &user, req.DeviceName)
+}
+
+// accessTokenTTL returns the lifetime of access tokens (JWT_ACCESS_TOKEN_TTL, default 15m)
+func accessTokenTTL() time.Duration {
+	return utils.GetEnvDuration("JWT_ACCESS_TOKEN_TTL", 15*time.Minute)
 }
 
-// generateLocalToken generates JWT token and returns success response
-func generateLocalToken(ctx *gin.Context, user *models.User) {
+// issueAccessToken signs a short-lived JWT for the user and records it in the token store
+func issueAccessToken(ctx *gin.Context, user *models.User, familyID string) (string, error) {
+	expiresAt := time.Now().Add(accessTokenTTL())
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"username": user.Username,
 		"email":    user.Email,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+		"role":     user.Role,
+		"fid":      familyID,
+		"exp":      expiresAt.Unix(),
 	}
 
 	tokenString, err := utils.GenerateJWT(&claims)
 	if err != nil {
-		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate token", nil)
-		return
+		return "", err
 	}
 
 	tokenStore := utils.GetTokenStoreFromContext(ctx)
-	tokenStore.Set(tokenString, &models.TokenInfo{
+	err = tokenStore.Set(tokenString, &models.TokenInfo{
 		Token:     tokenString,
-		ExpiresAt: time.Now().Add(time.Hour * 24),
+		ExpiresAt: expiresAt,
 		UserID:    user.ID,
+		FamilyID:  familyID,
 	})
+	if err != nil {
+		log.Printf("[Auth] %v", err)
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// generateLocalToken starts a new session and responds with an access/refresh token pair
+func generateLocalToken(ctx *gin.Context, db *gorm.DB, user *models.User, deviceName string) {
+	refreshSvc := services.NewRefreshTokenService(db)
+
+	familyID, err := refreshSvc.NewFamily()
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate token", nil)
+		return
+	}
+
+	refreshToken, _, err := refreshSvc.Issue(user.ID, familyID)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate token", nil)
+		return
+	}
+
+	if _, err := services.NewSessionService(db).Create(user.ID, familyID, deviceName, ctx.ClientIP(), ctx.Request.UserAgent()); err != nil {
+		refreshSvc.RevokeFamily(familyID)
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create session", nil)
+		return
+	}
+
+	tokenString, err := issueAccessToken(ctx, user, familyID)
+	if err != nil {
+		refreshSvc.RevokeFamily(familyID)
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate token", nil)
+		return
+	}
+
+	respondWithTokens(ctx, "Login successful", user, tokenString, refreshToken, refreshSvc.TTL())
+}
 
-	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Login successful", "", gin.H{
-		"token": tokenString,
+// respondWithTokens writes the token pair response shared by login and refresh
+func respondWithTokens(ctx *gin.Context, message string, user *models.User, accessToken, refreshToken string, refreshTTL time.Duration) {
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, message, "", gin.H{
+		"token":              accessToken,
+		"expires_in":         int64(accessTokenTTL().Seconds()),
+		"refresh_token":      refreshToken,
+		"refresh_expires_in": int64(refreshTTL.Seconds()),
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.Username,
 			"email":    user.Email,
+			"role":     user.Role,
 		},
 	}))
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh exchanges a refresh token for a new access/refresh token pair
+// POST /api/auth/refresh
+func Refresh(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	refreshSvc := services.NewRefreshTokenService(db)
+	refreshToken, record, err := refreshSvc.Rotate(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			// Someone replayed a token: drop every access token of the family as well
+			utils.GetTokenStoreFromContext(ctx).DeleteFamily(record.FamilyID)
+			utils.RespondWithError(ctx, http.StatusUnauthorized, "Refresh token reuse detected, session revoked", nil)
+		case errors.Is(err, services.ErrRefreshTokenInvalid):
+			utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid or expired refresh token", nil)
+		default:
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to refresh token", nil)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, record.UserID).Error; err != nil {
+		refreshSvc.RevokeFamily(record.FamilyID)
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not found", nil)
+		return
+	}
+
+	if !user.IsActive {
+		refreshSvc.RevokeFamily(record.FamilyID)
+		utils.RespondWithError(ctx, http.StatusForbidden, "Account is inactive", nil)
+		return
+	}
+
+	tokenString, err := issueAccessToken(ctx, &user, record.FamilyID)
+	if err != nil {
+		// The client never sees the new refresh token, so let it retry with the old one
+		if err := refreshSvc.Revert(req.RefreshToken, record); err != nil {
+			log.Printf("[Auth] Failed to revert refresh token rotation: %v", err)
+			refreshSvc.RevokeFamily(record.FamilyID)
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate token", nil)
+		return
+	}
+
+	services.NewSessionService(db).Touch(record.FamilyID, ctx.ClientIP())
+
+	respondWithTokens(ctx, "Token refreshed", &user, tokenString, refreshToken, refreshSvc.TTL())
+}
+
 func Logout(ctx *gin.Context) {
 	token, exists := ctx.Get("token")
 	if !exists {
@@ -267,5 +507,13 @@ func Logout(ctx *gin.Context) {
 	tokenStore := utils.GetTokenStoreFromContext(ctx)
 	tokenStore.Delete(token.(string))
 
+	// Revoke the refresh tokens of this login so the session cannot be renewed
+	if familyID := ctx.GetString("family_id"); familyID != "" {
+		if db, err := utils.GetDBFromContext(ctx); err == nil {
+			services.NewRefreshTokenService(db).RevokeFamily(familyID)
+		}
+		tokenStore.DeleteFamily(familyID)
+	}
+
 	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Logout successful", "", nil))
 }
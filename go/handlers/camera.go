@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"sotsukenn/go/models"
 	"sotsukenn/go/services"
@@ -10,10 +18,29 @@ import (
 	"sotsukenn/go/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// GetCameras retrieves all camera names from go2rtc streams
-// GET /api/cameras
+// allInstances selects every active Frigate instance of the user's households
+const allInstances = "all"
+
+// maxSnapshotHeight caps the h option of snapshot requests
+const maxSnapshotHeight = 2160
+
+// Live stream proxy settings
+const (
+	mjpegBoundary          = "frame"
+	mjpegFirstFrameTimeout = 10 * time.Second
+
+	streamWriteTimeout        = 30 * time.Second
+	streamAccessCheckInterval = 30 * time.Second
+)
+
+// GetCameras retrieves all cameras from go2rtc streams. Alternate streams of a camera are
+// grouped; details carries each camera's config and live state, cameras just the names.
+// Without ?instance= (or with instance=all) cameras of every active Frigate instance
+// of the user's households are merged.
+// GET /api/cameras?instance=xxx
 // Requires authentication (JWT token)
 func GetCameras(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
@@ -28,34 +55,139 @@ func GetCameras(ctx *gin.Context) {
 		return
 	}
 
-	// Get user's Frigate configuration
-	var frigateConnect models.FrigateConnect
-	err = db.Where("user_id = ? AND is_active = ?", userID, true).First(&frigateConnect).Error
-	if err != nil {
+	// Get user's Frigate configurations
+	instances, ok := requestedInstances(ctx, db, userID.(uint))
+	if !ok {
+		return
+	}
+
+	aclSvc := services.NewCameraACLService(db)
+	detailSvc := services.NewCameraDetailService(db)
+	seen := make(map[string]bool)
+	cameras := []string{}
+	details := []types.CameraDetail{}
+	instanceResults := make([]gin.H, 0, len(instances))
+	failures := 0
+
+	for i := range instances {
+		frigateConnect := &instances[i]
+		result := gin.H{
+			"id":   frigateConnect.ID,
+			"name": frigateConnect.Name,
+		}
+
+		// Streams grouped by camera, shared with other recent requests; an expired token is
+		// renewed with stored credentials if available
+		instanceDetails, err := detailSvc.Details(frigateConnect)
+		if err != nil {
+			failures++
+			result["cameras"] = []string{}
+			result["error"] = err.Error()
+			instanceResults = append(instanceResults, result)
+			continue
+		}
+
+		// Keep the cameras the user may view
+		access, err := aclSvc.Access(*frigateConnect.HouseholdID, userID.(uint))
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to load camera permissions", err.Error())
+			return
+		}
+		instanceCameras := []string{}
+		for _, detail := range instanceDetails {
+			// Cameras without any go2rtc stream cannot be watched
+			if len(detail.Streams) == 0 || !access.Allowed(detail.Name, models.CameraActionViewLive) {
+				continue
+			}
+			instanceCameras = append(instanceCameras, detail.Name)
+			details = append(details, detail)
+			if !seen[detail.Name] {
+				seen[detail.Name] = true
+				cameras = append(cameras, detail.Name)
+			}
+		}
+
+		result["cameras"] = instanceCameras
+		instanceResults = append(instanceResults, result)
+	}
+
+	// Only fail when no instance could be reached
+	if failures == len(instances) {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve streams from Frigate", instanceResults)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Cameras retrieved successfully", "", gin.H{
+		"cameras":   cameras,
+		"details":   details,
+		"instances": instanceResults,
+	}))
+}
+
+// requestedInstances returns the instance selected by ?instance=, or every active instance of
+// the user's households when it is omitted or "all". It responds with 404 if there is none.
+func requestedInstances(ctx *gin.Context, db *gorm.DB, userID uint) ([]models.FrigateConnect, bool) {
+	var instances []models.FrigateConnect
+	if identifier := ctx.Query("instance"); identifier != "" && identifier != allInstances {
+		frigateConnect, ok := resolveFrigateConnect(ctx, db, userID)
+		if !ok {
+			return nil, false
+		}
+		return append(instances, *frigateConnect), true
+	}
+
+	householdIDs := services.NewHouseholdService(db).MemberHouseholdIDs(userID)
+	db.Where("household_id IN (?) AND is_active = ?", householdIDs, true).Order("id ASC").Find(&instances)
+	if len(instances) == 0 {
 		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate configuration not found", nil)
+		return nil, false
+	}
+	return instances, true
+}
+
+// GetCamera retrieves the config and live state of one camera, including its alternate streams
+// GET /api/cameras/:name?instance=xxx
+// Requires authentication (JWT token)
+func GetCamera(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
 		return
 	}
 
-	// Create Frigate client
-	client := services.NewFrigateClient(frigateConnect.FrigateURL)
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	cameraName := normalizeCameraName(ctx.Param("name"))
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
+		return
+	}
+	if !requireCameraAccess(ctx, db, userID.(uint), frigateConnect, cameraName, models.CameraActionViewLive) {
+		return
+	}
 
-	// Get streams using stored Frigate token (Bearer authentication)
-	streams, err := client.GetGo2RTCStreamsWithToken(frigateConnect.TokenCookie)
+	details, err := services.NewCameraDetailService(db).Details(frigateConnect)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve streams from Frigate", err.Error())
 		return
 	}
 
-	// Extract camera names and deduplicate (remove _WebRTC suffix)
-	cameras := deduplicateCameraNames(streams)
-
-	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Cameras retrieved successfully", "", gin.H{
-		"cameras": cameras,
-	}))
+	for _, detail := range details {
+		if detail.Name == cameraName {
+			ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Camera retrieved successfully", "", detail))
+			return
+		}
+	}
+	utils.RespondWithError(ctx, http.StatusNotFound, "Camera not found", cameraName)
 }
 
-// GetCameraSnapshot returns the URL for the latest snapshot from a camera
-// GET /api/camera/:name/snapshot
+// GetCameraSnapshot returns the URL of the server-side snapshot proxy for a camera.
+// Clients load it with their own JWT; the Frigate token is never handed out.
+// GET /api/camera/:name/snapshot?instance=xxx
 // Requires authentication (JWT token)
 func GetCameraSnapshot(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
@@ -70,33 +202,75 @@ func GetCameraSnapshot(ctx *gin.Context) {
 		return
 	}
 
-	cameraName := ctx.Param("name")
+	cameraName := normalizeCameraName(ctx.Param("name"))
 	if cameraName == "" {
 		utils.RespondWithError(ctx, http.StatusBadRequest, "Camera name is required", nil)
 		return
 	}
 
-	// Get user's Frigate configuration
-	var frigateConnect models.FrigateConnect
-	err = db.Where("user_id = ? AND is_active = ?", userID, true).First(&frigateConnect).Error
-	if err != nil {
-		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate configuration not found", nil)
+	// Get user's Frigate configuration (?instance= selects one, default is the first active)
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
 		return
 	}
 
-	// Create Frigate client and get snapshot URL
-	client := services.NewFrigateClient(frigateConnect.FrigateURL)
-	snapshotURL := client.GetLatestSnapshotURL(cameraName)
+	if !requireCameraAccess(ctx, db, userID.(uint), frigateConnect, cameraName, models.CameraActionViewLive) {
+		return
+	}
+
+	snapshotURL := fmt.Sprintf("/api/camera/%s/snapshot.jpg?%s", url.PathEscape(cameraName),
+		url.Values{"instance": {frigateConnect.Name}}.Encode())
 
 	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Snapshot URL retrieved", "", gin.H{
-		"camera_name":   cameraName,
-		"url":           snapshotURL,
-		"frigate_token": frigateConnect.TokenCookie,
+		"camera_name": cameraName,
+		"instance":    frigateConnect.Name,
+		"url":         snapshotURL,
 	}))
 }
 
-// GetCameraStream returns the stream URL for a specific camera
-// GET /api/camera/:name/stream?type=mjpeg
+// GetCameraSnapshotImage streams the latest snapshot of a camera through the server, so the
+// client never needs a Frigate token. Guest sessions may fetch the cameras of their grant.
+// h (height in pixels), quality (1-100) and bbox (draw bounding boxes) are passed to Frigate.
+// GET /api/camera/:name/snapshot.jpg?instance=xxx&h=360&quality=70&bbox=1
+// Requires authentication (JWT token or guest session)
+func GetCameraSnapshotImage(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	params, ok := snapshotParams(ctx)
+	if !ok {
+		return
+	}
+
+	cameraName := ctx.Param("name")
+	frigateConnect, ok := resolveLiveCamera(ctx, db, cameraName)
+	if !ok {
+		return
+	}
+
+	client := services.NewFrigateClientForConnect(db, frigateConnect)
+	snapshot, err := services.FrigateResponses().Snapshot(frigateConnect.ID, cameraName, params, func() ([]byte, string, error) {
+		return client.GetLatestSnapshot(cameraName, frigateConnect.TokenCookie, params)
+	})
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve snapshot from Frigate", err.Error())
+		return
+	}
+
+	// Clients revalidate with If-None-Match or If-Modified-Since and get 304 while it is unchanged
+	ctx.Header("Content-Type", snapshot.ContentType)
+	ctx.Header("Cache-Control", "private, no-cache")
+	ctx.Header("ETag", snapshot.ETag)
+	http.ServeContent(ctx.Writer, ctx.Request, "", snapshot.LastModified, bytes.NewReader(snapshot.Data))
+}
+
+// GetCameraStream returns the URL of the server-side stream proxy for a camera.
+// Clients open it with their own JWT; the Frigate token is never handed out.
+// type is mjpeg (default), hls or mp4.
+// GET /api/camera/:name/stream?type=mjpeg&instance=xxx
 // Requires authentication (JWT token)
 func GetCameraStream(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
@@ -111,7 +285,7 @@ func GetCameraStream(ctx *gin.Context) {
 		return
 	}
 
-	cameraName := ctx.Param("name")
+	cameraName := normalizeCameraName(ctx.Param("name"))
 	if cameraName == "" {
 		utils.RespondWithError(ctx, http.StatusBadRequest, "Camera name is required", nil)
 		return
@@ -123,50 +297,352 @@ func GetCameraStream(ctx *gin.Context) {
 		streamType = "mjpeg"
 	}
 
-	// Currently only support mjpeg
-	if streamType != "mjpeg" {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Only mjpeg stream type is currently supported", nil)
+	streamPath, supported := streamTypes[streamType]
+	if !supported {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Stream type must be mjpeg, hls or mp4", streamType)
 		return
 	}
 
-	// Get user's Frigate configuration
-	var frigateConnect models.FrigateConnect
-	err = db.Where("user_id = ? AND is_active = ?", userID, true).First(&frigateConnect).Error
-	if err != nil {
-		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate configuration not found", nil)
+	// Get user's Frigate configuration (?instance= selects one, default is the first active)
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
 		return
 	}
 
-	// Create Frigate client and get stream URL
-	client := services.NewFrigateClient(frigateConnect.FrigateURL)
-	streamURL := client.GetMJPEGStreamURL(cameraName)
+	if !requireCameraAccess(ctx, db, userID.(uint), frigateConnect, cameraName, models.CameraActionViewLive) {
+		return
+	}
+
+	streamURL := fmt.Sprintf("/api/camera/%s/%s?%s", url.PathEscape(cameraName), streamPath,
+		url.Values{"instance": {frigateConnect.Name}}.Encode())
 
 	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Stream URL retrieved", "", gin.H{
-		"camera_name":   cameraName,
-		"stream_type":   streamType,
-		"url":           streamURL,
-		"frigate_token": frigateConnect.TokenCookie,
+		"camera_name": cameraName,
+		"instance":    frigateConnect.Name,
+		"stream_type": streamType,
+		"url":         streamURL,
 	}))
 }
 
-// normalizeCameraName removes the _WebRTC suffix from camera names
-func normalizeCameraName(name string) string {
-	return strings.TrimSuffix(name, "_WebRTC")
+// snapshotParams validates the snapshot options of the request and converts them to
+// Frigate's latest.jpg parameters. It responds with 400 on invalid values.
+func snapshotParams(ctx *gin.Context) (url.Values, bool) {
+	params := url.Values{}
+
+	if value := ctx.Query("h"); value != "" {
+		height, err := strconv.Atoi(value)
+		if err != nil || height < 1 || height > maxSnapshotHeight {
+			utils.RespondWithError(ctx, http.StatusBadRequest, fmt.Sprintf("h must be between 1 and %d", maxSnapshotHeight), value)
+			return nil, false
+		}
+		params.Set("h", strconv.Itoa(height))
+	}
+
+	if value := ctx.Query("quality"); value != "" {
+		quality, err := strconv.Atoi(value)
+		if err != nil || quality < 1 || quality > 100 {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "quality must be between 1 and 100", value)
+			return nil, false
+		}
+		params.Set("quality", strconv.Itoa(quality))
+	}
+
+	if value := ctx.Query("bbox"); value != "" {
+		bbox, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "bbox must be 0 or 1", value)
+			return nil, false
+		}
+		if bbox {
+			params.Set("bbox", "1")
+		}
+	}
+
+	return params, true
 }
 
-// deduplicateCameraNames extracts camera names from go2rtc streams and removes duplicates
-// e.g., "Tp-Link" and "Tp-Link_WebRTC" are treated as the same camera
-func deduplicateCameraNames(streams types.Go2RTCStreamsResponse) []string {
-	seen := make(map[string]bool)
-	result := []string{}
+// GetCameraMJPEG proxies the MJPEG live stream of a camera to the client. Viewers of the same
+// camera share one upstream connection; a viewer that cannot keep up skips frames.
+// Guest sessions may watch the cameras of their grant.
+// GET /api/camera/:name/mjpeg?instance=xxx
+// Requires authentication (JWT token or guest session)
+func GetCameraMJPEG(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	cameraName := ctx.Param("name")
+	frigateConnect, ok := resolveLiveCamera(ctx, db, cameraName)
+	if !ok {
+		return
+	}
+
+	client := services.NewFrigateClientForConnect(db, frigateConnect)
+	token := frigateConnect.TokenCookie
+	sub, err := services.MJPEGStreams().Subscribe(liveStreamKey(frigateConnect, cameraName), func(streamCtx context.Context) (*http.Response, error) {
+		return client.OpenMJPEGStream(streamCtx, cameraName, token)
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrTooManyStreams) {
+			utils.RespondWithError(ctx, http.StatusServiceUnavailable, "Too many concurrent streams, try again later", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to open stream", err.Error())
+		return
+	}
+	defer sub.Close()
 
-	for name := range streams {
-		normalized := normalizeCameraName(name)
-		if !seen[normalized] {
-			seen[normalized] = true
-			result = append(result, normalized)
+	// Only commit to a streaming response once Frigate delivered a frame
+	var frame []byte
+	select {
+	case frame, ok = <-sub.Frames():
+		if !ok {
+			utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to open stream from Frigate", errorDetails(sub.Err()))
+			return
 		}
+	case <-time.After(mjpegFirstFrameTimeout):
+		utils.RespondWithError(ctx, http.StatusGatewayTimeout, "Frigate did not send a frame in time", nil)
+		return
+	case <-ctx.Request.Context().Done():
+		return
 	}
 
-	return result
+	ctx.Header("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	writer := http.NewResponseController(ctx.Writer)
+	// Access is re-checked periodically, so revoking it also ends streams already running
+	access := liveAccessFromContext(ctx)
+	accessCheck := time.NewTicker(streamAccessCheckInterval)
+	defer accessCheck.Stop()
+
+	for {
+		// A client that stops reading is dropped instead of holding a stream slot forever
+		writer.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err := writeMJPEGFrame(ctx.Writer, frame); err != nil {
+			return
+		}
+		if err := writer.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case frame, ok = <-sub.Frames():
+			if !ok {
+				return
+			}
+		case <-accessCheck.C:
+			if !access.stillValid(db, frigateConnect, cameraName) {
+				return
+			}
+		case <-ctx.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// liveAccess identifies the caller of a long-running live request, so access can still be
+// re-checked when the request context is no longer available
+type liveAccess struct {
+	token      string
+	tokenStore models.TokenStore
+	grant      *models.GuestGrant
+	apiKeyID   uint
+	userID     uint
+}
+
+// liveAccessFromContext captures the caller set by AuthMiddleware or GuestAuthMiddleware
+func liveAccessFromContext(ctx *gin.Context) *liveAccess {
+	access := &liveAccess{
+		token:      ctx.GetString("token"),
+		tokenStore: utils.GetTokenStoreFromContext(ctx),
+		apiKeyID:   ctx.GetUint("api_key_id"),
+		userID:     ctx.GetUint("user_id"),
+	}
+	access.grant, _ = guestGrantFromContext(ctx)
+	return access
+}
+
+// owner identifies the user or guest grant, e.g. to bind signaling sessions to their creator
+func (a *liveAccess) owner() string {
+	if a.grant != nil {
+		return fmt.Sprintf("guest:%d", a.grant.ID)
+	}
+	return fmt.Sprintf("user:%d", a.userID)
+}
+
+// stillValid re-checks the caller. Guests need a usable grant; users need a live session
+// or API key, an active account and the camera permission.
+func (a *liveAccess) stillValid(db *gorm.DB, frigateConnect *models.FrigateConnect, camera string) bool {
+	if a.token != "" {
+		if _, exists := a.tokenStore.Get(a.token); !exists {
+			return false
+		}
+	}
+
+	if a.grant != nil {
+		return guestGrantStillValid(db, a.grant)
+	}
+
+	if a.apiKeyID != 0 {
+		var apiKey models.APIKey
+		if err := db.First(&apiKey, a.apiKeyID).Error; err != nil || !apiKey.IsUsable() {
+			return false
+		}
+	}
+
+	var user models.User
+	if err := db.First(&user, a.userID).Error; err != nil || !user.IsActive {
+		return false
+	}
+
+	if frigateConnect.HouseholdID == nil {
+		return false
+	}
+	owner, err := services.NewCameraDetailService(db).CameraOf(frigateConnect, camera)
+	return err == nil &&
+		services.NewCameraACLService(db).Allowed(*frigateConnect.HouseholdID, a.userID, owner, models.CameraActionViewLive)
+}
+
+// liveStreamKey identifies a camera of an instance, e.g. for shared upstreams and WebRTC sessions
+func liveStreamKey(frigateConnect *models.FrigateConnect, camera string) string {
+	return fmt.Sprintf("%d/%s", frigateConnect.ID, camera)
+}
+
+// writeMJPEGFrame writes one part of a multipart/x-mixed-replace response
+func writeMJPEGFrame(w io.Writer, frame []byte) error {
+	header := fmt.Sprintf("--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(frame))
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// errorDetails returns the message of err for an error response, or nil
+func errorDetails(err error) interface{} {
+	if err == nil {
+		return nil
+	}
+	return err.Error()
+}
+
+// resolveLiveCamera picks the Frigate instance serving camera and checks that the caller may
+// view it live. Guests are limited to the instance and cameras of their grant; users choose
+// the instance with ?instance= and are subject to camera permissions.
+func resolveLiveCamera(ctx *gin.Context, db *gorm.DB, camera string) (*models.FrigateConnect, bool) {
+	if camera == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Camera name is required", nil)
+		return nil, false
+	}
+
+	if grant, isGuest := guestGrantFromContext(ctx); isGuest {
+		frigateConnect, ok := guestFrigateConnect(ctx, db, grant)
+		if !ok {
+			return nil, false
+		}
+		owner, ok := cameraOfStream(ctx, db, frigateConnect, camera)
+		if !ok {
+			return nil, false
+		}
+		if !grant.AllowsCamera(owner) {
+			utils.RespondWithError(ctx, http.StatusForbidden, "Camera access denied", camera)
+			return nil, false
+		}
+		return frigateConnect, true
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return nil, false
+	}
+
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
+		return nil, false
+	}
+	if !requireCameraAccess(ctx, db, userID.(uint), frigateConnect, camera, models.CameraActionViewLive) {
+		return nil, false
+	}
+	return frigateConnect, true
+}
+
+// guestFrigateConnect loads the instance of a guest grant. It responds with 404 if the
+// instance was deactivated or moved to another household.
+func guestFrigateConnect(ctx *gin.Context, db *gorm.DB, grant *models.GuestGrant) (*models.FrigateConnect, bool) {
+	var frigateConnect models.FrigateConnect
+	err := db.Where("id = ? AND household_id = ? AND is_active = ?", grant.FrigateConnectID, grant.HouseholdID, true).
+		First(&frigateConnect).Error
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate instance not found", nil)
+		return nil, false
+	}
+	return &frigateConnect, true
+}
+
+// resolveCameraAction picks the instance selected by ?instance= and checks that the user may
+// perform action on the camera
+func resolveCameraAction(ctx *gin.Context, action string) (*gorm.DB, *models.FrigateConnect, string, bool) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return nil, nil, "", false
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return nil, nil, "", false
+	}
+
+	cameraName := normalizeCameraName(ctx.Param("name"))
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
+		return nil, nil, "", false
+	}
+	if !requireCameraAccess(ctx, db, userID.(uint), frigateConnect, cameraName, action) {
+		return nil, nil, "", false
+	}
+	return db, frigateConnect, cameraName, true
+}
+
+// requireCameraAccess checks the camera permissions of the user in the instance's household.
+// camera may be any go2rtc stream; the permissions of the camera it belongs to apply.
+// It responds with 403 if the action is not allowed.
+func requireCameraAccess(ctx *gin.Context, db *gorm.DB, userID uint, frigateConnect *models.FrigateConnect, camera, action string) bool {
+	owner, ok := cameraOfStream(ctx, db, frigateConnect, camera)
+	if !ok {
+		return false
+	}
+	if frigateConnect.HouseholdID != nil &&
+		services.NewCameraACLService(db).Allowed(*frigateConnect.HouseholdID, userID, owner, action) {
+		return true
+	}
+	utils.RespondWithError(ctx, http.StatusForbidden, "Camera access denied", camera)
+	return false
+}
+
+// cameraOfStream returns the camera a stream name belongs to, grouped like the camera list,
+// so that alternate streams such as front_sub cannot bypass the rules of their camera.
+// It responds with 502 if the streams of the instance cannot be attributed.
+func cameraOfStream(ctx *gin.Context, db *gorm.DB, frigateConnect *models.FrigateConnect, stream string) (string, bool) {
+	camera, err := services.NewCameraDetailService(db).CameraOf(frigateConnect, stream)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve camera config from Frigate", err.Error())
+		return "", false
+	}
+	return camera, true
+}
+
+// normalizeCameraName removes the _WebRTC suffix from camera names
+func normalizeCameraName(name string) string {
+	return strings.TrimSuffix(name, "_WebRTC")
 }
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GroupRequest creates a household group
+type GroupRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+// GroupMemberRequest adds a household member to a group
+type GroupMemberRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// CameraPermissionRequest sets the permission of a user or a group on a camera
+type CameraPermissionRequest struct {
+	Camera     string `json:"camera" binding:"required,max=100"`
+	UserID     *uint  `json:"user_id"`
+	GroupID    *uint  `json:"group_id"`
+	ViewLive   bool   `json:"view_live"`
+	ViewEvents bool   `json:"view_events"`
+	Notify     bool   `json:"notify"`
+	Control    bool   `json:"control"`
+}
+
+// GetHouseholdGroups lists the groups of a household and their members (owners only)
+// GET /api/households/:id/groups
+func GetHouseholdGroups(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	var groups []models.HouseholdGroup
+	if err := db.Where("household_id = ?", membership.HouseholdID).Order("id ASC").Find(&groups).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve groups", err.Error())
+		return
+	}
+
+	response := make([]gin.H, len(groups))
+	for i, group := range groups {
+		var userIDs []uint
+		db.Model(&models.HouseholdGroupMember{}).Where("group_id = ?", group.ID).Order("user_id ASC").Pluck("user_id", &userIDs)
+		response[i] = gin.H{
+			"id":         group.ID,
+			"name":       group.Name,
+			"user_ids":   userIDs,
+			"created_at": group.CreatedAt,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Groups retrieved", "", response))
+}
+
+// CreateHouseholdGroup creates a group in a household (owners only)
+// POST /api/households/:id/groups
+func CreateHouseholdGroup(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req GroupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	var existing models.HouseholdGroup
+	if err := db.Where("household_id = ? AND name = ?", membership.HouseholdID, req.Name).First(&existing).Error; err == nil {
+		utils.RespondWithError(ctx, http.StatusConflict, "Group name already in use", nil)
+		return
+	}
+
+	group := models.HouseholdGroup{HouseholdID: membership.HouseholdID, Name: req.Name}
+	if err := db.Create(&group).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create group", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "Group created", "", group))
+}
+
+// DeleteHouseholdGroup deletes a group and the camera permissions given to it (owners only)
+// DELETE /api/households/:id/groups/:group_id
+func DeleteHouseholdGroup(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	group, ok := findHouseholdGroup(ctx, db, membership.HouseholdID)
+	if !ok {
+		return
+	}
+
+	if err := services.NewCameraACLService(db).DeleteGroup(group); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to delete group", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Group deleted", "", nil))
+}
+
+// AddHouseholdGroupMember adds a household member to a group (owners only)
+// POST /api/households/:id/groups/:group_id/members
+func AddHouseholdGroupMember(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req GroupMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	group, ok := findHouseholdGroup(ctx, db, membership.HouseholdID)
+	if !ok {
+		return
+	}
+
+	if err := services.NewCameraACLService(db).AddGroupMember(group, req.UserID); err != nil {
+		if errors.Is(err, services.ErrNotHouseholdMember) {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "User is not a member of this household", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to add group member", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Group member added", "", nil))
+}
+
+// RemoveHouseholdGroupMember removes a user from a group (owners only)
+// DELETE /api/households/:id/groups/:group_id/members/:user_id
+func RemoveHouseholdGroupMember(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	group, ok := findHouseholdGroup(ctx, db, membership.HouseholdID)
+	if !ok {
+		return
+	}
+
+	result := db.Where("group_id = ? AND user_id = ?", group.ID, ctx.Param("user_id")).Delete(&models.HouseholdGroupMember{})
+	if result.Error != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to remove group member", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Group member not found", nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Group member removed", "", nil))
+}
+
+// GetCameraPermissions lists the camera permissions of a household (owners only)
+// GET /api/households/:id/camera-permissions?camera=xxx
+func GetCameraPermissions(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	query := db.Where("household_id = ?", membership.HouseholdID)
+	if camera := ctx.Query("camera"); camera != "" {
+		query = query.Where("camera = ?", camera)
+	}
+
+	var permissions []models.CameraPermission
+	if err := query.Order("camera ASC, id ASC").Find(&permissions).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve camera permissions", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Camera permissions retrieved", "", permissions))
+}
+
+// SetCameraPermission creates or replaces the permission of a user or group on a camera (owners only).
+// Once a camera has a permission, members it does not cover keep full access; list every
+// group or user that should be restricted.
+// PUT /api/households/:id/camera-permissions
+func SetCameraPermission(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req CameraPermissionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	if (req.UserID == nil) == (req.GroupID == nil) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Exactly one of user_id and group_id is required", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.GroupID != nil {
+		var group models.HouseholdGroup
+		if err := db.Where("id = ? AND household_id = ?", *req.GroupID, membership.HouseholdID).First(&group).Error; err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Group not found in this household", nil)
+			return
+		}
+	}
+
+	permission := models.CameraPermission{
+		HouseholdID: membership.HouseholdID,
+		Camera:      normalizeCameraName(req.Camera),
+		UserID:      req.UserID,
+		GroupID:     req.GroupID,
+		ViewLive:    req.ViewLive,
+		ViewEvents:  req.ViewEvents,
+		Notify:      req.Notify,
+		Control:     req.Control,
+	}
+	if err := services.NewCameraACLService(db).SetPermission(&permission); err != nil {
+		if errors.Is(err, services.ErrNotHouseholdMember) {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "User is not a member of this household", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to save camera permission", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Camera permission saved", "", permission))
+}
+
+// DeleteCameraPermission removes a camera permission (owners only)
+// DELETE /api/households/:id/camera-permissions/:permission_id
+func DeleteCameraPermission(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	permissionID, err := strconv.ParseUint(ctx.Param("permission_id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid permission ID", err.Error())
+		return
+	}
+
+	result := db.Where("id = ? AND household_id = ?", permissionID, membership.HouseholdID).Delete(&models.CameraPermission{})
+	if result.Error != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to delete camera permission", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Camera permission not found", nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Camera permission deleted", "", nil))
+}
+
+// findHouseholdGroup loads the group named by the :group_id path parameter
+func findHouseholdGroup(ctx *gin.Context, db *gorm.DB, householdID uint) (*models.HouseholdGroup, bool) {
+	groupID, err := strconv.ParseUint(ctx.Param("group_id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid group ID", err.Error())
+		return nil, false
+	}
+
+	var group models.HouseholdGroup
+	if err := db.Where("id = ? AND household_id = ?", groupID, householdID).First(&group).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Group not found", nil)
+		return nil, false
+	}
+	return &group, true
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // Frigate may answer with PNG placeholders
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/types"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Mosaic limits and defaults
+const (
+	maxMosaicTiles   = 64
+	maxMosaicColumns = 8
+
+	defaultMosaicTileWidth  = 320
+	defaultMosaicTileHeight = 180
+	minMosaicTileSize       = 64
+	maxMosaicTileWidth      = 1280
+	maxMosaicTileHeight     = 720
+
+	defaultMosaicQuality = 80
+)
+
+// mosaicCamera is a camera to draw into the mosaic
+type mosaicCamera struct {
+	frigateConnect *models.FrigateConnect
+	detail         types.CameraDetail
+	caption        string
+}
+
+// GetCameraMosaic renders the latest snapshot of every accessible camera into one JPEG grid
+// with camera names and online/offline badges, e.g. for wall tablets and Zabbix maps.
+// cameras selects a comma separated subset in the given order; columns (1-8, default is a
+// square grid), w and h (tile size in pixels), captions (default 1) and quality (1-100) set
+// the layout. Without ?instance= (or with instance=all) every active instance is included.
+// Guest sessions get the cameras of their grant.
+// GET /api/cameras/mosaic.jpg?instance=xxx&cameras=front,back&columns=2&w=320&h=180&captions=1&quality=80
+// Requires authentication (JWT token or guest session)
+func GetCameraMosaic(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	layout, quality, ok := mosaicParams(ctx)
+	if !ok {
+		return
+	}
+
+	cameras, ok := mosaicCameras(ctx, db)
+	if !ok {
+		return
+	}
+
+	if subset := ctx.Query("cameras"); subset != "" {
+		cameras = selectMosaicCameras(cameras, strings.Split(subset, ","))
+	}
+	if len(cameras) == 0 {
+		utils.RespondWithError(ctx, http.StatusNotFound, "No cameras to show", nil)
+		return
+	}
+	if len(cameras) > maxMosaicTiles {
+		utils.RespondWithError(ctx, http.StatusBadRequest, fmt.Sprintf("A mosaic can show at most %d cameras", maxMosaicTiles), len(cameras))
+		return
+	}
+
+	if layout.Columns == 0 {
+		layout.Columns = int(math.Ceil(math.Sqrt(float64(len(cameras)))))
+	}
+	if layout.Columns > len(cameras) {
+		layout.Columns = len(cameras)
+	}
+	if !layout.Fits(len(cameras)) {
+		width, height := layout.MosaicSize(len(cameras))
+		utils.RespondWithError(ctx, http.StatusBadRequest, fmt.Sprintf("A mosaic can be at most %dx%d pixels, use fewer cameras or smaller tiles", services.MaxMosaicSize, services.MaxMosaicSize), fmt.Sprintf("%dx%d", width, height))
+		return
+	}
+
+	release, err := services.MosaicRenders().Acquire()
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusServiceUnavailable, "Too many concurrent mosaics, try again later", nil)
+		return
+	}
+	defer release()
+
+	// Frigate scales snapshots to the tile height, so only small images are transferred.
+	// Tokens are read up front since a re-login in one fetch updates the shared instance.
+	params := url.Values{"h": {strconv.Itoa(layout.TileHeight)}}
+	tiles := make([]services.MosaicTile, len(cameras))
+	var wg sync.WaitGroup
+	for i := range cameras {
+		tiles[i] = services.MosaicTile{Caption: cameras[i].caption, Online: cameras[i].detail.Online}
+		token := cameras[i].frigateConnect.TokenCookie
+		wg.Add(1)
+		go func(camera *mosaicCamera, tile *services.MosaicTile) {
+			defer wg.Done()
+			tile.Image = fetchMosaicSnapshot(db, camera, token, params)
+		}(&cameras[i], &tiles[i])
+	}
+	wg.Wait()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, services.RenderMosaic(tiles, layout), &jpeg.Options{Quality: quality}); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to encode mosaic", err.Error())
+		return
+	}
+
+	ctx.Header("Cache-Control", "private, no-cache")
+	ctx.Data(http.StatusOK, "image/jpeg", buf.Bytes())
+}
+
+// mosaicParams validates the layout options of a mosaic request. Columns stays 0 if it was
+// not given. It responds with 400 on invalid values.
+func mosaicParams(ctx *gin.Context) (services.MosaicLayout, int, bool) {
+	layout := services.MosaicLayout{
+		TileWidth:  defaultMosaicTileWidth,
+		TileHeight: defaultMosaicTileHeight,
+		Captions:   true,
+	}
+	quality := defaultMosaicQuality
+
+	intParams := []struct {
+		name     string
+		min, max int
+		target   *int
+	}{
+		{"columns", 1, maxMosaicColumns, &layout.Columns},
+		{"w", minMosaicTileSize, maxMosaicTileWidth, &layout.TileWidth},
+		{"h", minMosaicTileSize, maxMosaicTileHeight, &layout.TileHeight},
+		{"quality", 1, 100, &quality},
+	}
+	for _, param := range intParams {
+		value := ctx.Query(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < param.min || n > param.max {
+			utils.RespondWithError(ctx, http.StatusBadRequest, fmt.Sprintf("%s must be between %d and %d", param.name, param.min, param.max), value)
+			return layout, 0, false
+		}
+		*param.target = n
+	}
+
+	if value := ctx.Query("captions"); value != "" {
+		captions, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "captions must be a boolean", value)
+			return layout, 0, false
+		}
+		layout.Captions = captions
+	}
+	return layout, quality, true
+}
+
+// mosaicCameras lists the cameras the caller may view live, in the order of their instances.
+// Captions carry the instance name when cameras of several instances are shown.
+// It responds with an error if no instance could be reached.
+func mosaicCameras(ctx *gin.Context, db *gorm.DB) ([]mosaicCamera, bool) {
+	detailSvc := services.NewCameraDetailService(db)
+
+	if grant, isGuest := guestGrantFromContext(ctx); isGuest {
+		frigateConnect, ok := guestFrigateConnect(ctx, db, grant)
+		if !ok {
+			return nil, false
+		}
+		details, err := detailSvc.Details(frigateConnect)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve streams from Frigate", err.Error())
+			return nil, false
+		}
+		cameras := []mosaicCamera{}
+		for _, detail := range details {
+			if len(detail.Streams) > 0 && grant.AllowsCamera(detail.Name) {
+				cameras = append(cameras, mosaicCamera{frigateConnect: frigateConnect, detail: detail, caption: detail.Name})
+			}
+		}
+		return cameras, true
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return nil, false
+	}
+
+	instances, ok := requestedInstances(ctx, db, userID.(uint))
+	if !ok {
+		return nil, false
+	}
+
+	aclSvc := services.NewCameraACLService(db)
+	cameras := []mosaicCamera{}
+	failures := []string{}
+	for i := range instances {
+		frigateConnect := &instances[i]
+		details, err := detailSvc.Details(frigateConnect)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", frigateConnect.Name, err))
+			continue
+		}
+
+		access, err := aclSvc.Access(*frigateConnect.HouseholdID, userID.(uint))
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to load camera permissions", err.Error())
+			return nil, false
+		}
+		for _, detail := range details {
+			if len(detail.Streams) == 0 || !access.Allowed(detail.Name, models.CameraActionViewLive) {
+				continue
+			}
+			caption := detail.Name
+			if len(instances) > 1 {
+				caption = frigateConnect.Name + "/" + detail.Name
+			}
+			cameras = append(cameras, mosaicCamera{frigateConnect: frigateConnect, detail: detail, caption: caption})
+		}
+	}
+
+	// Only fail when no instance could be reached
+	if len(failures) == len(instances) {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve streams from Frigate", failures)
+		return nil, false
+	}
+	return cameras, true
+}
+
+// selectMosaicCameras keeps the named cameras in the order given. A camera present on several
+// instances is kept for each of them.
+func selectMosaicCameras(cameras []mosaicCamera, names []string) []mosaicCamera {
+	selected := []mosaicCamera{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = normalizeCameraName(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		for _, camera := range cameras {
+			if camera.detail.Name == name {
+				selected = append(selected, camera)
+			}
+		}
+	}
+	return selected
+}
+
+// fetchMosaicSnapshot loads and decodes the latest snapshot of a camera through the shared
+// snapshot cache. It returns nil if the snapshot is unavailable, so the tile shows a placeholder.
+func fetchMosaicSnapshot(db *gorm.DB, camera *mosaicCamera, token string, params url.Values) image.Image {
+	frigateConnect := camera.frigateConnect
+	name := camera.detail.Name
+	client := services.NewFrigateClientForConnect(db, frigateConnect)
+	snapshot, err := services.FrigateResponses().Snapshot(frigateConnect.ID, name, params, func() ([]byte, string, error) {
+		return client.GetLatestSnapshot(name, token, params)
+	})
+	if err != nil {
+		return nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(snapshot.Data))
+	if err != nil {
+		return nil
+	}
+	return img
+}
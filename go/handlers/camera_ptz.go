@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/types"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PTZDirectionRequest starts a continuous move or zoom
+type PTZDirectionRequest struct {
+	Direction string `json:"direction" binding:"required"`
+}
+
+// PTZPresetRequest moves a camera to a preset
+type PTZPresetRequest struct {
+	Preset string `json:"preset" binding:"required,max=100"`
+}
+
+// GetCameraPTZ retrieves the PTZ features and presets of a camera, and whether the user may
+// control it
+// GET /api/camera/:name/ptz?instance=xxx
+// Requires authentication (JWT token)
+func GetCameraPTZ(ctx *gin.Context) {
+	db, frigateConnect, cameraName, ok := resolveCameraAction(ctx, models.CameraActionViewLive)
+	if !ok {
+		return
+	}
+
+	info, ok := fetchPTZInfo(ctx, db, frigateConnect, cameraName)
+	if !ok {
+		return
+	}
+
+	canControl := services.NewCameraACLService(db).Allowed(*frigateConnect.HouseholdID, ctx.GetUint("user_id"), cameraName, models.CameraActionControl)
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "PTZ info retrieved", "", gin.H{
+		"camera_name": cameraName,
+		"instance":    frigateConnect.Name,
+		"features":    nonNilStrings(info.Features),
+		"presets":     nonNilStrings(info.Presets),
+		"can_control": canControl,
+	}))
+}
+
+// GetCameraPTZPresets lists the ONVIF presets of a camera
+// GET /api/camera/:name/ptz/presets?instance=xxx
+// Requires authentication (JWT token)
+func GetCameraPTZPresets(ctx *gin.Context) {
+	db, frigateConnect, cameraName, ok := resolveCameraAction(ctx, models.CameraActionViewLive)
+	if !ok {
+		return
+	}
+
+	info, ok := fetchPTZInfo(ctx, db, frigateConnect, cameraName)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "PTZ presets retrieved", "", gin.H{
+		"camera_name": cameraName,
+		"instance":    frigateConnect.Name,
+		"presets":     nonNilStrings(info.Presets),
+	}))
+}
+
+// MoveCameraPTZ starts moving a camera left, right, up or down until StopCameraPTZ is called
+// POST /api/camera/:name/ptz/move?instance=xxx {"direction": "left"}
+// Requires authentication (JWT token) and the camera's control permission
+func MoveCameraPTZ(ctx *gin.Context) {
+	var req PTZDirectionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	command, err := services.PTZMove(req.Direction)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid PTZ command", err.Error())
+		return
+	}
+	sendPTZCommand(ctx, command)
+}
+
+// ZoomCameraPTZ starts zooming a camera in or out until StopCameraPTZ is called
+// POST /api/camera/:name/ptz/zoom?instance=xxx {"direction": "in"}
+// Requires authentication (JWT token) and the camera's control permission
+func ZoomCameraPTZ(ctx *gin.Context) {
+	var req PTZDirectionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	command, err := services.PTZZoom(req.Direction)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid PTZ command", err.Error())
+		return
+	}
+	sendPTZCommand(ctx, command)
+}
+
+// StopCameraPTZ stops any movement and zoom of a camera
+// POST /api/camera/:name/ptz/stop?instance=xxx
+// Requires authentication (JWT token) and the camera's control permission
+func StopCameraPTZ(ctx *gin.Context) {
+	sendPTZCommand(ctx, services.PTZStop())
+}
+
+// GoToCameraPTZPreset moves a camera to one of its presets
+// POST /api/camera/:name/ptz/preset?instance=xxx {"preset": "driveway"}
+// Requires authentication (JWT token) and the camera's control permission
+func GoToCameraPTZPreset(ctx *gin.Context) {
+	var req PTZPresetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	command, err := services.PTZPreset(req.Preset)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid PTZ command", err.Error())
+		return
+	}
+	sendPTZCommand(ctx, command)
+}
+
+// sendPTZCommand checks the control permission, the instance's topic prefix and the camera's
+// PTZ features, then publishes the command to Frigate over MQTT
+func sendPTZCommand(ctx *gin.Context, command services.PTZCommand) {
+	db, frigateConnect, cameraName, ok := resolveCameraAction(ctx, models.CameraActionControl)
+	if !ok {
+		return
+	}
+	if !requirePublishablePrefix(ctx, db, frigateConnect) {
+		return
+	}
+
+	client := getMQTTClient()
+	if !client.IsConnected() {
+		utils.RespondWithError(ctx, http.StatusServiceUnavailable, "MQTT client is not connected", nil)
+		return
+	}
+
+	// STOP is always sent, so a camera can be stopped even if Frigate's API is unreachable
+	if command.Feature != "" || command.Preset != "" {
+		info, ok := fetchPTZInfo(ctx, db, frigateConnect, cameraName)
+		if !ok {
+			return
+		}
+		if err := command.Check(info); err != nil {
+			if errors.Is(err, services.ErrPTZUnsupported) {
+				utils.RespondWithError(ctx, http.StatusConflict, "Camera does not support this PTZ command", command.Payload)
+				return
+			}
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid PTZ command", err.Error())
+			return
+		}
+	}
+
+	if err := services.SendPTZ(client, frigateConnect, cameraName, command); err != nil {
+		if errors.Is(err, services.ErrMQTTNotConnected) {
+			utils.RespondWithError(ctx, http.StatusServiceUnavailable, "MQTT client is not connected", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to send PTZ command", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "PTZ command sent", "", gin.H{
+		"camera_name": cameraName,
+		"instance":    frigateConnect.Name,
+		"command":     command.Payload,
+	}))
+}
+
+// fetchPTZInfo loads the PTZ features and presets of a camera from Frigate.
+// It responds with 502 if Frigate cannot be reached.
+func fetchPTZInfo(ctx *gin.Context, db *gorm.DB, frigateConnect *models.FrigateConnect, camera string) (*types.PTZInfo, bool) {
+	info, err := services.NewFrigateClientForConnect(db, frigateConnect).GetPTZInfo(camera, frigateConnect.TokenCookie)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve PTZ info from Frigate", err.Error())
+		return nil, false
+	}
+	return info, true
+}
+
+// nonNilStrings returns values, or an empty slice so it is encoded as [] instead of null
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
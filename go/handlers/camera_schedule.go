@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limits of the upcoming transitions listing and of temporary overrides
+const (
+	defaultUpcomingHours = 24
+	maxUpcomingHours     = 7 * 24
+	maxScheduleOverride  = 7 * 24 * time.Hour
+)
+
+// CameraScheduleRequest creates or replaces a camera schedule
+type CameraScheduleRequest struct {
+	Instance string   `json:"instance"` // Instance ID or name, defaults to the first active instance
+	Camera   string   `json:"camera" binding:"required,max=100"`
+	Switch   string   `json:"switch" binding:"required"`
+	State    *bool    `json:"state" binding:"required"` // Switch state inside the window
+	Days     []string `json:"days"`                     // e.g. ["mon", "fri"], empty for every day
+	Start    string   `json:"start" binding:"required"` // "HH:MM", "sunrise", "sunset-30m"
+	End      string   `json:"end" binding:"required"`
+	Enabled  *bool    `json:"enabled"` // Defaults to true
+}
+
+// ScheduleOverrideRequest holds the switch of a schedule at a state until a time
+type ScheduleOverrideRequest struct {
+	State *bool     `json:"state" binding:"required"`
+	Until time.Time `json:"until" binding:"required"`
+}
+
+// GetCameraSchedules lists the schedules of cameras the user may view, optionally
+// filtered by ?instance= and ?camera=
+// GET /api/schedules?instance=xxx&camera=xxx
+// Requires authentication (JWT token)
+func GetCameraSchedules(ctx *gin.Context) {
+	db, userID, ok := scheduleContext(ctx)
+	if !ok {
+		return
+	}
+
+	schedules, ok := visibleSchedules(ctx, db, userID)
+	if !ok {
+		return
+	}
+
+	response := make([]gin.H, len(schedules))
+	for i := range schedules {
+		response[i] = cameraScheduleResponse(&schedules[i])
+	}
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Schedules retrieved", "", gin.H{
+		"schedules": response,
+		"location":  services.ScheduleLocationFromEnv(),
+	}))
+}
+
+// GetUpcomingScheduleTransitions lists the switch changes planned in the next hours
+// (default 24, at most 168) for the schedules the user may view
+// GET /api/schedules/upcoming?hours=24&instance=xxx&camera=xxx
+// Requires authentication (JWT token)
+func GetUpcomingScheduleTransitions(ctx *gin.Context) {
+	db, userID, ok := scheduleContext(ctx)
+	if !ok {
+		return
+	}
+
+	hours := defaultUpcomingHours
+	if value := ctx.Query("hours"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxUpcomingHours {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "hours must be between 1 and 168", value)
+			return
+		}
+		hours = n
+	}
+
+	schedules, ok := visibleSchedules(ctx, db, userID)
+	if !ok {
+		return
+	}
+
+	location := services.ScheduleLocationFromEnv()
+	now := time.Now()
+	transitions := []services.ScheduleTransition{}
+	for i := range schedules {
+		if !schedules[i].Enabled {
+			continue
+		}
+		upcoming, err := services.ScheduleTransitions(&schedules[i], now, now.Add(time.Duration(hours)*time.Hour), location)
+		if err != nil {
+			continue
+		}
+		transitions = append(transitions, upcoming...)
+	}
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Upcoming transitions retrieved", "", transitions))
+}
+
+// CreateCameraSchedule adds a schedule to a camera
+// POST /api/schedules
+// Requires authentication (JWT token) and the camera's control permission
+func CreateCameraSchedule(ctx *gin.Context) {
+	db, userID, ok := scheduleContext(ctx)
+	if !ok {
+		return
+	}
+
+	var req CameraScheduleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	frigateConnect, err := findFrigateConnect(db, userID, req.Instance, true)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate instance not found", req.Instance)
+		return
+	}
+
+	schedule := models.CameraSchedule{CreatedBy: userID}
+	if !applyScheduleRequest(ctx, db, userID, &schedule, frigateConnect, &req) {
+		return
+	}
+	if err := db.Create(&schedule).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create schedule", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "Schedule created", "", cameraScheduleResponse(&schedule)))
+}
+
+// UpdateCameraSchedule replaces a schedule. An active override is kept.
+// PUT /api/schedules/:id
+// Requires authentication (JWT token) and the camera's control permission
+func UpdateCameraSchedule(ctx *gin.Context) {
+	db, userID, ok := scheduleContext(ctx)
+	if !ok {
+		return
+	}
+
+	var req CameraScheduleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	schedule, ok := findCameraSchedule(ctx, db, userID, models.CameraActionControl)
+	if !ok {
+		return
+	}
+
+	// The schedule stays on its instance unless another one is given
+	identifier := req.Instance
+	if identifier == "" {
+		identifier = strconv.FormatUint(uint64(schedule.FrigateConnectID), 10)
+	}
+	frigateConnect, err := findFrigateConnect(db, userID, identifier, true)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate instance not found", req.Instance)
+		return
+	}
+
+	if !applyScheduleRequest(ctx, db, userID, schedule, frigateConnect, &req) {
+		return
+	}
+	if err := db.Save(schedule).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to update schedule", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Schedule updated", "", cameraScheduleResponse(schedule)))
+}
+
+// DeleteCameraSchedule removes a schedule. The camera keeps its current switch states.
+// DELETE /api/schedules/:id
+// Requires authentication (JWT token) and the camera's control permission
+func DeleteCameraSchedule(ctx *gin.Context) {
+	db, userID, ok := scheduleContext(ctx)
+	if !ok {
+		return
+	}
+
+	schedule, ok := findCameraSchedule(ctx, db, userID, models.CameraActionControl)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(schedule).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to delete schedule", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Schedule deleted", "", nil))
+}
+
+// SetCameraScheduleOverride holds the switch of a schedule at a state until a time, e.g.
+// detection on while the family is away over a holiday. The state is published right away;
+// transitions before until are skipped.
+// PUT /api/schedules/:id/override {"state": true, "until": "2026-10-20T18:00:00+09:00"}
+// Requires authentication (JWT token) and the camera's control permission
+func SetCameraScheduleOverride(ctx *gin.Context) {
+	db, userID, ok := scheduleContext(ctx)
+	if !ok {
+		return
+	}
+
+	var req ScheduleOverrideRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	now := time.Now()
+	if !req.Until.After(now) || req.Until.Sub(now) > maxScheduleOverride {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "until must be in the future and at most 7 days ahead", nil)
+		return
+	}
+
+	schedule, ok := findCameraSchedule(ctx, db, userID, models.CameraActionControl)
+	if !ok {
+		return
+	}
+
+	if err := services.NewCameraScheduler(db, getMQTTClient()).SetOverride(schedule, *req.State, req.Until); err != nil {
+		respondScheduleError(ctx, "Failed to set override", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Override set", "", cameraScheduleResponse(schedule)))
+}
+
+// ClearCameraScheduleOverride ends the override of a schedule and restores the state the
+// schedule gives the switch now
+// DELETE /api/schedules/:id/override
+// Requires authentication (JWT token) and the camera's control permission
+func ClearCameraScheduleOverride(ctx *gin.Context) {
+	db, userID, ok := scheduleContext(ctx)
+	if !ok {
+		return
+	}
+
+	schedule, ok := findCameraSchedule(ctx, db, userID, models.CameraActionControl)
+	if !ok {
+		return
+	}
+	if schedule.OverrideUntil == nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Schedule has no override", nil)
+		return
+	}
+
+	if err := services.NewCameraScheduler(db, getMQTTClient()).ClearOverride(schedule, time.Now()); err != nil {
+		respondScheduleError(ctx, "Failed to clear override", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Override cleared", "", cameraScheduleResponse(schedule)))
+}
+
+// scheduleContext returns the database and the authenticated user
+func scheduleContext(ctx *gin.Context) (*gorm.DB, uint, bool) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return nil, 0, false
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return nil, 0, false
+	}
+	return db, userID.(uint), true
+}
+
+// applyScheduleRequest validates req and copies it into schedule. It responds with 400 on
+// invalid values and 403 without the control permission on the camera.
+func applyScheduleRequest(ctx *gin.Context, db *gorm.DB, userID uint, schedule *models.CameraSchedule, frigateConnect *models.FrigateConnect, req *CameraScheduleRequest) bool {
+	days := []string{}
+	seen := make(map[string]bool)
+	for _, day := range req.Days {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+
+	schedule.HouseholdID = *frigateConnect.HouseholdID
+	schedule.FrigateConnectID = frigateConnect.ID
+	schedule.Camera = normalizeCameraName(req.Camera)
+	schedule.Switch = req.Switch
+	schedule.State = *req.State
+	schedule.Days = strings.Join(days, ",")
+	schedule.Start = strings.ToLower(strings.TrimSpace(req.Start))
+	schedule.End = strings.ToLower(strings.TrimSpace(req.End))
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+	if len(days) == 7 {
+		schedule.Days = ""
+	}
+
+	if err := services.ValidateCameraSchedule(schedule, services.ScheduleLocationFromEnv()); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid schedule", err.Error())
+		return false
+	}
+	return requireCameraAccess(ctx, db, userID, frigateConnect, schedule.Camera, models.CameraActionControl)
+}
+
+// visibleSchedules loads the schedules of the user's households on cameras they may view,
+// filtered by ?instance= and ?camera=
+func visibleSchedules(ctx *gin.Context, db *gorm.DB, userID uint) ([]models.CameraSchedule, bool) {
+	query := db.Where("household_id IN (?)", services.NewHouseholdService(db).MemberHouseholdIDs(userID))
+	if identifier := ctx.Query("instance"); identifier != "" && identifier != allInstances {
+		frigateConnect, ok := resolveFrigateConnect(ctx, db, userID)
+		if !ok {
+			return nil, false
+		}
+		query = query.Where("frigate_connect_id = ?", frigateConnect.ID)
+	}
+	if camera := ctx.Query("camera"); camera != "" {
+		query = query.Where("camera = ?", normalizeCameraName(camera))
+	}
+
+	var schedules []models.CameraSchedule
+	if err := query.Order("camera ASC, id ASC").Find(&schedules).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve schedules", err.Error())
+		return nil, false
+	}
+
+	aclSvc := services.NewCameraACLService(db)
+	access := make(map[uint]*services.CameraAccess)
+	visible := []models.CameraSchedule{}
+	for _, schedule := range schedules {
+		if _, loaded := access[schedule.HouseholdID]; !loaded {
+			householdAccess, err := aclSvc.Access(schedule.HouseholdID, userID)
+			if err != nil {
+				utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to load camera permissions", err.Error())
+				return nil, false
+			}
+			access[schedule.HouseholdID] = householdAccess
+		}
+		if access[schedule.HouseholdID].Allowed(schedule.Camera, models.CameraActionViewLive) {
+			visible = append(visible, schedule)
+		}
+	}
+	return visible, true
+}
+
+// findCameraSchedule loads the schedule named by the :id path parameter and checks that
+// the user may perform action on its camera. It responds with 404 or 403 on failure.
+func findCameraSchedule(ctx *gin.Context, db *gorm.DB, userID uint, action string) (*models.CameraSchedule, bool) {
+	scheduleID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid schedule ID", err.Error())
+		return nil, false
+	}
+
+	var schedule models.CameraSchedule
+	err = db.Where("id = ? AND household_id IN (?)", scheduleID, services.NewHouseholdService(db).MemberHouseholdIDs(userID)).
+		First(&schedule).Error
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Schedule not found", nil)
+		return nil, false
+	}
+
+	if !services.NewCameraACLService(db).Allowed(schedule.HouseholdID, userID, schedule.Camera, action) {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Camera access denied", schedule.Camera)
+		return nil, false
+	}
+	return &schedule, true
+}
+
+// respondScheduleError maps errors of publishing a schedule state to a response
+func respondScheduleError(ctx *gin.Context, message string, err error) {
+	if errors.Is(err, services.ErrMQTTNotConnected) {
+		utils.RespondWithError(ctx, http.StatusServiceUnavailable, "MQTT client is not connected", nil)
+		return
+	}
+	if errors.Is(err, services.ErrTopicPrefixNotRoutable) {
+		utils.RespondWithError(ctx, http.StatusConflict, "MQTT topic prefix of this Frigate instance is unset or shared with another household, set a unique mqtt_topic_prefix", nil)
+		return
+	}
+	utils.RespondWithError(ctx, http.StatusBadGateway, message, err.Error())
+}
+
+func cameraScheduleResponse(schedule *models.CameraSchedule) gin.H {
+	return gin.H{
+		"id":                 schedule.ID,
+		"frigate_connect_id": schedule.FrigateConnectID,
+		"camera":             schedule.Camera,
+		"switch":             schedule.Switch,
+		"state":              schedule.State,
+		"days":               schedule.DayList(),
+		"start":              schedule.Start,
+		"end":                schedule.End,
+		"enabled":            schedule.Enabled,
+		"override_state":     schedule.OverrideState,
+		"override_until":     schedule.OverrideUntil,
+		"created_by":         schedule.CreatedBy,
+		"created_at":         schedule.CreatedAt,
+		"updated_at":         schedule.UpdatedAt,
+	}
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// hlsPlaylistContentType is the media type of HLS playlists
+const hlsPlaylistContentType = "application/vnd.apple.mpegurl"
+
+// streamTypes maps the stream types of GetCameraStream to the path of their proxy endpoint
+var streamTypes = map[string]string{
+	"mjpeg": "mjpeg",
+	"hls":   "stream.m3u8",
+	"mp4":   "stream.mp4",
+}
+
+// GetCameraHLSPlaylist proxies the go2rtc HLS master playlist of a camera. Playlist URIs are
+// rewritten to GetCameraHLSFile so that segments are also fetched through the server.
+// Guest sessions may watch the cameras of their grant.
+// GET /api/camera/:name/stream.m3u8?instance=xxx
+// Requires authentication (JWT token or guest session)
+func GetCameraHLSPlaylist(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	cameraName := ctx.Param("name")
+	frigateConnect, ok := resolveLiveCamera(ctx, db, cameraName)
+	if !ok {
+		return
+	}
+
+	serveHLSPlaylist(ctx, db, frigateConnect, cameraName, "stream.m3u8", url.Values{"src": {cameraName}})
+}
+
+// GetCameraHLSFile proxies a playlist or segment that a rewritten HLS playlist refers to.
+// GET /api/camera/:name/hls/:file?id=xxx&instance=xxx
+// Requires authentication (JWT token or guest session)
+func GetCameraHLSFile(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	file := ctx.Param("file")
+	if !services.IsHLSFileName(file) {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Unknown HLS file", file)
+		return
+	}
+
+	cameraName := ctx.Param("name")
+	frigateConnect, ok := resolveLiveCamera(ctx, db, cameraName)
+	if !ok {
+		return
+	}
+
+	// Everything but our own instance parameter belongs to go2rtc, e.g. the session id
+	params := ctx.Request.URL.Query()
+	params.Del("instance")
+
+	if path.Ext(file) == ".m3u8" {
+		serveHLSPlaylist(ctx, db, frigateConnect, cameraName, "hls/"+file, params)
+		return
+	}
+
+	client := services.NewFrigateClientForConnect(db, frigateConnect)
+	data, contentType, err := client.GetGo2RTCFile(ctx.Request.Context(), "hls/"+file, params, frigateConnect.TokenCookie)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve HLS segment from Frigate", err.Error())
+		return
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// serveHLSPlaylist fetches a go2rtc playlist and responds with its URIs pointing at the proxy
+func serveHLSPlaylist(ctx *gin.Context, db *gorm.DB, frigateConnect *models.FrigateConnect, camera, apiPath string, params url.Values) {
+	client := services.NewFrigateClientForConnect(db, frigateConnect)
+	playlist, _, err := client.GetGo2RTCFile(ctx.Request.Context(), apiPath, params, frigateConnect.TokenCookie)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to retrieve HLS playlist from Frigate", err.Error())
+		return
+	}
+
+	playlistURL, err := url.Parse(client.GetGo2RTCAPIURL(apiPath, params))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Invalid Frigate URL", err.Error())
+		return
+	}
+	hlsBase, err := url.Parse(client.GetGo2RTCHLSBase())
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Invalid Frigate URL", err.Error())
+		return
+	}
+
+	rewriter := &services.HLSRewriter{
+		HLSBase:     hlsBase,
+		ProxyPrefix: fmt.Sprintf("/api/camera/%s/hls/", url.PathEscape(camera)),
+		Query:       url.Values{"instance": {frigateConnect.Name}},
+	}
+	rewritten, err := rewriter.Rewrite(playlist, playlistURL)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Unexpected HLS playlist from Frigate", err.Error())
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(http.StatusOK, hlsPlaylistContentType, rewritten)
+}
+
+// GetCameraMP4 proxies the fragmented MP4 live stream of a camera from go2rtc.
+// Each viewer has its own upstream connection; GO2RTC_MAX_STREAMS caps them.
+// GET /api/camera/:name/stream.mp4?instance=xxx
+// Requires authentication (JWT token or guest session)
+func GetCameraMP4(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	cameraName := ctx.Param("name")
+	frigateConnect, ok := resolveLiveCamera(ctx, db, cameraName)
+	if !ok {
+		return
+	}
+
+	release, err := services.Go2RTCStreams().Acquire()
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusServiceUnavailable, "Too many concurrent streams, try again later", nil)
+		return
+	}
+	defer release()
+
+	streamCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+
+	client := services.NewFrigateClientForConnect(db, frigateConnect)
+	resp, err := client.OpenGo2RTCStream(streamCtx, "stream.mp4", url.Values{"src": {cameraName}}, frigateConnect.TokenCookie)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to open stream from Frigate", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "video/mp4"
+	}
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	// Revoking access ends the upstream, which ends the copy below
+	access := liveAccessFromContext(ctx)
+	go func() {
+		ticker := time.NewTicker(streamAccessCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !access.stillValid(db, frigateConnect, cameraName) {
+					cancel()
+					return
+				}
+			case <-streamCtx.Done():
+				return
+			}
+		}
+	}()
+
+	relayStream(ctx, resp.Body)
+}
+
+// relayStream copies a continuous upstream body to the client, flushing every chunk.
+// A client that stops reading is dropped instead of holding a stream slot forever.
+func relayStream(ctx *gin.Context, body io.Reader) {
+	writer := http.NewResponseController(ctx.Writer)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			writer.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, writeErr := ctx.Writer.Write(buf[:n]); writeErr != nil {
+				return
+			}
+			if flushErr := writer.Flush(); flushErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// maxSDPSize bounds SDP offers and trickle-ICE fragments sent by clients
+const maxSDPSize = 64 << 10
+
+// Media types of WHEP signaling
+const (
+	sdpContentType        = "application/sdp"
+	trickleICEContentType = "application/trickle-ice-sdpfrag"
+)
+
+// WebRTCOfferRequest is the JSON form of a WebRTC offer; WHEP clients post the SDP as application/sdp
+type WebRTCOfferRequest struct {
+	Type string `json:"type" binding:"required,eq=offer"`
+	SDP  string `json:"sdp" binding:"required"`
+}
+
+// StartCameraWebRTC relays a WebRTC offer to go2rtc with the stored Frigate token and returns
+// the answer. Media then flows peer-to-peer between the client and go2rtc. Clients trickle
+// ICE candidates with PATCH and end the session with DELETE on the returned session URL.
+// Guest sessions may watch the cameras of their grant.
+// POST /api/camera/:name/webrtc?instance=xxx
+// Requires authentication (JWT token or guest session)
+func StartCameraWebRTC(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	cameraName := ctx.Param("name")
+	frigateConnect, ok := resolveLiveCamera(ctx, db, cameraName)
+	if !ok {
+		return
+	}
+
+	whep := isContentType(ctx, sdpContentType)
+	var offer string
+	if whep {
+		body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxSDPSize+1))
+		if err != nil || len(body) == 0 || len(body) > maxSDPSize {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid SDP offer", nil)
+			return
+		}
+		offer = string(body)
+	} else {
+		var req WebRTCOfferRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+			return
+		}
+		if len(req.SDP) > maxSDPSize {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid SDP offer", nil)
+			return
+		}
+		offer = req.SDP
+	}
+
+	access := liveAccessFromContext(ctx)
+	client := services.NewFrigateClientForConnect(db, frigateConnect)
+	token := frigateConnect.TokenCookie
+	session, answer, err := services.WebRTCSignaling().Start(ctx.Request.Context(), access.owner(), liveStreamKey(frigateConnect, cameraName), offer,
+		func(dialCtx context.Context) (*websocket.Conn, error) {
+			return client.DialGo2RTCWebSocket(dialCtx, cameraName, token)
+		},
+		func() bool {
+			return access.stillValid(db, frigateConnect, cameraName)
+		})
+	if err != nil {
+		if errors.Is(err, services.ErrTooManyStreams) {
+			utils.RespondWithError(ctx, http.StatusServiceUnavailable, "Too many concurrent streams, try again later", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to negotiate WebRTC with Frigate", err.Error())
+		return
+	}
+
+	sessionURL := fmt.Sprintf("/api/camera/%s/webrtc/%s?%s", url.PathEscape(cameraName), session.ID,
+		url.Values{"instance": {frigateConnect.Name}}.Encode())
+	ctx.Header("Location", sessionURL)
+
+	if whep {
+		ctx.Data(http.StatusCreated, sdpContentType, []byte(answer))
+		return
+	}
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "WebRTC session started", "", gin.H{
+		"session_id": session.ID,
+		"type":       "answer",
+		"sdp":        answer,
+		"url":        sessionURL,
+	}))
+}
+
+// TrickleCameraWebRTC forwards the client's ICE candidates (application/trickle-ice-sdpfrag)
+// to go2rtc. The response carries the candidates go2rtc gathered since the last call,
+// or is empty with 204; clients may send an empty fragment to poll for them.
+// PATCH /api/camera/:name/webrtc/:session?instance=xxx
+// Requires authentication (JWT token or guest session)
+func TrickleCameraWebRTC(ctx *gin.Context) {
+	session, ok := resolveWebRTCSession(ctx)
+	if !ok {
+		return
+	}
+
+	if ctx.Request.ContentLength != 0 && !isContentType(ctx, trickleICEContentType) {
+		utils.RespondWithError(ctx, http.StatusUnsupportedMediaType, "Expected "+trickleICEContentType, nil)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxSDPSize+1))
+	if err != nil || len(body) > maxSDPSize {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid SDP fragment", nil)
+		return
+	}
+
+	candidates, err := session.AddCandidates(string(body))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to forward ICE candidates to Frigate", err.Error())
+		return
+	}
+	if candidates == "" {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	ctx.Data(http.StatusOK, trickleICEContentType, []byte(candidates))
+}
+
+// StopCameraWebRTC ends a WebRTC session and its peer connection
+// DELETE /api/camera/:name/webrtc/:session?instance=xxx
+// Requires authentication (JWT token or guest session)
+func StopCameraWebRTC(ctx *gin.Context) {
+	session, ok := resolveWebRTCSession(ctx)
+	if !ok {
+		return
+	}
+
+	services.WebRTCSignaling().End(session.ID, session.Owner, session.Key)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "WebRTC session ended", "", nil))
+}
+
+// resolveWebRTCSession finds the caller's WebRTC session of the camera in the URL
+func resolveWebRTCSession(ctx *gin.Context) (*services.WebRTCSession, bool) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return nil, false
+	}
+
+	cameraName := ctx.Param("name")
+	frigateConnect, ok := resolveLiveCamera(ctx, db, cameraName)
+	if !ok {
+		return nil, false
+	}
+
+	access := liveAccessFromContext(ctx)
+	session, err := services.WebRTCSignaling().Get(ctx.Param("session"), access.owner(), liveStreamKey(frigateConnect, cameraName))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "WebRTC session not found", nil)
+		return nil, false
+	}
+	return session, true
+}
+
+// isContentType reports whether the request body has the given media type
+func isContentType(ctx *gin.Context, mediaType string) bool {
+	contentType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	return contentType == mediaType
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CameraSwitchRequest turns a camera switch on or off
+type CameraSwitchRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// GetCameraSwitches retrieves the detect, recordings, snapshots, motion and notifications switches of a
+// camera as last reported by Frigate over MQTT. Switches not reported yet are null.
+// GET /api/camera/:name/switches?instance=xxx
+// Requires authentication (JWT token)
+func GetCameraSwitches(ctx *gin.Context) {
+	_, frigateConnect, cameraName, ok := resolveCameraAction(ctx, models.CameraActionViewLive)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Camera switches retrieved", "", gin.H{
+		"camera_name":    cameraName,
+		"instance":       frigateConnect.Name,
+		"switches":       services.CameraSwitches().States(frigateConnect.MQTTTopicPrefix, cameraName),
+		"mqtt_connected": getMQTTClient().IsConnected(),
+	}))
+}
+
+// SetCameraSwitch asks Frigate to turn a switch of a camera on or off. Frigate confirms the
+// change on the switch's /state topic, after which GetCameraSwitches returns it. Nothing is
+// published under a topic prefix that another household also uses.
+// PUT /api/camera/:name/switches/:switch?instance=xxx {"enabled": false}
+// Requires authentication (JWT token) and the camera's control permission
+func SetCameraSwitch(ctx *gin.Context) {
+	name := ctx.Param("switch")
+	if !services.IsValidCameraSwitch(name) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Switch must be detect, recordings, snapshots, motion or notifications", name)
+		return
+	}
+
+	var req CameraSwitchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	db, frigateConnect, cameraName, ok := resolveCameraAction(ctx, models.CameraActionControl)
+	if !ok {
+		return
+	}
+	if !requirePublishablePrefix(ctx, db, frigateConnect) {
+		return
+	}
+
+	if err := services.SetCameraSwitch(getMQTTClient(), frigateConnect, cameraName, name, *req.Enabled); err != nil {
+		if errors.Is(err, services.ErrMQTTNotConnected) {
+			utils.RespondWithError(ctx, http.StatusServiceUnavailable, "MQTT client is not connected", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Failed to change camera switch", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, utils.JsonResponse("success", http.StatusAccepted, "Camera switch change requested", "", gin.H{
+		"camera_name": cameraName,
+		"instance":    frigateConnect.Name,
+		"switch":      name,
+		"enabled":     *req.Enabled,
+	}))
+}
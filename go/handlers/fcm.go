@@ -94,6 +94,7 @@ func RegisterFCMToken(ctx *gin.Context) {
 		if req.DeviceName != "" && req.DeviceName != existingToken.DeviceName {
 			existingToken.DeviceName = req.DeviceName
 		}
+		existingToken.FamilyID = ctx.GetString("family_id")
 		db.Save(&existingToken)
 
 		log.Printf("[FCM] Token reactivated for user %v, device: %s", userID, existingToken.DeviceName)
@@ -112,6 +113,7 @@ func RegisterFCMToken(ctx *gin.Context) {
 		Token:      req.Token,
 		DeviceName: req.DeviceName,
 		IsActive:   true,
+		FamilyID:   ctx.GetString("family_id"),
 	}
 
 	if err := db.Create(&fcmToken).Error; err != nil {
@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateFrigateInstanceRequest links a Frigate instance by logging in to it
+type CreateFrigateInstanceRequest struct {
+	HouseholdID     uint   `json:"household_id"` // Defaults to the user's first household
+	Name            string `json:"name" binding:"required,max=50"`
+	FrigateURL      string `json:"frigate_url" binding:"required,url"`
+	Username        string `json:"username" binding:"required"`
+	Password        string `json:"password" binding:"required"`
+	MQTTTopicPrefix string `json:"mqtt_topic_prefix" binding:"omitempty,max=100"`
+}
+
+// UpdateFrigateInstanceRequest renames, moves or (de)activates a Frigate instance.
+// Sending username and password logs in again and replaces the stored token; changing
+// frigate_url requires them, so the stored credentials are never sent to another server.
+type UpdateFrigateInstanceRequest struct {
+	Name            string `json:"name" binding:"omitempty,max=50"`
+	FrigateURL      string `json:"frigate_url" binding:"omitempty,url"`
+	MQTTTopicPrefix string `json:"mqtt_topic_prefix" binding:"omitempty,max=100"`
+	IsActive        *bool  `json:"is_active"`
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+}
+
+// GetFrigateInstances lists the Frigate instances of the authenticated user's households
+// GET /api/frigate/instances
+func GetFrigateInstances(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var instances []models.FrigateConnect
+	householdIDs := services.NewHouseholdService(db).MemberHouseholdIDs(userID.(uint))
+	if err := db.Where("household_id IN (?)", householdIDs).Order("id ASC").Find(&instances).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve Frigate instances", err.Error())
+		return
+	}
+
+	response := make([]gin.H, len(instances))
+	for i, instance := range instances {
+		response[i] = frigateInstanceResponse(&instance)
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Frigate instances retrieved", "", response))
+}
+
+// CreateFrigateInstance links another Frigate instance to one of the user's households
+// POST /api/frigate/instances
+func CreateFrigateInstance(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req CreateFrigateInstanceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	householdID, ok := instanceHousehold(ctx, db, userID.(uint), req.HouseholdID)
+	if !ok {
+		return
+	}
+
+	if msg := validateInstanceName(db, householdID, req.Name, 0); msg != "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, msg, nil)
+		return
+	}
+
+	frigateURL := strings.TrimSuffix(req.FrigateURL, "/")
+	token, err := services.NewFrigateClient(frigateURL).Login(req.Username, req.Password)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadGateway, "Frigate authentication failed", err.Error())
+		return
+	}
+
+	if req.MQTTTopicPrefix == "" {
+		req.MQTTTopicPrefix = models.DefaultMQTTTopicPrefix
+	}
+	if !checkTopicPrefix(ctx, db, req.MQTTTopicPrefix, 0) {
+		return
+	}
+
+	now := time.Now()
+	instance := models.FrigateConnect{
+		UserID:          userID.(uint),
+		HouseholdID:     &householdID,
+		Name:            req.Name,
+		FrigateURL:      frigateURL,
+		FrigateUsername: req.Username,
+		MQTTTopicPrefix: req.MQTTTopicPrefix,
+		TokenCookie:     token,
+		LastVerifiedAt:  &now,
+		IsActive:        true,
+	}
+	services.RememberFrigatePassword(&instance, req.Password)
+
+	if err := db.Create(&instance).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create Frigate instance", err.Error())
+		return
+	}
+
+	log.Printf("[Frigate] Instance %s (%s) linked by user %v", instance.Name, instance.FrigateURL, userID)
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "Frigate instance linked", "", frigateInstanceResponse(&instance)))
+}
+
+// UpdateFrigateInstance updates a linked Frigate instance
+// PUT /api/frigate/instances/:instance
+func UpdateFrigateInstance(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req UpdateFrigateInstanceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	instance, member, ok := findManagedFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
+		return
+	}
+
+	if req.Name != "" && req.Name != instance.Name {
+		if msg := validateInstanceName(db, *instance.HouseholdID, req.Name, instance.ID); msg != "" {
+			utils.RespondWithError(ctx, http.StatusBadRequest, msg, nil)
+			return
+		}
+		instance.Name = req.Name
+	}
+	if frigateURL := strings.TrimSuffix(req.FrigateURL, "/"); frigateURL != "" && frigateURL != instance.FrigateURL {
+		// The stored token and password belong to the current server; only the member who
+		// linked the instance or an owner may point it elsewhere, and only with a fresh login
+		if instance.UserID != member.UserID && member.Role != models.HouseholdRoleOwner {
+			utils.RespondWithError(ctx, http.StatusForbidden, "Only the member who linked the instance or a household owner can change its URL", nil)
+			return
+		}
+		if req.Username == "" || req.Password == "" {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Username and password are required to change the Frigate URL", nil)
+			return
+		}
+		instance.FrigateURL = frigateURL
+	}
+	if req.MQTTTopicPrefix != "" && req.MQTTTopicPrefix != instance.MQTTTopicPrefix {
+		if !checkTopicPrefix(ctx, db, req.MQTTTopicPrefix, instance.ID) {
+			return
+		}
+		instance.MQTTTopicPrefix = req.MQTTTopicPrefix
+	}
+	if req.IsActive != nil {
+		instance.IsActive = *req.IsActive
+	}
+
+	if req.Username != "" || req.Password != "" {
+		if req.Username == "" || req.Password == "" {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Both username and password are required to log in again", nil)
+			return
+		}
+		token, err := services.NewFrigateClient(instance.FrigateURL).Login(req.Username, req.Password)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadGateway, "Frigate authentication failed", err.Error())
+			return
+		}
+		now := time.Now()
+		instance.FrigateUsername = req.Username
+		instance.TokenCookie = token
+		instance.LastVerifiedAt = &now
+		services.RememberFrigatePassword(instance, req.Password)
+	}
+
+	if err := db.Save(instance).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to update Frigate instance", err.Error())
+		return
+	}
+	services.FrigateResponses().Invalidate(instance.ID)
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Frigate instance updated", "", frigateInstanceResponse(instance)))
+}
+
+// DeleteFrigateInstance unlinks a Frigate instance
+// DELETE /api/frigate/instances/:instance
+func DeleteFrigateInstance(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	instance, _, ok := findManagedFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
+		return
+	}
+
+	// Hard delete so the name can be reused
+	if err := db.Unscoped().Delete(instance).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to delete Frigate instance", err.Error())
+		return
+	}
+	services.FrigateResponses().Invalidate(instance.ID)
+	if err := db.Where("frigate_connect_id = ?", instance.ID).Delete(&models.CameraSchedule{}).Error; err != nil {
+		log.Printf("[Frigate] Failed to delete schedules of instance %s: %v", instance.Name, err)
+	}
+
+	log.Printf("[Frigate] Instance %s unlinked by user %v", instance.Name, userID)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Frigate instance unlinked", "", nil))
+}
+
+// resolveFrigateConnect loads the Frigate instance named by the ?instance= query parameter,
+// or the user's first active instance when it is omitted. It responds with 404 on failure.
+func resolveFrigateConnect(ctx *gin.Context, db *gorm.DB, userID uint) (*models.FrigateConnect, bool) {
+	instance, err := findFrigateConnect(db, userID, ctx.Query("instance"), true)
+	if err != nil {
+		if identifier := ctx.Query("instance"); identifier != "" {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Frigate instance not found", identifier)
+		} else {
+			utils.RespondWithError(ctx, http.StatusNotFound, "Frigate configuration not found", nil)
+		}
+		return nil, false
+	}
+	return instance, true
+}
+
+// findManagedFrigateConnect loads the instance named by the :instance path parameter and
+// checks that the user may change it. It responds with 404 or 403 on failure.
+func findManagedFrigateConnect(ctx *gin.Context, db *gorm.DB, userID uint) (*models.FrigateConnect, *models.HouseholdMember, bool) {
+	instance, err := findFrigateConnect(db, userID, ctx.Param("instance"), false)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate instance not found", nil)
+		return nil, nil, false
+	}
+
+	member, err := services.NewHouseholdService(db).Membership(*instance.HouseholdID, userID)
+	if err != nil || !member.CanManageInstances() {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Insufficient household permissions", nil)
+		return nil, nil, false
+	}
+	return instance, member, true
+}
+
+// checkTopicPrefix validates an MQTT topic prefix for a new or changed instance and makes
+// sure no other instance uses it. It responds with 400 or 409 on failure.
+func checkTopicPrefix(ctx *gin.Context, db *gorm.DB, prefix string, excludeID uint) bool {
+	if err := services.ValidateTopicPrefix(prefix); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, err.Error(), prefix)
+		return false
+	}
+	err := services.CheckTopicPrefixAvailable(db, prefix, excludeID)
+	if errors.Is(err, services.ErrTopicPrefixInUse) {
+		utils.RespondWithError(ctx, http.StatusConflict, "MQTT topic prefix is already used by another Frigate instance, set mqtt_topic_prefix to this Frigate's mqtt.topic_prefix", prefix)
+		return false
+	}
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return false
+	}
+	return true
+}
+
+// requirePublishablePrefix checks that MQTT commands for the instance reach only its own
+// Frigate server. It responds with 409 if the prefix is unset or shared with another household.
+func requirePublishablePrefix(ctx *gin.Context, db *gorm.DB, frigateConnect *models.FrigateConnect) bool {
+	err := services.CheckPublishPrefix(db, frigateConnect)
+	if errors.Is(err, services.ErrTopicPrefixNotRoutable) {
+		utils.RespondWithError(ctx, http.StatusConflict, "MQTT topic prefix of this Frigate instance is unset or shared with another household, set a unique mqtt_topic_prefix", frigateConnect.MQTTTopicPrefix)
+		return false
+	}
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return false
+	}
+	return true
+}
+
+// instanceHousehold picks the household a new instance is linked to: the requested one,
+// which the user must be allowed to manage, or the user's default household
+func instanceHousehold(ctx *gin.Context, db *gorm.DB, userID uint, householdID uint) (uint, bool) {
+	householdSvc := services.NewHouseholdService(db)
+
+	if householdID == 0 {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			utils.RespondWithError(ctx, http.StatusNotFound, "User not found", nil)
+			return 0, false
+		}
+		household, err := householdSvc.DefaultHousehold(&user)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create household", err.Error())
+			return 0, false
+		}
+		return household.ID, true
+	}
+
+	member, err := householdSvc.Membership(householdID, userID)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Household not found", nil)
+		return 0, false
+	}
+	if !member.CanManageInstances() {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Insufficient household permissions", nil)
+		return 0, false
+	}
+	return householdID, true
+}
+
+// findFrigateConnect looks up a Frigate instance of the user's households by ID or name.
+// An empty identifier selects the first active instance.
+func findFrigateConnect(db *gorm.DB, userID uint, identifier string, activeOnly bool) (*models.FrigateConnect, error) {
+	query := db.Where("household_id IN (?)", services.NewHouseholdService(db).MemberHouseholdIDs(userID))
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+
+	if identifier != "" {
+		if id, err := strconv.ParseUint(identifier, 10, 32); err == nil {
+			query = query.Where("id = ?", id)
+		} else {
+			query = query.Where("name = ?", identifier)
+		}
+	}
+
+	var instance models.FrigateConnect
+	if err := query.Order("id ASC").First(&instance).Error; err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+// validateInstanceName returns an error message if name cannot be used for a new or renamed instance
+func validateInstanceName(db *gorm.DB, householdID uint, name string, excludeID uint) string {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return "Instance name cannot be a number"
+	}
+	if name == allInstances {
+		return "Instance name \"all\" is reserved"
+	}
+
+	var existing models.FrigateConnect
+	err := db.Where("household_id = ? AND name = ? AND id <> ?", householdID, name, excludeID).First(&existing).Error
+	if err == nil {
+		return "Instance name already in use"
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "Database error"
+	}
+	return ""
+}
+
+func frigateInstanceResponse(instance *models.FrigateConnect) gin.H {
+	return gin.H{
+		"id":                 instance.ID,
+		"household_id":       instance.HouseholdID,
+		"name":               instance.Name,
+		"frigate_url":        instance.FrigateURL,
+		"frigate_username":   instance.FrigateUsername,
+		"mqtt_topic_prefix":  instance.MQTTTopicPrefix,
+		"is_active":          instance.IsActive,
+		"last_verified_at":   instance.LastVerifiedAt,
+		"stored_credentials": instance.FrigatePassword != "",
+		"created_at":         instance.CreatedAt,
+	}
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// guestSessionType marks JWTs redeemed from a guest grant; AuthMiddleware refuses them
+const guestSessionType = "guest"
+
+// guestRole is the role reported for guest sessions
+const guestRole = "guest"
+
+// GuestGrantRequest mints a guest grant
+type GuestGrantRequest struct {
+	Label      string    `json:"label" binding:"required,max=100"`
+	Instance   string    `json:"instance"` // Instance ID or name, defaults to the first active instance of the household
+	Cameras    []string  `json:"cameras" binding:"required,min=1,dive,required,max=100"`
+	HoursStart string    `json:"hours_start"` // "HH:MM", server local time
+	HoursEnd   string    `json:"hours_end"`
+	ExpiresAt  time.Time `json:"expires_at" binding:"required"`
+}
+
+// RedeemGuestGrantRequest exchanges a guest code for a guest session
+type RedeemGuestGrantRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GetGuestGrants lists the active guest grants of a household (owners only)
+// GET /api/households/:id/guest-grants
+func GetGuestGrants(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	var grants []models.GuestGrant
+	err = db.Where("household_id = ? AND revoked_at IS NULL AND expires_at > ?", membership.HouseholdID, time.Now()).
+		Order("expires_at ASC").
+		Find(&grants).Error
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve guest grants", err.Error())
+		return
+	}
+
+	response := make([]gin.H, len(grants))
+	for i := range grants {
+		response[i] = guestGrantResponse(&grants[i])
+	}
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Guest grants retrieved", "", response))
+}
+
+// CreateGuestGrant mints a guest grant for cameras of one Frigate instance (owners only).
+// The redeem code is only returned in this response.
+// POST /api/households/:id/guest-grants
+func CreateGuestGrant(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req GuestGrantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	if !req.ExpiresAt.After(time.Now()) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "expires_at must be in the future", nil)
+		return
+	}
+	if (req.HoursStart == "") != (req.HoursEnd == "") {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "hours_start and hours_end must be set together", nil)
+		return
+	}
+	for _, clock := range []string{req.HoursStart, req.HoursEnd} {
+		if clock == "" {
+			continue
+		}
+		if _, err := models.ParseClock(clock); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid hours", err.Error())
+			return
+		}
+	}
+
+	cameras := []string{}
+	seen := make(map[string]bool)
+	for _, camera := range req.Cameras {
+		if strings.Contains(camera, ",") {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Camera names cannot contain commas", camera)
+			return
+		}
+		camera = normalizeCameraName(camera)
+		if !seen[camera] {
+			seen[camera] = true
+			cameras = append(cameras, camera)
+		}
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	query := db.Where("household_id = ? AND is_active = ?", membership.HouseholdID, true)
+	if req.Instance != "" {
+		if id, err := strconv.ParseUint(req.Instance, 10, 32); err == nil {
+			query = query.Where("id = ?", id)
+		} else {
+			query = query.Where("name = ?", req.Instance)
+		}
+	}
+	var instance models.FrigateConnect
+	if err := query.Order("id ASC").First(&instance).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Frigate instance not found in this household", req.Instance)
+		return
+	}
+
+	grant := models.GuestGrant{
+		HouseholdID:      membership.HouseholdID,
+		FrigateConnectID: instance.ID,
+		Label:            req.Label,
+		Cameras:          strings.Join(cameras, ","),
+		HoursStart:       req.HoursStart,
+		HoursEnd:         req.HoursEnd,
+		CreatedBy:        membership.UserID,
+		ExpiresAt:        req.ExpiresAt,
+	}
+	code, err := services.NewGuestGrantService(db).Create(&grant)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create guest grant", err.Error())
+		return
+	}
+
+	response := guestGrantResponse(&grant)
+	response["code"] = code
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "Guest grant created, share the code now as it will not be shown again", "", response))
+}
+
+// RevokeGuestGrant ends a guest grant and signs out its guest sessions (owners only)
+// DELETE /api/households/:id/guest-grants/:grant_id
+func RevokeGuestGrant(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	grantID, err := strconv.ParseUint(ctx.Param("grant_id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid guest grant ID", err.Error())
+		return
+	}
+
+	var grant models.GuestGrant
+	if err := db.Where("id = ? AND household_id = ? AND revoked_at IS NULL", grantID, membership.HouseholdID).First(&grant).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Guest grant not found", nil)
+		return
+	}
+
+	if err := services.NewGuestGrantService(db).Revoke(&grant); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to revoke guest grant", err.Error())
+		return
+	}
+	utils.GetTokenStoreFromContext(ctx).DeleteFamily(services.GuestFamilyID(grant.ID))
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Guest grant revoked", "", nil))
+}
+
+// RedeemGuestGrant exchanges a guest code for a restricted session that can only fetch
+// snapshots and streams of the granted cameras during the granted hours
+// POST /api/auth/guest
+func RedeemGuestGrant(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req RedeemGuestGrantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	grantSvc := services.NewGuestGrantService(db)
+	grant, err := grantSvc.Redeem(req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrGuestGrantInvalid) {
+			utils.RespondWithError(ctx, http.StatusUnauthorized, "Guest code is invalid, revoked or expired", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to redeem guest code", err.Error())
+		return
+	}
+
+	expiresAt := grantSvc.SessionExpiry(grant)
+	claims := jwt.MapClaims{
+		"typ":  guestSessionType,
+		"gid":  grant.ID,
+		"role": guestRole,
+		"exp":  expiresAt.Unix(),
+	}
+	tokenString, err := utils.GenerateJWT(&claims)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate token", nil)
+		return
+	}
+	err = utils.GetTokenStoreFromContext(ctx).Set(tokenString, &models.TokenInfo{
+		Token:     tokenString,
+		ExpiresAt: expiresAt,
+		FamilyID:  services.GuestFamilyID(grant.ID),
+	})
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to start guest session", nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Guest session started", "", gin.H{
+		"token":       tokenString,
+		"expires_at":  expiresAt,
+		"label":       grant.Label,
+		"cameras":     grant.CameraList(),
+		"hours_start": grant.HoursStart,
+		"hours_end":   grant.HoursEnd,
+	}))
+}
+
+// authenticateGuest admits a guest session while its grant is usable and inside its daily hours
+func authenticateGuest(ctx *gin.Context, tokenString string, claims jwt.MapClaims) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		ctx.Abort()
+		return
+	}
+
+	grantID, _ := claims["gid"].(float64)
+	grant, err := services.NewGuestGrantService(db).Active(uint(grantID))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "Guest access revoked or expired", nil)
+		ctx.Abort()
+		return
+	}
+
+	if !grant.WithinHours(time.Now()) {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Guest access is outside the allowed hours", gin.H{
+			"hours_start": grant.HoursStart,
+			"hours_end":   grant.HoursEnd,
+		})
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set("guest_grant", grant)
+	ctx.Set("role", guestRole)
+	ctx.Set("token", tokenString)
+	ctx.Next()
+}
+
+// guestGrantStillValid reloads a grant for long-running guest requests such as streams
+func guestGrantStillValid(db *gorm.DB, grant *models.GuestGrant) bool {
+	current, err := services.NewGuestGrantService(db).Active(grant.ID)
+	return err == nil && current.WithinHours(time.Now())
+}
+
+// guestGrantFromContext returns the grant of a guest session set by GuestAuthMiddleware
+func guestGrantFromContext(ctx *gin.Context) (*models.GuestGrant, bool) {
+	value, exists := ctx.Get("guest_grant")
+	if !exists {
+		return nil, false
+	}
+	grant, ok := value.(*models.GuestGrant)
+	return grant, ok
+}
+
+func guestGrantResponse(grant *models.GuestGrant) gin.H {
+	return gin.H{
+		"id":                 grant.ID,
+		"label":              grant.Label,
+		"frigate_connect_id": grant.FrigateConnectID,
+		"cameras":            grant.CameraList(),
+		"hours_start":        grant.HoursStart,
+		"hours_end":          grant.HoursEnd,
+		"expires_at":         grant.ExpiresAt,
+		"last_used_at":       grant.LastUsedAt,
+		"created_by":         grant.CreatedBy,
+		"created_at":         grant.CreatedAt,
+	}
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// HouseholdRequest creates or renames a household
+type HouseholdRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// HouseholdRoleRequest sets the household role of a member or invite
+type HouseholdRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// JoinHouseholdRequest redeems an invite code
+type JoinHouseholdRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GetHouseholds lists the households the authenticated user belongs to
+// GET /api/households
+func GetHouseholds(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var memberships []models.HouseholdMember
+	if err := db.Where("user_id = ?", userID).Order("household_id ASC").Find(&memberships).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve households", err.Error())
+		return
+	}
+
+	response := make([]gin.H, 0, len(memberships))
+	for _, membership := range memberships {
+		var household models.Household
+		if err := db.First(&household, membership.HouseholdID).Error; err != nil {
+			continue
+		}
+		response = append(response, householdResponse(db, &household, membership.Role))
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Households retrieved", "", response))
+}
+
+// CreateHousehold creates a household owned by the authenticated user
+// POST /api/households
+func CreateHousehold(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req HouseholdRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	household, err := services.NewHouseholdService(db).Create(req.Name, userID.(uint))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create household", err.Error())
+		return
+	}
+
+	log.Printf("[Household] Household %s created by user %v", household.Name, userID)
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "Household created", "", householdResponse(db, household, models.HouseholdRoleOwner)))
+}
+
+// UpdateHousehold renames a household (owners only)
+// PUT /api/households/:id
+func UpdateHousehold(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req HouseholdRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	var household models.Household
+	if err := db.First(&household, membership.HouseholdID).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Household not found", nil)
+		return
+	}
+
+	household.Name = req.Name
+	if err := db.Save(&household).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to update household", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Household updated", "", householdResponse(db, &household, membership.Role)))
+}
+
+// DeleteHousehold deletes a household and unlinks its Frigate instances (owners only)
+// DELETE /api/households/:id
+func DeleteHousehold(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	if err := services.NewHouseholdService(db).Delete(membership.HouseholdID); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to delete household", err.Error())
+		return
+	}
+
+	log.Printf("[Household] Household %d deleted by user %d", membership.HouseholdID, membership.UserID)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Household deleted", "", nil))
+}
+
+// GetHouseholdMembers lists the members of a household
+// GET /api/households/:id/members
+func GetHouseholdMembers(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db)
+	if !ok {
+		return
+	}
+
+	var members []models.HouseholdMember
+	if err := db.Preload("User").Where("household_id = ?", membership.HouseholdID).Order("id ASC").Find(&members).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve members", err.Error())
+		return
+	}
+
+	response := make([]gin.H, len(members))
+	for i, member := range members {
+		response[i] = gin.H{
+			"user_id":   member.UserID,
+			"username":  member.User.Username,
+			"role":      member.Role,
+			"joined_at": member.CreatedAt,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Household members retrieved", "", response))
+}
+
+// UpdateHouseholdMember changes the household role of a member (owners only)
+// PUT /api/households/:id/members/:user_id
+func UpdateHouseholdMember(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req HouseholdRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	if !models.IsValidHouseholdRole(req.Role) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid role", req.Role)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	member, ok := findHouseholdMember(ctx, db, membership.HouseholdID)
+	if !ok {
+		return
+	}
+
+	if err := services.NewHouseholdService(db).UpdateMemberRole(member, req.Role); err != nil {
+		if errors.Is(err, services.ErrLastOwner) {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Cannot demote the last owner", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to update member", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Household member updated", "", gin.H{
+		"user_id": member.UserID,
+		"role":    member.Role,
+	}))
+}
+
+// RemoveHouseholdMember removes a member from a household.
+// Owners can remove anyone; other members can only remove themselves.
+// DELETE /api/households/:id/members/:user_id
+func RemoveHouseholdMember(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db)
+	if !ok {
+		return
+	}
+
+	member, ok := findHouseholdMember(ctx, db, membership.HouseholdID)
+	if !ok {
+		return
+	}
+
+	if member.UserID != membership.UserID && membership.Role != models.HouseholdRoleOwner {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Insufficient household permissions", nil)
+		return
+	}
+
+	if err := services.NewHouseholdService(db).RemoveMember(member); err != nil {
+		if errors.Is(err, services.ErrLastOwner) {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Cannot remove the last owner", nil)
+			return
+		}
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to remove member", err.Error())
+		return
+	}
+
+	log.Printf("[Household] User %d removed from household %d", member.UserID, member.HouseholdID)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Household member removed", "", nil))
+}
+
+// GetHouseholdInvites lists the pending invites of a household (owners only)
+// GET /api/households/:id/invites
+func GetHouseholdInvites(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	var invites []models.HouseholdInvite
+	if err := db.Where("household_id = ? AND accepted_at IS NULL", membership.HouseholdID).Order("id ASC").Find(&invites).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve invites", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Household invites retrieved", "", invites))
+}
+
+// CreateHouseholdInvite issues an invite code granting a household role (owners only).
+// The code is only returned in this response.
+// POST /api/households/:id/invites
+func CreateHouseholdInvite(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req HouseholdRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+	if !models.IsValidHouseholdRole(req.Role) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid role", req.Role)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	code, invite, err := services.NewHouseholdService(db).CreateInvite(membership.HouseholdID, req.Role, membership.UserID)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to create invite", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "Invite created, share the code now as it will not be shown again", "", gin.H{
+		"id":         invite.ID,
+		"code":       code,
+		"role":       invite.Role,
+		"expires_at": invite.ExpiresAt,
+	}))
+}
+
+// RevokeHouseholdInvite deletes a pending invite (owners only)
+// DELETE /api/households/:id/invites/:invite_id
+func RevokeHouseholdInvite(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	membership, ok := requireHouseholdRole(ctx, db, models.HouseholdRoleOwner)
+	if !ok {
+		return
+	}
+
+	inviteID, err := strconv.ParseUint(ctx.Param("invite_id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid invite ID", err.Error())
+		return
+	}
+
+	result := db.Where("id = ? AND household_id = ? AND accepted_at IS NULL", inviteID, membership.HouseholdID).Delete(&models.HouseholdInvite{})
+	if result.Error != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to revoke invite", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Invite not found", nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Invite revoked", "", nil))
+}
+
+// JoinHousehold redeems an invite code and adds the authenticated user to its household
+// POST /api/households/join
+func JoinHousehold(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req JoinHouseholdRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	member, err := services.NewHouseholdService(db).AcceptInvite(req.Code, userID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInviteInvalid):
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid or expired invite", nil)
+		case errors.Is(err, services.ErrAlreadyMember):
+			utils.RespondWithError(ctx, http.StatusConflict, "Already a member of this household", nil)
+		default:
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to join household", err.Error())
+		}
+		return
+	}
+
+	var household models.Household
+	db.First(&household, member.HouseholdID)
+
+	log.Printf("[Household] User %v joined household %d as %s", userID, member.HouseholdID, member.Role)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Joined household", "", householdResponse(db, &household, member.Role)))
+}
+
+// requireHouseholdRole loads the user's membership of the household in the :id path
+// parameter and checks it has one of roles (any role if none are given).
+// Households the user does not belong to are reported as not found.
+func requireHouseholdRole(ctx *gin.Context, db *gorm.DB, roles ...string) (*models.HouseholdMember, bool) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return nil, false
+	}
+
+	householdID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid household ID", err.Error())
+		return nil, false
+	}
+
+	membership, err := services.NewHouseholdService(db).Membership(uint(householdID), userID.(uint))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Household not found", nil)
+		return nil, false
+	}
+
+	if len(roles) == 0 {
+		return membership, true
+	}
+	for _, role := range roles {
+		if membership.Role == role {
+			return membership, true
+		}
+	}
+	utils.RespondWithError(ctx, http.StatusForbidden, "Insufficient household permissions", nil)
+	return nil, false
+}
+
+// findHouseholdMember loads the member named by the :user_id path parameter
+func findHouseholdMember(ctx *gin.Context, db *gorm.DB, householdID uint) (*models.HouseholdMember, bool) {
+	memberUserID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid user ID", err.Error())
+		return nil, false
+	}
+
+	member, err := services.NewHouseholdService(db).Membership(householdID, uint(memberUserID))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Member not found", nil)
+		return nil, false
+	}
+	return member, true
+}
+
+func householdResponse(db *gorm.DB, household *models.Household, role string) gin.H {
+	var memberCount, instanceCount int64
+	db.Model(&models.HouseholdMember{}).Where("household_id = ?", household.ID).Count(&memberCount)
+	db.Model(&models.FrigateConnect{}).Where("household_id = ?", household.ID).Count(&instanceCount)
+
+	return gin.H{
+		"id":             household.ID,
+		"name":           household.Name,
+		"role":           role,
+		"member_count":   memberCount,
+		"instance_count": instanceCount,
+		"created_at":     household.CreatedAt,
+	}
+}
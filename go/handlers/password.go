@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ChangePasswordRequest represents a password change by the account owner
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+// ResetPasswordWithTokenRequest redeems an admin-issued reset token
+type ResetPasswordWithTokenRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// ChangePassword sets a new password after checking the current one, then logs the user out everywhere
+// PUT /api/users/profile/password
+func ChangePassword(ctx *gin.Context) {
+	db, user, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	// A stolen session must not be usable to guess the current password
+	if lockedUntil, locked := services.NewLoginThrottleService(db).Check(ctx.ClientIP(), user.Username); locked {
+		respondLockedOut(ctx, lockedUntil)
+		return
+	}
+
+	if !checkPassword(db, user, req.CurrentPassword) {
+		loginFailed(ctx, db, user.Username, http.StatusUnauthorized, "Current password is incorrect", nil)
+		return
+	}
+
+	if err := services.NewPasswordService(db).SetPassword(user.ID, req.NewPassword); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to change password", err.Error())
+		return
+	}
+
+	revokeUserSessions(ctx, db, user.ID)
+
+	log.Printf("[Auth] Password changed by user %s", user.Username)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Password changed, please log in again", "", nil))
+}
+
+// ResetPasswordWithToken sets a new password using a single-use token issued by an administrator
+// POST /api/auth/password/reset
+func ResetPasswordWithToken(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req ResetPasswordWithTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	user, err := services.NewPasswordService(db).ResetWithToken(req.Token, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, services.ErrResetTokenInvalid) {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid or expired reset token", nil)
+		} else {
+			utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to reset password", err.Error())
+		}
+		return
+	}
+
+	revokeUserSessions(ctx, db, user.ID)
+	services.NewLoginThrottleService(db).RecordSuccess(user.Username)
+
+	log.Printf("[Auth] Password of user %s reset with a reset token", user.Username)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Password reset, please log in with the new password", "", nil))
+}
+
+// IssuePasswordResetToken creates a single-use reset token for a user
+// The raw token is only returned in this response
+// POST /api/admin/users/:id/password-reset-token
+func IssuePasswordResetToken(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	user, ok := findUserByParam(ctx, db)
+	if !ok {
+		return
+	}
+
+	token, record, err := services.NewPasswordService(db).IssueResetToken(user.ID, ctx.MustGet("user_id").(uint))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to issue reset token", err.Error())
+		return
+	}
+
+	log.Printf("[Admin] Password reset token issued for user %s by user %v", user.Username, ctx.MustGet("user_id"))
+	ctx.JSON(http.StatusCreated, utils.JsonResponse("success", http.StatusCreated, "Reset token issued, hand it to the user now as it will not be shown again", "", gin.H{
+		"token":      token,
+		"expires_at": record.ExpiresAt,
+	}))
+}
+
+// checkPassword verifies a password for a local login. Accounts whose password is
+// managed by Frigate are checked against Frigate first, so a password changed there
+// replaces the local copy and the old one stops working. The local copy is only used
+// when Frigate cannot be reached.
+func checkPassword(db *gorm.DB, user *models.User, password string) bool {
+	localMatch := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+	if !user.FrigateManagedPassword {
+		return localMatch
+	}
+
+	var frigateConnect models.FrigateConnect
+	if err := db.Where("user_id = ? AND is_active = ?", user.ID, true).Order("id ASC").First(&frigateConnect).Error; err != nil {
+		return localMatch
+	}
+
+	username := frigateConnect.FrigateUsername
+	if username == "" {
+		username = user.Username
+	}
+
+	token, err := services.NewFrigateClient(frigateConnect.FrigateURL).Login(username, password)
+	if err != nil {
+		if errors.Is(err, services.ErrFrigateCredentialsRejected) {
+			return false
+		}
+		log.Printf("[Auth] Frigate unreachable while checking password of user %s, using local copy: %v", user.Username, err)
+		return localMatch
+	}
+
+	if !localMatch {
+		if err := services.NewPasswordService(db).SyncFrigatePassword(user.ID, password); err != nil {
+			log.Printf("[Auth] Failed to sync password of user %s from Frigate: %v", user.Username, err)
+		} else {
+			log.Printf("[Auth] Local password of user %s re-synced from Frigate", user.Username)
+		}
+	}
+
+	now := time.Now()
+	frigateConnect.TokenCookie = token
+	frigateConnect.LastVerifiedAt = &now
+	services.RememberFrigatePassword(&frigateConnect, password)
+	db.Save(&frigateConnect)
+
+	return true
+}
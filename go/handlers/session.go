@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetSessions lists the active login sessions of the authenticated user
+// GET /api/users/sessions
+func GetSessions(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	sessions, err := services.NewSessionService(db).ListActive(userID.(uint))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve sessions", err.Error())
+		return
+	}
+
+	currentFamily := ctx.GetString("family_id")
+	response := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		response[i] = gin.H{
+			"id":           session.ID,
+			"device_name":  session.DeviceName,
+			"ip_address":   session.IPAddress,
+			"user_agent":   session.UserAgent,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"current":      session.FamilyID == currentFamily,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Sessions retrieved", "", response))
+}
+
+// RevokeSession logs out one session of the authenticated user
+// Pass ?deactivate_fcm=true to also stop push notifications to that device
+// DELETE /api/users/sessions/:id
+func RevokeSession(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid session ID", err.Error())
+		return
+	}
+
+	session, err := services.NewSessionService(db).FindActive(userID.(uint), uint(id))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "Session not found", nil)
+		return
+	}
+
+	revokeSessionFamilies(ctx, db, []string{session.FamilyID}, ctx.Query("deactivate_fcm") == "true")
+
+	log.Printf("[Session] Session %d (%s) of user %v revoked", session.ID, session.DeviceName, userID)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Session revoked", "", nil))
+}
+
+// RevokeOtherSessions logs out every session of the authenticated user except the current one
+// Pass ?deactivate_fcm=true to also stop push notifications to those devices
+// DELETE /api/users/sessions
+func RevokeOtherSessions(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	sessions, err := services.NewSessionService(db).ListActive(userID.(uint))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to retrieve sessions", err.Error())
+		return
+	}
+
+	currentFamily := ctx.GetString("family_id")
+	familyIDs := []string{}
+	for _, session := range sessions {
+		if session.FamilyID != currentFamily {
+			familyIDs = append(familyIDs, session.FamilyID)
+		}
+	}
+
+	revokeSessionFamilies(ctx, db, familyIDs, ctx.Query("deactivate_fcm") == "true")
+
+	log.Printf("[Session] %d other sessions of user %v revoked", len(familyIDs), userID)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Other sessions revoked", "", gin.H{
+		"revoked": len(familyIDs),
+	}))
+}
+
+// revokeSessionFamilies invalidates the access and refresh tokens of the given sessions
+func revokeSessionFamilies(ctx *gin.Context, db *gorm.DB, familyIDs []string, deactivateFCM bool) {
+	tokenStore := utils.GetTokenStoreFromContext(ctx)
+	refreshSvc := services.NewRefreshTokenService(db)
+	for _, familyID := range familyIDs {
+		tokenStore.DeleteFamily(familyID)
+		if err := refreshSvc.RevokeFamily(familyID); err != nil {
+			log.Printf("[Session] Failed to revoke refresh tokens of family %s: %v", familyID, err)
+		}
+	}
+
+	if deactivateFCM {
+		if err := services.NewSessionService(db).DeactivateFCMTokens(familyIDs...); err != nil {
+			log.Printf("[Session] Failed to deactivate FCM tokens: %v", err)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/services"
+	"sotsukenn/go/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// twoFactorChallengeType marks challenge JWTs so they cannot be mistaken for access tokens
+const twoFactorChallengeType = "2fa_challenge"
+
+// twoFactorChallengeTTL is how long the user has to enter their code after the password step
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// TwoFactorCodeRequest carries a TOTP code or a recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest requires both the password and a second factor
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginRequest completes a login that returned a challenge token
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// respondWithTwoFactorChallenge answers the password step of a 2FA-enabled account
+// with a short-lived challenge token instead of a session
+func respondWithTwoFactorChallenge(ctx *gin.Context, user *models.User, deviceName string) {
+	claims := jwt.MapClaims{
+		"typ":         twoFactorChallengeType,
+		"user_id":     user.ID,
+		"device_name": deviceName,
+		"exp":         time.Now().Add(twoFactorChallengeTTL).Unix(),
+	}
+
+	challenge, err := utils.GenerateJWT(&claims)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate token", nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Two-factor authentication required", "", gin.H{
+		"two_factor_required": true,
+		"challenge_token":     challenge,
+		"expires_in":          int64(twoFactorChallengeTTL.Seconds()),
+	}))
+}
+
+// VerifyTwoFactorLogin exchanges a challenge token and a TOTP or recovery code for a session
+// POST /api/auth/2fa
+func VerifyTwoFactorLogin(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	var req TwoFactorLoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateJWT(req.ChallengeToken)
+	if err != nil || claims["typ"] != twoFactorChallengeType {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, uint(userID)).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+
+	if !user.IsActive {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Account is inactive", nil)
+		return
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	throttle := services.NewLoginThrottleService(db)
+	if lockedUntil, locked := throttle.Check(ctx.ClientIP(), user.Username); locked {
+		respondLockedOut(ctx, lockedUntil)
+		return
+	}
+
+	if !user.TOTPEnabled || !services.NewTOTPService(db).VerifySecondFactor(&user, req.Code) {
+		loginFailed(ctx, db, user.Username, http.StatusUnauthorized, "Invalid two-factor code", nil)
+		return
+	}
+
+	throttle.RecordSuccess(user.Username)
+
+	deviceName, _ := claims["device_name"].(string)
+	generateLocalToken(ctx, db, &user, deviceName)
+}
+
+// GetTwoFactorStatus reports whether two-factor authentication is enabled
+// GET /api/users/profile/2fa
+func GetTwoFactorStatus(ctx *gin.Context) {
+	db, user, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Two-factor status retrieved", "", gin.H{
+		"enabled":                  user.TOTPEnabled,
+		"recovery_codes_remaining": services.NewTOTPService(db).RemainingRecoveryCodes(user.ID),
+	}))
+}
+
+// SetupTwoFactor generates a new secret and returns its provisioning URI for a QR code.
+// Two-factor authentication stays off until the first code is confirmed.
+// POST /api/users/profile/2fa
+func SetupTwoFactor(ctx *gin.Context) {
+	db, user, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		utils.RespondWithError(ctx, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	totp := services.NewTOTPService(db)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate secret", nil)
+		return
+	}
+
+	// A struct update so the secret goes through the encrypted serializer
+	if err := db.Model(user).Select("TOTPSecret", "TOTPLastStep").Updates(&models.User{TOTPSecret: secret}).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to save secret", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Scan the QR code and confirm with a code", "", gin.H{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(user.Username, secret),
+	}))
+}
+
+// EnableTwoFactor confirms enrollment with a code from the authenticator app and returns recovery codes
+// POST /api/users/profile/2fa/enable
+func EnableTwoFactor(ctx *gin.Context) {
+	db, user, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	if user.TOTPEnabled {
+		utils.RespondWithError(ctx, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+	if user.TOTPSecret == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Start two-factor setup first", nil)
+		return
+	}
+
+	totp := services.NewTOTPService(db)
+	if !totp.VerifyCode(user, req.Code) {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid two-factor code", nil)
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(user.ID)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate recovery codes", err.Error())
+		return
+	}
+
+	if err := db.Model(user).Update("totp_enabled", true).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to enable two-factor authentication", err.Error())
+		return
+	}
+
+	log.Printf("[2FA] Enabled for user %s", user.Username)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Two-factor authentication enabled, store the recovery codes now", "", gin.H{
+		"recovery_codes": codes,
+	}))
+}
+
+// DisableTwoFactor turns off two-factor authentication
+// DELETE /api/users/profile/2fa
+func DisableTwoFactor(ctx *gin.Context) {
+	db, user, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	if !user.TOTPEnabled {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Two-factor authentication is not enabled", nil)
+		return
+	}
+
+	totp := services.NewTOTPService(db)
+	if !checkPassword(db, user, req.Password) || !totp.VerifySecondFactor(user, req.Code) {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid credentials", nil)
+		return
+	}
+
+	if err := totp.Disable(user.ID); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to disable two-factor authentication", err.Error())
+		return
+	}
+
+	log.Printf("[2FA] Disabled for user %s", user.Username)
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Two-factor authentication disabled", "", nil))
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user
+// POST /api/users/profile/2fa/recovery-codes
+func RegenerateRecoveryCodes(ctx *gin.Context) {
+	db, user, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	if !user.TOTPEnabled {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Two-factor authentication is not enabled", nil)
+		return
+	}
+
+	totp := services.NewTOTPService(db)
+	if !totp.VerifyCode(user, req.Code) {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "Invalid two-factor code", nil)
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(user.ID)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to generate recovery codes", err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Recovery codes regenerated, store them now", "", gin.H{
+		"recovery_codes": codes,
+	}))
+}
+
+// ResetUserTwoFactor turns off two-factor authentication for a user who lost their device
+// DELETE /api/admin/users/:id/2fa
+func ResetUserTwoFactor(ctx *gin.Context) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return
+	}
+
+	user, ok := findUserByParam(ctx, db)
+	if !ok {
+		return
+	}
+
+	if err := services.NewTOTPService(db).Disable(user.ID); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to reset two-factor authentication", err.Error())
+		return
+	}
+
+	log.Printf("[Admin] Two-factor authentication of user %s reset by user %v", user.Username, ctx.MustGet("user_id"))
+	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Two-factor authentication reset", "", nil))
+}
+
+// currentUser loads the authenticated user, responding with an error if that fails
+func currentUser(ctx *gin.Context) (*gorm.DB, *models.User, bool) {
+	db, err := utils.GetDBFromContext(ctx)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Database error", nil)
+		return nil, nil, false
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return nil, nil, false
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		utils.RespondWithError(ctx, http.StatusNotFound, "User not found", nil)
+		return nil, nil, false
+	}
+
+	return db, &user, true
+}
@@ -34,12 +34,16 @@ func GetProfile(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Profile retrieved", "", gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"email":      user.Email,
-		"is_active":  user.IsActive,
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
+		"id":           user.ID,
+		"username":     user.Username,
+		"email":        user.Email,
+		"role":         user.Role,
+		"is_active":    user.IsActive,
+		"totp_enabled": user.TOTPEnabled,
+		// Frigate-managed passwords follow the Frigate account until changed here
+		"frigate_managed_password": user.FrigateManagedPassword,
+		"created_at":               user.CreatedAt,
+		"updated_at":               user.UpdatedAt,
 	}))
 }
 
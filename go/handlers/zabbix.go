@@ -2,15 +2,15 @@ package handlers
 
 import (
 	"net/http"
-	"sotsukenn/go/models"
 	"sotsukenn/go/services"
 	"sotsukenn/go/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetZabbixStatus 返回Frigate状态和响应时间
-// GET /api/zabbix/status
+// GET /api/zabbix/status?instance=xxx
 func GetZabbixStatus(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
 	if err != nil {
@@ -24,16 +24,14 @@ func GetZabbixStatus(ctx *gin.Context) {
 		return
 	}
 
-	// 获取Frigate配置
-	var frigateConnect models.FrigateConnect
-	err = db.Where("user_id = ? AND is_active = ?", userID, true).First(&frigateConnect).Error
-	if err != nil {
-		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate configuration not found", nil)
+	// 获取Frigate配置（?instance= 指定实例，默认第一个启用的实例）
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
 		return
 	}
 
-	monitoringSvc := services.NewMonitoringService(db, frigateConnect.FrigateURL)
-	status, err := monitoringSvc.GetFrigateStatus(userID.(uint))
+	monitoringSvc := services.NewMonitoringService(db)
+	status, err := monitoringSvc.GetFrigateStatus(frigateConnect)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get Frigate status", err.Error())
 		return
@@ -43,7 +41,7 @@ func GetZabbixStatus(ctx *gin.Context) {
 }
 
 // GetZabbixLastEvent 返回最后事件时间
-// GET /api/zabbix/events/last?camera=xxx&label=xxx
+// GET /api/zabbix/events/last?camera=xxx&label=xxx&instance=xxx
 func GetZabbixLastEvent(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
 	if err != nil {
@@ -51,11 +49,16 @@ func GetZabbixLastEvent(ctx *gin.Context) {
 		return
 	}
 
+	scope, ok := eventScope(ctx, db)
+	if !ok {
+		return
+	}
+
 	camera := ctx.Query("camera")
 	label := ctx.Query("label")
 
 	eventSvc := services.NewEventService(db)
-	lastTime, err := eventSvc.GetLastEventTime(camera, label)
+	lastTime, err := eventSvc.GetLastEventTime(scope, camera, label)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get last event time", err.Error())
 		return
@@ -69,7 +72,7 @@ func GetZabbixLastEvent(ctx *gin.Context) {
 }
 
 // GetZabbixCameras 返回摄像头在线/离线统计
-// GET /api/zabbix/cameras
+// GET /api/zabbix/cameras?instance=xxx
 func GetZabbixCameras(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
 	if err != nil {
@@ -83,16 +86,14 @@ func GetZabbixCameras(ctx *gin.Context) {
 		return
 	}
 
-	// 获取Frigate配置
-	var frigateConnect models.FrigateConnect
-	err = db.Where("user_id = ? AND is_active = ?", userID, true).First(&frigateConnect).Error
-	if err != nil {
-		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate configuration not found", nil)
+	// 获取Frigate配置（?instance= 指定实例，默认第一个启用的实例）
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
 		return
 	}
 
-	monitoringSvc := services.NewMonitoringService(db, frigateConnect.FrigateURL)
-	status, err := monitoringSvc.GetCameraStatus(userID.(uint))
+	monitoringSvc := services.NewMonitoringService(db)
+	status, err := monitoringSvc.GetCameraStatus(frigateConnect)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get camera status", err.Error())
 		return
@@ -102,7 +103,7 @@ func GetZabbixCameras(ctx *gin.Context) {
 }
 
 // GetZabbixPersonStats 返回人类检测统计
-// GET /api/zabbix/stats/person
+// GET /api/zabbix/stats/person?instance=xxx
 func GetZabbixPersonStats(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
 	if err != nil {
@@ -110,8 +111,13 @@ func GetZabbixPersonStats(ctx *gin.Context) {
 		return
 	}
 
+	scope, ok := eventScope(ctx, db)
+	if !ok {
+		return
+	}
+
 	eventSvc := services.NewEventService(db)
-	count, people, err := eventSvc.GetPersonDetectionCount()
+	count, people, err := eventSvc.GetPersonDetectionCount(scope)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get person stats", err.Error())
 		return
@@ -125,7 +131,7 @@ func GetZabbixPersonStats(ctx *gin.Context) {
 }
 
 // GetZabbixAllStats 返回所有监控指标的统一端点
-// GET /api/zabbix/all
+// GET /api/zabbix/all?instance=xxx
 func GetZabbixAllStats(ctx *gin.Context) {
 	db, err := utils.GetDBFromContext(ctx)
 	if err != nil {
@@ -139,41 +145,46 @@ func GetZabbixAllStats(ctx *gin.Context) {
 		return
 	}
 
-	// 获取Frigate配置
-	var frigateConnect models.FrigateConnect
-	err = db.Where("user_id = ? AND is_active = ?", userID, true).First(&frigateConnect).Error
-	if err != nil {
-		utils.RespondWithError(ctx, http.StatusNotFound, "Frigate configuration not found", nil)
+	// 获取Frigate配置（?instance= 指定实例，默认第一个启用的实例）
+	frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+	if !ok {
 		return
 	}
 
 	// 初始化服务
 	eventSvc := services.NewEventService(db)
-	monitoringSvc := services.NewMonitoringService(db, frigateConnect.FrigateURL)
+	monitoringSvc := services.NewMonitoringService(db)
+
+	// 事件统计只包含该实例中用户有权查看的事件
+	scope, err := services.NewCameraACLService(db).InstanceEventScope(frigateConnect, userID.(uint))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to load camera permissions", err.Error())
+		return
+	}
 
 	// 1. 最后事件时间
-	lastTime, err := eventSvc.GetLastEventTime("", "")
+	lastTime, err := eventSvc.GetLastEventTime(scope, "", "")
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get last event time", err.Error())
 		return
 	}
 
 	// 2. Frigate状态
-	frigateStatus, err := monitoringSvc.GetFrigateStatus(userID.(uint))
+	frigateStatus, err := monitoringSvc.GetFrigateStatus(frigateConnect)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get Frigate status", err.Error())
 		return
 	}
 
 	// 3. 摄像头状态
-	cameraStatus, err := monitoringSvc.GetCameraStatus(userID.(uint))
+	cameraStatus, err := monitoringSvc.GetCameraStatus(frigateConnect)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get camera status", err.Error())
 		return
 	}
 
 	// 4. 人类检测统计
-	personCount, people, err := eventSvc.GetPersonDetectionCount()
+	personCount, people, err := eventSvc.GetPersonDetectionCount(scope)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to get person stats", err.Error())
 		return
@@ -192,3 +203,30 @@ func GetZabbixAllStats(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, utils.JsonResponse("success", http.StatusOK, "Zabbix stats retrieved", "", allStats))
 }
+
+// eventScope 返回用户可见的事件范围：指定 ?instance= 时为该实例，否则为用户所属家庭的所有实例，并排除无权查看事件的摄像头
+func eventScope(ctx *gin.Context, db *gorm.DB) (services.EventScope, bool) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated", nil)
+		return services.EventScope{}, false
+	}
+
+	aclSvc := services.NewCameraACLService(db)
+	var scope services.EventScope
+	var err error
+	if ctx.Query("instance") != "" {
+		frigateConnect, ok := resolveFrigateConnect(ctx, db, userID.(uint))
+		if !ok {
+			return services.EventScope{}, false
+		}
+		scope, err = aclSvc.InstanceEventScope(frigateConnect, userID.(uint))
+	} else {
+		scope, err = aclSvc.EventScope(userID.(uint))
+	}
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to load camera permissions", err.Error())
+		return services.EventScope{}, false
+	}
+	return scope, true
+}
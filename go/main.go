@@ -5,10 +5,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"sotsukenn/go/database"
+	"sotsukenn/go/encryption"
 	"sotsukenn/go/handlers"
 	"sotsukenn/go/middleware"
 	"sotsukenn/go/migrate"
@@ -25,21 +27,54 @@ import (
 )
 
 var (
-	tokenStore *models.TokenStore
+	tokenStore models.TokenStore
 	once       sync.Once
 )
 
+// initTokenStore picks the session store from TOKEN_STORE (database or memory)
 func initTokenStore() {
-	tokenStore = models.NewTokenStore()
+	switch os.Getenv("TOKEN_STORE") {
+	case "memory":
+		tokenStore = models.NewMemoryTokenStore()
+		log.Println("TokenStore: Using in-memory session store")
+	default:
+		db, err := database.GetDBWithLogger(logger.Silent)
+		if err != nil {
+			panic(fmt.Sprintf("fail get databases.... error : %v", err))
+		}
+		tokenStore = models.NewDBTokenStore(db)
+		log.Println("TokenStore: Using database session store")
+	}
+
 	go func() {
 		for {
 			time.Sleep(60 * time.Second)
 			tokenStore.Clean()
+			if db := database.GetDB(); db != nil {
+				if err := services.NewRefreshTokenService(db).CleanExpired(); err != nil {
+					log.Printf("TokenStore: Failed to clean expired refresh tokens: %v", err)
+				}
+				if err := services.NewSessionService(db).CleanInactive(); err != nil {
+					log.Printf("TokenStore: Failed to clean inactive sessions: %v", err)
+				}
+				if err := services.NewPasswordService(db).CleanExpired(); err != nil {
+					log.Printf("Login: Failed to clean expired password reset tokens: %v", err)
+				}
+				if err := services.NewLoginThrottleService(db).CleanStale(); err != nil {
+					log.Printf("Login: Failed to clean stale login throttles: %v", err)
+				}
+				if err := services.NewHouseholdService(db).CleanExpiredInvites(); err != nil {
+					log.Printf("Household: Failed to clean expired invites: %v", err)
+				}
+				if err := services.NewGuestGrantService(db).CleanExpired(); err != nil {
+					log.Printf("Household: Failed to clean expired guest grants: %v", err)
+				}
+			}
 		}
 	}()
 }
 
-func getTokenStore() *models.TokenStore {
+func getTokenStore() models.TokenStore {
 	once.Do(initTokenStore)
 	return tokenStore
 }
@@ -58,6 +93,31 @@ func addDB(ctx *gin.Context) {
 	ctx.Next()
 }
 
+// configureClientIP reads TRUSTED_PLATFORM (cloudflare, google-app-engine or fly-io) and
+// TRUSTED_PROXIES (comma separated IPs or CIDRs). Without them the client IP is the peer
+// address and headers such as CF-Connecting-IP and X-Forwarded-For are ignored.
+func configureClientIP(r *gin.Engine) error {
+	switch platform := os.Getenv("TRUSTED_PLATFORM"); platform {
+	case "":
+	case "cloudflare":
+		r.TrustedPlatform = gin.PlatformCloudflare
+	case "google-app-engine":
+		r.TrustedPlatform = gin.PlatformGoogleAppEngine
+	case "fly-io":
+		r.TrustedPlatform = gin.PlatformFlyIO
+	default:
+		return fmt.Errorf("unknown TRUSTED_PLATFORM %q, expected cloudflare, google-app-engine or fly-io", platform)
+	}
+
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return r.SetTrustedProxies(proxies)
+}
+
 func runServer() func(cmd *cobra.Command, args []string) {
 	return func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetString("port")
@@ -65,11 +125,19 @@ func runServer() func(cmd *cobra.Command, args []string) {
 			port = ":8080"
 		}
 
+		// Refuse to start with a broken key configuration rather than failing on first use
+		if _, err := encryption.Default(); err != nil {
+			panic(fmt.Sprintf("invalid encryption configuration: %v", err))
+		}
+
 		fmt.Printf("Running server on port %s\n", port)
 
 		r := gin.Default()
 
-		r.TrustedPlatform = gin.PlatformCloudflare
+		// The client IP keys the login throttle, so forwarding headers are only trusted when configured
+		if err := configureClientIP(r); err != nil {
+			panic(fmt.Sprintf("invalid client IP configuration: %v", err))
+		}
 
 		r.Use(gin.Logger())
 		r.Use(gin.Recovery())
@@ -80,8 +148,9 @@ func runServer() func(cmd *cobra.Command, args []string) {
 
 		r.Use(cors.New(cors.Config{
 			AllowOrigins:     []string{"*"},
-			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
+			ExposeHeaders:    []string{"Location"},
 			AllowCredentials: false,
 			MaxAge:           12 * time.Hour,
 		}))
@@ -99,9 +168,13 @@ func runServer() func(cmd *cobra.Command, args []string) {
 		{
 			utils.RegisterRoutes("/auth", api, routes.AuthRoutes)
 			utils.RegisterRoutes("/users", api, routes.UserRoutes)
+			utils.RegisterRoutes("/admin", api, routes.AdminRoutes)
 			utils.RegisterRoutes("/health", api, routes.HealthRoutes)
 			utils.RegisterRoutes("/cameras", api, routes.CamerasRoutes)
 			utils.RegisterRoutes("", api, routes.CameraRoutes)
+			utils.RegisterRoutes("", api, routes.ScheduleRoutes)
+			utils.RegisterRoutes("", api, routes.FrigateRoutes)
+			utils.RegisterRoutes("", api, routes.HouseholdRoutes)
 			utils.RegisterRoutes("", api, routes.MqttRoutes)
 			utils.RegisterRoutes("", api, routes.FcmRoutes)
 			utils.RegisterRoutes("", api, routes.ZabbixRoutes)
@@ -160,6 +233,17 @@ func runServer() func(cmd *cobra.Command, args []string) {
 			log.Println("MQTT: Auto-start disabled, use API to start manually")
 		}
 
+		// Camera schedules publish through the MQTT client once it is connected
+		if db, err := database.GetDBWithLogger(logger.Silent); err != nil {
+			log.Printf("Schedule: Failed to initialize database, camera schedules are disabled: %v", err)
+		} else {
+			scheduler := services.NewCameraScheduler(db, handlers.GetMQTTClient())
+			if scheduler.Location() == nil {
+				log.Println("Schedule: SCHEDULE_LATITUDE/SCHEDULE_LONGITUDE not set, sunrise and sunset schedules are skipped")
+			}
+			scheduler.Start()
+		}
+
 		if err := r.Run(port); err != nil {
 			panic(fmt.Sprintf("failed to start server: %v", err))
 		}
@@ -197,6 +281,7 @@ func main() {
 
 	var migrateModelCmd = migrate.MigrateModelCmd()
 	var migrateMarkdownCmd = migrate.MigrateMarkdownCmd()
+	var migrateEncryptCmd = migrate.MigrateEncryptCmd()
 
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(migrateCmd)
@@ -205,6 +290,7 @@ func main() {
 
 	migrateCmd.AddCommand(migrateMarkdownCmd)
 	migrateCmd.AddCommand(migrateModelCmd)
+	migrateCmd.AddCommand(migrateEncryptCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
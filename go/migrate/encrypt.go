@@ -0,0 +1,114 @@
+package migrate
+
+import (
+	"fmt"
+	"log"
+
+	"sotsukenn/go/database"
+	"sotsukenn/go/encryption"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// MigrateEncryptCmd encrypts plaintext values of encrypted fields in place and
+// re-encrypts values written with an older key version under the active key
+func MigrateEncryptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt sensitive fields in place and rotate them to the active key",
+		Run: func(cmd *cobra.Command, args []string) {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			keyring, err := encryption.Default()
+			if err != nil {
+				log.Fatalf("Invalid encryption configuration: %v", err)
+			}
+			if !keyring.Enabled() {
+				log.Fatal("ENCRYPTION_KEYS is not set, nothing to encrypt with.")
+			}
+
+			db, err := database.GetDBWithLogger(logger.Silent)
+			if err != nil {
+				log.Fatalf("Failed to get database instance: %v", err)
+			}
+
+			fmt.Printf("Encrypting sensitive fields with key v%d (available: %v)...\n", keyring.ActiveVersion(), keyring.Versions())
+
+			total := 0
+			for _, model := range Models() {
+				stmt := &gorm.Statement{DB: db}
+				if err := stmt.Parse(model); err != nil {
+					log.Fatalf("Failed to parse model %T: %v", model, err)
+				}
+
+				for _, field := range stmt.Schema.Fields {
+					if field.TagSettings["SERIALIZER"] != "encrypted" {
+						continue
+					}
+					count, err := rotateColumn(db, keyring, stmt.Schema.Table, stmt.Schema.PrioritizedPrimaryField.DBName, field.DBName, dryRun)
+					if err != nil {
+						log.Fatalf("Failed to encrypt %s.%s: %v", stmt.Schema.Table, field.DBName, err)
+					}
+					fmt.Printf("  %s.%s: %d values updated\n", stmt.Schema.Table, field.DBName, count)
+					total += count
+				}
+			}
+
+			if dryRun {
+				fmt.Printf("Dry run: %d values would be updated.\n", total)
+				return
+			}
+			fmt.Printf("Encryption completed, %d values updated.\n", total)
+		},
+	}
+
+	cmd.Flags().Bool("dry-run", false, "Only report how many values would be updated")
+
+	return cmd
+}
+
+// rotateColumn rewrites every value of a column that is plaintext or uses an old key.
+// Raw SQL is used so the serializer is bypassed and soft-deleted rows are included.
+func rotateColumn(db *gorm.DB, keyring *encryption.Keyring, table, pk, column string, dryRun bool) (int, error) {
+	type row struct {
+		ID    uint64
+		Value *string
+	}
+
+	var rows []row
+	err := db.Table(table).
+		Select(fmt.Sprintf("%s AS id, %s AS value", pk, column)).
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, r := range rows {
+		if r.Value == nil || !keyring.NeedsRotation(*r.Value) {
+			continue
+		}
+
+		plaintext, err := keyring.Decrypt(*r.Value)
+		if err != nil {
+			return count, fmt.Errorf("row %v: %w", r.ID, err)
+		}
+
+		count++
+		if dryRun {
+			continue
+		}
+
+		encrypted, err := keyring.Encrypt(plaintext)
+		if err != nil {
+			return count, err
+		}
+		query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", table, column, pk)
+		if err := db.Exec(query, encrypted, r.ID).Error; err != nil {
+			return count, fmt.Errorf("row %v: %w", r.ID, err)
+		}
+	}
+	return count, nil
+}
@@ -6,11 +6,37 @@ import (
 
 	"sotsukenn/go/database"
 	"sotsukenn/go/models"
+	"sotsukenn/go/services"
 
 	"github.com/spf13/cobra"
 	"gorm.io/gorm/logger"
 )
 
+// Models returns every model managed by the migrate commands
+func Models() []interface{} {
+	return []interface{}{
+		&models.User{},
+		&models.FrigateConnect{},
+		&models.FCMToken{},
+		&models.DetectionEvent{},
+		&models.SessionToken{},
+		&models.RefreshToken{},
+		&models.APIKey{},
+		&models.LoginThrottle{},
+		&models.RecoveryCode{},
+		&models.UserSession{},
+		&models.PasswordResetToken{},
+		&models.Household{},
+		&models.HouseholdMember{},
+		&models.HouseholdInvite{},
+		&models.HouseholdGroup{},
+		&models.HouseholdGroupMember{},
+		&models.CameraPermission{},
+		&models.GuestGrant{},
+		&models.CameraSchedule{},
+	}
+}
+
 func MigrateModelCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "db",
@@ -23,17 +49,98 @@ func MigrateModelCmd() *cobra.Command {
 
 			fmt.Println("Migrating models to the database...")
 
-			err = db.AutoMigrate(
-				&models.User{},
-				&models.FrigateConnect{},
-				&models.FCMToken{},
-				&models.DetectionEvent{},
-			)
+			// Checked before migrating so existing Frigate-provisioned accounts can be flagged below
+			backfillFrigateManaged := db.Migrator().HasTable(&models.User{}) &&
+				!db.Migrator().HasColumn(&models.User{}, "FrigateManagedPassword")
+
+			// Users used to be limited to one Frigate instance; drop the old unique index so
+			// AutoMigrate recreates it as a plain index
+			if db.Migrator().HasIndex(&models.FrigateConnect{}, "idx_frigate_connects_user_id") {
+				var unique int
+				db.Raw("SELECT \"unique\" FROM pragma_index_list('frigate_connects') WHERE name = ?", "idx_frigate_connects_user_id").Scan(&unique)
+				if unique == 1 {
+					if err := db.Migrator().DropIndex(&models.FrigateConnect{}, "idx_frigate_connects_user_id"); err != nil {
+						log.Fatalf("Failed to drop unique index on frigate_connects.user_id: %v", err)
+					}
+					fmt.Println("Dropped unique index on frigate_connects.user_id.")
+				}
+			}
+
+			// Instance names used to be unique per user; they are now unique per household
+			if db.Migrator().HasIndex(&models.FrigateConnect{}, "idx_frigate_connects_user_name") {
+				if err := db.Migrator().DropIndex(&models.FrigateConnect{}, "idx_frigate_connects_user_name"); err != nil {
+					log.Fatalf("Failed to drop index idx_frigate_connects_user_name: %v", err)
+				}
+			}
+
+			err = db.AutoMigrate(Models()...)
 
 			if err != nil {
 				log.Fatalf("Migration failed: %v", err)
 			}
 
+			// Databases created before roles existed have no administrator; promote the oldest user
+			var adminCount int64
+			db.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&adminCount)
+			if adminCount == 0 {
+				var firstUser models.User
+				if err := db.Order("id ASC").First(&firstUser).Error; err == nil {
+					db.Model(&firstUser).Update("role", models.RoleAdmin)
+					fmt.Printf("Promoted user %s to admin.\n", firstUser.Username)
+				}
+			}
+
+			// Accounts created by a Frigate login share their username with the Frigate account
+			if backfillFrigateManaged {
+				result := db.Model(&models.User{}).
+					Where("id IN (?)", db.Model(&models.FrigateConnect{}).
+						Select("frigate_connects.user_id").
+						Joins("JOIN users ON users.id = frigate_connects.user_id").
+						Where("frigate_connects.frigate_username = users.username")).
+					Update("frigate_managed_password", true)
+				if result.RowsAffected > 0 {
+					fmt.Printf("Marked %d Frigate-provisioned users as Frigate-managed.\n", result.RowsAffected)
+				}
+			}
+
+			// Frigate instances from before households existed move into a household owned by the user who linked them
+			var orphanedUserIDs []uint
+			db.Model(&models.FrigateConnect{}).Where("household_id IS NULL").Distinct("user_id").Pluck("user_id", &orphanedUserIDs)
+			householdSvc := services.NewHouseholdService(db)
+			for _, userID := range orphanedUserIDs {
+				var user models.User
+				if err := db.First(&user, userID).Error; err != nil {
+					continue
+				}
+				household, err := householdSvc.DefaultHousehold(&user)
+				if err != nil {
+					log.Fatalf("Failed to create household for user %s: %v", user.Username, err)
+				}
+				db.Model(&models.FrigateConnect{}).
+					Where("user_id = ? AND household_id IS NULL", userID).
+					Update("household_id", household.ID)
+				fmt.Printf("Moved Frigate instances of user %s into household %q.\n", user.Username, household.Name)
+			}
+
+			// MQTT topic prefixes are now unique; prefixes several households already share
+			// are not routed until all but one of them are changed
+			shared, err := services.SharedTopicPrefixes(db)
+			if err != nil {
+				log.Fatalf("Failed to check MQTT topic prefixes: %v", err)
+			}
+			for prefix := range shared {
+				fmt.Printf("Warning: MQTT topic prefix %q is used by several households; its events, notifications and camera controls are disabled until each Frigate uses its own prefix.\n", prefix)
+			}
+
+			// Prefixes are now a single topic level; +/events does not match longer ones
+			var multiLevel []models.FrigateConnect
+			if err := db.Where("mqtt_topic_prefix LIKE ?", "%/%").Find(&multiLevel).Error; err != nil {
+				log.Fatalf("Failed to check MQTT topic prefixes: %v", err)
+			}
+			for _, fc := range multiLevel {
+				fmt.Printf("Warning: Frigate instance %q uses the multi-level MQTT topic prefix %q; change it in Frigate and in the instance settings to a single level without \"/\".\n", fc.Name, fc.MQTTTopicPrefix)
+			}
+
 			fmt.Println("Migration completed successfully.")
 		},
 	}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API key scopes
+const (
+	ScopeZabbixRead     = "zabbix:read"
+	ScopeEventsRead     = "events:read"
+	ScopeCamerasRead    = "cameras:read"
+	ScopeCamerasControl = "cameras:control"
+)
+
+// APIKeyPrefix marks raw API keys so they are easy to recognise in configs
+const APIKeyPrefix = "sk_"
+
+// APIKey is a long-lived credential for machine clients such as the Zabbix script.
+// Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Foreign key to the owning user
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	User   User `gorm:"foreignKey:UserID" json:"-"`
+
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;not null" json:"prefix"` // First characters of the key, for display
+	KeyHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"size:255;not null" json:"-"` // Comma separated list of scopes
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsValidScope reports whether scope is a known API key scope
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeZabbixRead, ScopeEventsRead, ScopeCamerasRead, ScopeCamerasControl:
+		return true
+	}
+	return false
+}
+
+// ScopeList returns the scopes granted to the key
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasAnyScope reports whether the key was granted at least one of scopes
+func (k *APIKey) HasAnyScope(scopes ...string) bool {
+	for _, granted := range k.ScopeList() {
+		for _, scope := range scopes {
+			if granted == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUsable reports whether the key is neither revoked nor expired
+func (k *APIKey) IsUsable() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
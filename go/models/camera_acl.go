@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+)
+
+// HouseholdGroup is a named set of household members that camera permissions can target, e.g. "kids"
+type HouseholdGroup struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	HouseholdID uint   `gorm:"not null;uniqueIndex:idx_household_groups_household_name,priority:1" json:"household_id"`
+	Name        string `gorm:"size:50;not null;uniqueIndex:idx_household_groups_household_name,priority:2" json:"name"`
+}
+
+func (HouseholdGroup) TableName() string {
+	return "household_groups"
+}
+
+// HouseholdGroupMember adds a user to a household group
+type HouseholdGroupMember struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	GroupID uint `gorm:"not null;uniqueIndex:idx_household_group_members_group_user,priority:1" json:"group_id"`
+	UserID  uint `gorm:"not null;index;uniqueIndex:idx_household_group_members_group_user,priority:2" json:"user_id"`
+}
+
+func (HouseholdGroupMember) TableName() string {
+	return "household_group_members"
+}
+
+// CameraPermission sets what a user or a group may do with one camera of a household.
+// Exactly one of UserID and GroupID is set. Cameras without any permission are open to
+// every member, except for Control which only owners and explicit permissions grant; a
+// user's own permission takes precedence over those of their groups.
+type CameraPermission struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	HouseholdID uint   `gorm:"not null;index" json:"household_id"`
+	Camera      string `gorm:"size:100;not null;index" json:"camera"` // Camera name without the _WebRTC suffix
+	UserID      *uint  `gorm:"index" json:"user_id,omitempty"`
+	GroupID     *uint  `gorm:"index" json:"group_id,omitempty"`
+
+	ViewLive   bool `gorm:"not null;default:false" json:"view_live"`   // Snapshots, streams and camera listing
+	ViewEvents bool `gorm:"not null;default:false" json:"view_events"` // Detection events and statistics
+	Notify     bool `gorm:"not null;default:false" json:"notify"`      // Push notifications for detections
+	Control    bool `gorm:"not null;default:false" json:"control"`     // PTZ commands and camera switches
+}
+
+func (CameraPermission) TableName() string {
+	return "camera_permissions"
+}
+
+// Camera actions controlled by CameraPermission
+const (
+	CameraActionViewLive   = "view_live"
+	CameraActionViewEvents = "view_events"
+	CameraActionNotify     = "notify"
+	CameraActionControl    = "control"
+)
+
+// Allows reports whether the permission grants action
+func (cp *CameraPermission) Allows(action string) bool {
+	switch action {
+	case CameraActionViewLive:
+		return cp.ViewLive
+	case CameraActionViewEvents:
+		return cp.ViewEvents
+	case CameraActionNotify:
+		return cp.Notify
+	case CameraActionControl:
+		return cp.Control
+	}
+	return false
+}
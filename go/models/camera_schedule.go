@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// CameraSchedule flips a camera switch of a Frigate instance at recurring times, e.g.
+// indoor detection off on weekdays from 07:30 until sunset. The switch is set to State
+// at Start and back to the opposite at End; a window whose end is not after its start
+// ends the next day.
+type CameraSchedule struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	HouseholdID      uint   `gorm:"not null;index" json:"household_id"`
+	FrigateConnectID uint   `gorm:"not null;index" json:"frigate_connect_id"`
+	Camera           string `gorm:"size:100;not null" json:"camera"` // Camera name without the _WebRTC suffix
+	Switch           string `gorm:"size:20;not null" json:"switch"`  // Frigate MQTT switch, e.g. detect, recordings or notifications
+	State            bool   `gorm:"not null" json:"state"`           // Switch state inside the window
+
+	// Days are comma separated weekdays such as "mon,tue,wed"; empty means every day.
+	// Start and End are "HH:MM" in server local time, or "sunrise"/"sunset" with an
+	// optional offset such as "sunset-30m".
+	Days  string `gorm:"size:30" json:"-"`
+	Start string `gorm:"size:20;not null" json:"start"`
+	End   string `gorm:"size:20;not null" json:"end"`
+
+	Enabled   bool `gorm:"not null;default:true" json:"enabled"`
+	CreatedBy uint `json:"created_by"`
+
+	// A temporary override holds the switch at OverrideState until OverrideUntil,
+	// skipping the transitions in between
+	OverrideState *bool      `json:"override_state,omitempty"`
+	OverrideUntil *time.Time `json:"override_until,omitempty"`
+}
+
+func (CameraSchedule) TableName() string {
+	return "camera_schedules"
+}
+
+// weekdayNames maps the day names used in CameraSchedule.Days to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// IsValidWeekday reports whether day is a weekday name such as "mon"
+func IsValidWeekday(day string) bool {
+	_, ok := weekdayNames[day]
+	return ok
+}
+
+// DayList returns the weekdays the schedule runs on; empty means every day
+func (cs *CameraSchedule) DayList() []string {
+	if cs.Days == "" {
+		return []string{}
+	}
+	return strings.Split(cs.Days, ",")
+}
+
+// RunsOn reports whether a window of the schedule starts on weekday
+func (cs *CameraSchedule) RunsOn(weekday time.Weekday) bool {
+	if cs.Days == "" {
+		return true
+	}
+	for _, day := range cs.DayList() {
+		if weekdayNames[day] == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// OverrideActive reports whether a temporary override holds the switch at t
+func (cs *CameraSchedule) OverrideActive(t time.Time) bool {
+	return cs.OverrideState != nil && cs.OverrideUntil != nil && t.Before(*cs.OverrideUntil)
+}
@@ -13,6 +13,9 @@ type DetectionEvent struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// 来源Frigate的MQTT主题前缀，关联了该前缀实例的家庭成员可见
+	MQTTTopicPrefix string `gorm:"column:mqtt_topic_prefix;type:varchar(100);index;not null;default:frigate" json:"mqtt_topic_prefix"`
+
 	// 事件基本信息
 	EventID string `gorm:"type:varchar(100);uniqueIndex;not null" json:"event_id"` // Frigate事件ID
 	Camera  string `gorm:"type:varchar(100);index;not null" json:"camera"`         // 摄像头名称
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"sotsukenn/go/encryption"
+
+	"gorm.io/gorm/schema"
+)
+
+// EncryptedSerializer encrypts string fields at rest. Use it with the
+// `gorm:"serializer:encrypted"` tag; such fields cannot be used in WHERE clauses.
+type EncryptedSerializer struct{}
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// Scan decrypts the stored value into the field
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported type %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	keyring, err := encryption.Default()
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := keyring.Decrypt(stored)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", field.Name, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+// Value encrypts the field with the active master key
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted field %s must be a string", field.Name)
+	}
+
+	keyring, err := encryption.Default()
+	if err != nil {
+		return nil, err
+	}
+	return keyring.Encrypt(plaintext)
+}
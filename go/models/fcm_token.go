@@ -21,6 +21,9 @@ type FCMToken struct {
 	Token      string `gorm:"size:500;not null;uniqueIndex" json:"token"`
 	DeviceName string `gorm:"size:100" json:"device_name"` // e.g., "iPhone 13", "Pixel 6"
 	IsActive   bool   `gorm:"default:true" json:"is_active"`
+
+	// Token family of the login session that registered this token
+	FamilyID string `gorm:"size:64;index" json:"-"`
 }
 
 func (FCMToken) TableName() string {
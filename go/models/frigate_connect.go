@@ -6,27 +6,41 @@ import (
 	"gorm.io/gorm"
 )
 
-// FrigateConnect stores the mapping between local users and Frigate API tokens
+// FrigateConnect stores the mapping between local users and Frigate API tokens.
+// Each row is one named Frigate instance owned by a household; UserID is the member
+// who linked it.
 type FrigateConnect struct {
 	ID              uint           `gorm:"primarykey" json:"id"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 
-	// Foreign key to local user
-	UserID          uint           `gorm:"uniqueIndex;not null" json:"user_id"`
+	// Foreign key to local user; a user may link several Frigate instances
+	UserID          uint           `gorm:"index;not null" json:"user_id"`
 	User            User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
 
+	// Household that owns the instance; its members share the cameras
+	HouseholdID     *uint          `gorm:"index;uniqueIndex:idx_frigate_connects_household_name,priority:1" json:"household_id"`
+
 	// Frigate configuration
+	Name            string         `gorm:"size:50;not null;default:default;uniqueIndex:idx_frigate_connects_household_name,priority:2" json:"name"` // e.g. "home", "cabin"
 	FrigateURL      string         `gorm:"size:255;not null" json:"frigate_url"`
 	FrigateUsername string         `gorm:"size:100" json:"frigate_username,omitempty"`
+	MQTTTopicPrefix string         `gorm:"column:mqtt_topic_prefix;size:100;not null;default:'';index" json:"mqtt_topic_prefix"` // Frigate's mqtt.topic_prefix, routes events to the household; unique, empty when unrouted
 
 	// Token management (cookie-based authentication)
-	TokenCookie     string         `gorm:"type:text" json:"-"`
+	TokenCookie     string         `gorm:"type:text;serializer:encrypted" json:"-"` // Encrypted at rest when ENCRYPTION_KEYS is set
+	FrigatePassword string         `gorm:"type:text;serializer:encrypted" json:"-"` // Only kept when FRIGATE_STORE_CREDENTIALS is on, for automatic re-login
 	LastVerifiedAt  *time.Time     `json:"last_verified_at,omitempty"`
 	IsActive        bool           `gorm:"default:true" json:"is_active"`
 }
 
+// DefaultFrigateInstanceName is used for the instance created by a Frigate login
+const DefaultFrigateInstanceName = "default"
+
+// DefaultMQTTTopicPrefix is Frigate's default mqtt.topic_prefix
+const DefaultMQTTTopicPrefix = "frigate"
+
 func (FrigateConnect) TableName() string {
 	return "frigate_connects"
 }
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GuestGrant lets someone without an account view selected cameras of one Frigate
+// instance until it expires or is revoked, e.g. a dog-sitter over a weekend.
+// Only the SHA-256 hash of the redeem code is stored.
+type GuestGrant struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	HouseholdID      uint   `gorm:"not null;index" json:"household_id"`
+	FrigateConnectID uint   `gorm:"not null;index" json:"frigate_connect_id"`
+	Label            string `gorm:"size:100;not null" json:"label"` // Who the grant is for, e.g. "Dog sitter"
+	Cameras          string `gorm:"size:1000;not null" json:"-"`    // Comma separated camera names without the _WebRTC suffix
+
+	// Daily access window as "HH:MM" in server local time; both empty means all day.
+	// A window whose end is before its start spans midnight, e.g. 22:00-06:00.
+	HoursStart string `gorm:"size:5" json:"hours_start"`
+	HoursEnd   string `gorm:"size:5" json:"hours_end"`
+
+	CodeHash   string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	CreatedBy  uint       `json:"created_by"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func (GuestGrant) TableName() string {
+	return "guest_grants"
+}
+
+// CameraList returns the cameras the grant gives access to
+func (g *GuestGrant) CameraList() []string {
+	if g.Cameras == "" {
+		return []string{}
+	}
+	return strings.Split(g.Cameras, ",")
+}
+
+// AllowsCamera reports whether camera is one of the granted cameras
+func (g *GuestGrant) AllowsCamera(camera string) bool {
+	for _, granted := range g.CameraList() {
+		if granted == camera {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUsable reports whether the grant is neither revoked nor expired
+func (g *GuestGrant) IsUsable() bool {
+	return g.RevokedAt == nil && time.Now().Before(g.ExpiresAt)
+}
+
+// WithinHours reports whether t falls inside the grant's daily access window
+func (g *GuestGrant) WithinHours(t time.Time) bool {
+	if g.HoursStart == "" && g.HoursEnd == "" {
+		return true
+	}
+	start, errStart := ParseClock(g.HoursStart)
+	end, errEnd := ParseClock(g.HoursEnd)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// ParseClock parses an "HH:MM" time of day into minutes after midnight
+func ParseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Household groups users that share Frigate instances. Cameras, detection events and
+// notifications of a household's instances are only visible to its members.
+type Household struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name      string `gorm:"size:100;not null" json:"name"`
+	CreatedBy uint   `json:"created_by"`
+}
+
+func (Household) TableName() string {
+	return "households"
+}
+
+// HouseholdMember links a user to a household with a household role
+type HouseholdMember struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	HouseholdID uint   `gorm:"not null;uniqueIndex:idx_household_members_household_user,priority:1" json:"household_id"`
+	UserID      uint   `gorm:"not null;index;uniqueIndex:idx_household_members_household_user,priority:2" json:"user_id"`
+	Role        string `gorm:"size:20;not null;default:member" json:"role"`
+	User        User   `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (HouseholdMember) TableName() string {
+	return "household_members"
+}
+
+// Household roles, from most to least privileged
+const (
+	HouseholdRoleOwner  = "owner"  // Manages members, invites and the household itself
+	HouseholdRoleMember = "member" // Manages Frigate instances
+	HouseholdRoleViewer = "viewer" // Read-only access to cameras and events
+)
+
+// IsValidHouseholdRole reports whether role is one of the known household roles
+func IsValidHouseholdRole(role string) bool {
+	switch role {
+	case HouseholdRoleOwner, HouseholdRoleMember, HouseholdRoleViewer:
+		return true
+	}
+	return false
+}
+
+// CanManageInstances reports whether the member may link or change Frigate instances
+func (hm *HouseholdMember) CanManageInstances() bool {
+	return hm.Role == HouseholdRoleOwner || hm.Role == HouseholdRoleMember
+}
+
+// HouseholdInvite is a single-use code that adds the user redeeming it to a household.
+// Only the SHA-256 hash of the code is stored.
+type HouseholdInvite struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	HouseholdID uint       `gorm:"not null;index" json:"household_id"`
+	CodeHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Role        string     `gorm:"size:20;not null" json:"role"` // Household role granted on acceptance
+	CreatedBy   uint       `json:"created_by"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedBy  *uint      `json:"accepted_by,omitempty"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
+
+func (HouseholdInvite) TableName() string {
+	return "household_invites"
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginThrottle tracks failed login attempts for a client IP or a username.
+// Key is "ip:<address>" or "user:<username>".
+type LoginThrottle struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Key           string     `gorm:"size:150;uniqueIndex;not null" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`   // Failures since the last lockout
+	Lockouts      int        `gorm:"not null;default:0" json:"lockouts"`   // Consecutive lockouts, drives the exponential backoff
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LockedUntil   *time.Time `gorm:"index" json:"locked_until,omitempty"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+// IsLocked reports whether the key is currently locked out
+func (lt *LoginThrottle) IsLocked() bool {
+	return lt.LockedUntil != nil && time.Now().Before(*lt.LockedUntil)
+}
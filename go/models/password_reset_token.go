@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordResetToken is a single-use token an administrator hands to a user so they
+// can choose a new password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	CreatedBy uint       `json:"created_by"` // Administrator who issued the token
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a single-use backup code for two-factor authentication.
+// Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID   uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash string     `gorm:"size:64;not null;index" json:"-"`
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
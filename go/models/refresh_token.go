@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a single-use, long-lived token that can be exchanged for a new
+// access token. Every token issued from the same login shares a FamilyID, so a
+// replayed token can revoke the whole chain.
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID  string     `gorm:"size:64;index;not null" json:"family_id"`
+	ExpiresAt time.Time  `gorm:"index;not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`    // Set once the token has been exchanged
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // Set when the family is revoked
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
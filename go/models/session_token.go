@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionToken persists an issued JWT so sessions survive a server restart.
+// Only the SHA-256 hash of the token is stored.
+type SessionToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	FamilyID  string    `gorm:"size:64;index" json:"family_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+}
+
+func (SessionToken) TableName() string {
+	return "session_tokens"
+}
+
+// DBTokenStore is a TokenStore backed by the session_tokens table
+type DBTokenStore struct {
+	db *gorm.DB
+}
+
+func NewDBTokenStore(db *gorm.DB) *DBTokenStore {
+	return &DBTokenStore{db: db}
+}
+
+func (ts *DBTokenStore) Set(token string, info *TokenInfo) error {
+	session := SessionToken{
+		TokenHash: HashToken(token),
+		UserID:    info.UserID,
+		FamilyID:  info.FamilyID,
+		ExpiresAt: info.ExpiresAt,
+	}
+	if err := ts.db.Create(&session).Error; err != nil {
+		return fmt.Errorf("failed to persist session for user %d: %w", info.UserID, err)
+	}
+	return nil
+}
+
+func (ts *DBTokenStore) Get(token string) (*TokenInfo, bool) {
+	var session SessionToken
+	err := ts.db.Where("token_hash = ? AND expires_at > ?", HashToken(token), time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, false
+	}
+
+	return &TokenInfo{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		UserID:    session.UserID,
+		FamilyID:  session.FamilyID,
+	}, true
+}
+
+func (ts *DBTokenStore) Delete(token string) {
+	if err := ts.db.Where("token_hash = ?", HashToken(token)).Delete(&SessionToken{}).Error; err != nil {
+		log.Printf("TokenStore: Failed to delete session: %v", err)
+	}
+}
+
+func (ts *DBTokenStore) DeleteFamily(familyID string) {
+	if err := ts.db.Where("family_id = ?", familyID).Delete(&SessionToken{}).Error; err != nil {
+		log.Printf("TokenStore: Failed to delete sessions of family %s: %v", familyID, err)
+	}
+}
+
+func (ts *DBTokenStore) DeleteUser(userID uint) {
+	if err := ts.db.Where("user_id = ?", userID).Delete(&SessionToken{}).Error; err != nil {
+		log.Printf("TokenStore: Failed to delete sessions of user %d: %v", userID, err)
+	}
+}
+
+func (ts *DBTokenStore) CleanExpired() {
+	result := ts.db.Where("expires_at <= ?", time.Now()).Delete(&SessionToken{})
+	if result.Error != nil {
+		log.Printf("TokenStore: Failed to clean expired sessions: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("TokenStore: Removed %d expired sessions", result.RowsAffected)
+	}
+}
+
+func (ts *DBTokenStore) Clean() {
+	ts.CleanExpired()
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"sync"
 	"time"
 )
@@ -9,39 +11,74 @@ type TokenInfo struct {
 	Token     string
 	ExpiresAt time.Time
 	UserID    uint
+	FamilyID  string // Refresh token family the access token was issued from
 }
 
-type TokenStore struct {
+// TokenStore keeps track of issued JWTs so they can be revoked before they expire
+type TokenStore interface {
+	// Set records an issued token; a token that could not be recorded must not be handed out
+	Set(token string, info *TokenInfo) error
+	Get(token string) (*TokenInfo, bool)
+	Delete(token string)
+	DeleteFamily(familyID string)
+	DeleteUser(userID uint)
+	CleanExpired()
+	Clean()
+}
+
+// MemoryTokenStore is a TokenStore that lives only as long as the process
+type MemoryTokenStore struct {
 	tokens map[string]*TokenInfo
 	mu     sync.RWMutex
 }
 
-func NewTokenStore() *TokenStore {
-	return &TokenStore{
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
 		tokens: make(map[string]*TokenInfo),
 	}
 }
 
-func (ts *TokenStore) Set(token string, info *TokenInfo) {
+func (ts *MemoryTokenStore) Set(token string, info *TokenInfo) error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 	ts.tokens[token] = info
+	return nil
 }
 
-func (ts *TokenStore) Get(token string) (*TokenInfo, bool) {
+func (ts *MemoryTokenStore) Get(token string) (*TokenInfo, bool) {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
 	info, exists := ts.tokens[token]
 	return info, exists
 }
 
-func (ts *TokenStore) Delete(token string) {
+func (ts *MemoryTokenStore) Delete(token string) {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 	delete(ts.tokens, token)
 }
 
-func (ts *TokenStore) CleanExpired() {
+func (ts *MemoryTokenStore) DeleteFamily(familyID string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for token, info := range ts.tokens {
+		if info.FamilyID == familyID {
+			delete(ts.tokens, token)
+		}
+	}
+}
+
+func (ts *MemoryTokenStore) DeleteUser(userID uint) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for token, info := range ts.tokens {
+		if info.UserID == userID {
+			delete(ts.tokens, token)
+		}
+	}
+}
+
+func (ts *MemoryTokenStore) CleanExpired() {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 	now := time.Now()
@@ -52,6 +89,13 @@ func (ts *TokenStore) CleanExpired() {
 	}
 }
 
-func (ts *TokenStore) Clean() {
+func (ts *MemoryTokenStore) Clean() {
 	ts.CleanExpired()
 }
+
+// HashToken returns the hex encoded SHA-256 digest of a secret token,
+// so raw tokens never need to be written to the database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
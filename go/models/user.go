@@ -15,6 +15,32 @@ type User struct {
 	Email     string         `gorm:"size:100" json:"email,omitempty"`
 	Password  string         `gorm:"not null" json:"-"`
 	IsActive  bool           `gorm:"default:true" json:"is_active"`
+	Role      string         `gorm:"size:20;not null;default:member" json:"role"`
+
+	// Set for accounts provisioned from a Frigate login: the local hash is a copy of the
+	// Frigate password and is re-synced when the user logs in with a changed Frigate password
+	FrigateManagedPassword bool `gorm:"default:false" json:"frigate_managed_password"`
+
+	// Two-factor authentication (RFC 6238 TOTP)
+	TOTPSecret   string `gorm:"column:totp_secret;type:text;serializer:encrypted" json:"-"` // Base32 secret, set during enrollment; encrypted at rest when ENCRYPTION_KEYS is set
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `gorm:"column:totp_last_step;default:0" json:"-"` // Last accepted time step, prevents code replay
+}
+
+// User roles, from most to least privileged
+const (
+	RoleAdmin  = "admin"  // Full access including operational endpoints
+	RoleMember = "member" // Regular household member
+	RoleViewer = "viewer" // Read-only access to cameras
+)
+
+// IsValidRole reports whether role is one of the known user roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleMember, RoleViewer:
+		return true
+	}
+	return false
 }
 
 func (User) TableName() string {
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// UserSession describes one login on one device. It shares its FamilyID with the
+// access and refresh tokens issued for that login, and stays active for as long as
+// the family still holds a usable refresh token.
+type UserSession struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	FamilyID   string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	DeviceName string    `gorm:"size:100" json:"device_name"` // Reported by the client at login, e.g. "Pixel 6"
+	IPAddress  string    `gorm:"size:45" json:"ip_address"`   // Most recent client IP
+	UserAgent  string    `gorm:"size:255" json:"user_agent"`
+	LastSeenAt time.Time `gorm:"index" json:"last_seen_at"`
+}
+
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"sotsukenn/go/handlers"
+	"sotsukenn/go/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,7 +11,11 @@ func AuthRoutes(prefix string, r *gin.RouterGroup) {
 	auth := r.Group("/auth")
 	{
 		auth.POST("/login", handlers.Login)
-		auth.POST("/logout", handlers.Logout)
+		auth.POST("/2fa", handlers.VerifyTwoFactorLogin)
+		auth.POST("/refresh", handlers.Refresh)
+		auth.POST("/password/reset", handlers.ResetPasswordWithToken)
+		auth.POST("/guest", handlers.RedeemGuestGrant)
+		auth.POST("/logout", handlers.GuestAuthMiddleware(), handlers.Logout)
 	}
 }
 
@@ -20,6 +25,35 @@ func UserRoutes(prefix string, r *gin.RouterGroup) {
 	{
 		users.GET("/profile", handlers.GetProfile)
 		users.PUT("/profile", handlers.UpdateProfile)
+		users.PUT("/profile/password", handlers.ChangePassword)
+		users.GET("/profile/2fa", handlers.GetTwoFactorStatus)
+		users.POST("/profile/2fa", handlers.SetupTwoFactor)
+		users.POST("/profile/2fa/enable", handlers.EnableTwoFactor)
+		users.DELETE("/profile/2fa", handlers.DisableTwoFactor)
+		users.POST("/profile/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		users.GET("/api-keys", handlers.GetAPIKeys)
+		users.POST("/api-keys", handlers.CreateAPIKey)
+		users.DELETE("/api-keys/:id", handlers.RevokeAPIKey)
+		users.GET("/sessions", handlers.GetSessions)
+		users.DELETE("/sessions", handlers.RevokeOtherSessions)
+		users.DELETE("/sessions/:id", handlers.RevokeSession)
+	}
+}
+
+func AdminRoutes(prefix string, r *gin.RouterGroup) {
+	admin := r.Group("/admin")
+	admin.Use(handlers.AuthMiddleware(), handlers.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", handlers.ListUsers)
+		admin.POST("/users", handlers.CreateUser)
+		admin.GET("/users/:id", handlers.GetUser)
+		admin.PUT("/users/:id", handlers.UpdateUser)
+		admin.DELETE("/users/:id", handlers.DeleteUser)
+		admin.POST("/users/:id/password", handlers.ResetUserPassword)
+		admin.POST("/users/:id/password-reset-token", handlers.IssuePasswordResetToken)
+		admin.DELETE("/users/:id/2fa", handlers.ResetUserTwoFactor)
+		admin.GET("/lockouts", handlers.ListLoginLockouts)
+		admin.DELETE("/lockouts/:id", handlers.ClearLoginLockout)
 	}
 }
 
@@ -28,19 +62,101 @@ func HealthRoutes(prefix string, r *gin.RouterGroup) {
 }
 
 func CameraRoutes(prefix string, r *gin.RouterGroup) {
+	auth := handlers.AuthMiddleware(models.ScopeCamerasRead)
+	guestAuth := handlers.GuestAuthMiddleware(models.ScopeCamerasRead)
+	control := handlers.AuthMiddleware(models.ScopeCamerasControl)
+	canControl := handlers.RequireRole(models.RoleAdmin, models.RoleMember)
+
 	cameras := r.Group("/camera")
-	cameras.Use(handlers.AuthMiddleware())
 	{
-		cameras.GET("/:name/snapshot", handlers.GetCameraSnapshot)
-		cameras.GET("/:name/stream", handlers.GetCameraStream)
+		cameras.GET("/:name/snapshot", auth, handlers.GetCameraSnapshot)
+		cameras.GET("/:name/snapshot.jpg", guestAuth, handlers.GetCameraSnapshotImage)
+		cameras.GET("/:name/stream", auth, handlers.GetCameraStream)
+		cameras.GET("/:name/mjpeg", guestAuth, handlers.GetCameraMJPEG)
+		cameras.GET("/:name/stream.m3u8", guestAuth, handlers.GetCameraHLSPlaylist)
+		cameras.GET("/:name/hls/:file", guestAuth, handlers.GetCameraHLSFile)
+		cameras.GET("/:name/stream.mp4", guestAuth, handlers.GetCameraMP4)
+		cameras.POST("/:name/webrtc", guestAuth, handlers.StartCameraWebRTC)
+		cameras.PATCH("/:name/webrtc/:session", guestAuth, handlers.TrickleCameraWebRTC)
+		cameras.DELETE("/:name/webrtc/:session", guestAuth, handlers.StopCameraWebRTC)
+		cameras.GET("/:name/ptz", auth, handlers.GetCameraPTZ)
+		cameras.GET("/:name/ptz/presets", auth, handlers.GetCameraPTZPresets)
+		cameras.POST("/:name/ptz/move", control, canControl, handlers.MoveCameraPTZ)
+		cameras.POST("/:name/ptz/zoom", control, canControl, handlers.ZoomCameraPTZ)
+		cameras.POST("/:name/ptz/stop", control, canControl, handlers.StopCameraPTZ)
+		cameras.POST("/:name/ptz/preset", control, canControl, handlers.GoToCameraPTZPreset)
+		cameras.GET("/:name/switches", auth, handlers.GetCameraSwitches)
+		cameras.PUT("/:name/switches/:switch", control, canControl, handlers.SetCameraSwitch)
 	}
 }
 
 func CamerasRoutes(prefix string, r *gin.RouterGroup) {
+	auth := handlers.AuthMiddleware(models.ScopeCamerasRead)
+	guestAuth := handlers.GuestAuthMiddleware(models.ScopeCamerasRead)
+
 	cameras := r.Group(prefix)
-	cameras.Use(handlers.AuthMiddleware())
 	{
-		cameras.GET("", handlers.GetCameras)
+		cameras.GET("", auth, handlers.GetCameras)
+		cameras.GET("/mosaic.jpg", guestAuth, handlers.GetCameraMosaic)
+		cameras.GET("/:name", auth, handlers.GetCamera)
+	}
+}
+
+func ScheduleRoutes(prefix string, r *gin.RouterGroup) {
+	auth := handlers.AuthMiddleware(models.ScopeCamerasRead)
+	control := handlers.AuthMiddleware(models.ScopeCamerasControl)
+	canControl := handlers.RequireRole(models.RoleAdmin, models.RoleMember)
+
+	schedules := r.Group("/schedules")
+	{
+		schedules.GET("", auth, handlers.GetCameraSchedules)
+		schedules.GET("/upcoming", auth, handlers.GetUpcomingScheduleTransitions)
+		schedules.POST("", control, canControl, handlers.CreateCameraSchedule)
+		schedules.PUT("/:id", control, canControl, handlers.UpdateCameraSchedule)
+		schedules.DELETE("/:id", control, canControl, handlers.DeleteCameraSchedule)
+		schedules.PUT("/:id/override", control, canControl, handlers.SetCameraScheduleOverride)
+		schedules.DELETE("/:id/override", control, canControl, handlers.ClearCameraScheduleOverride)
+	}
+}
+
+func FrigateRoutes(prefix string, r *gin.RouterGroup) {
+	frigate := r.Group("/frigate")
+	frigate.Use(handlers.AuthMiddleware())
+	canManage := handlers.RequireRole(models.RoleAdmin, models.RoleMember)
+	{
+		frigate.GET("/instances", handlers.GetFrigateInstances)
+		frigate.POST("/instances", canManage, handlers.CreateFrigateInstance)
+		frigate.PUT("/instances/:instance", canManage, handlers.UpdateFrigateInstance)
+		frigate.DELETE("/instances/:instance", canManage, handlers.DeleteFrigateInstance)
+	}
+}
+
+func HouseholdRoutes(prefix string, r *gin.RouterGroup) {
+	households := r.Group("/households")
+	households.Use(handlers.AuthMiddleware())
+	{
+		households.GET("", handlers.GetHouseholds)
+		households.POST("", handlers.RequireRole(models.RoleAdmin, models.RoleMember), handlers.CreateHousehold)
+		households.POST("/join", handlers.JoinHousehold)
+		households.PUT("/:id", handlers.UpdateHousehold)
+		households.DELETE("/:id", handlers.DeleteHousehold)
+		households.GET("/:id/members", handlers.GetHouseholdMembers)
+		households.PUT("/:id/members/:user_id", handlers.UpdateHouseholdMember)
+		households.DELETE("/:id/members/:user_id", handlers.RemoveHouseholdMember)
+		households.GET("/:id/invites", handlers.GetHouseholdInvites)
+		households.POST("/:id/invites", handlers.CreateHouseholdInvite)
+		households.DELETE("/:id/invites/:invite_id", handlers.RevokeHouseholdInvite)
+		households.GET("/:id/groups", handlers.GetHouseholdGroups)
+		households.POST("/:id/groups", handlers.CreateHouseholdGroup)
+		households.DELETE("/:id/groups/:group_id", handlers.DeleteHouseholdGroup)
+		households.POST("/:id/groups/:group_id/members", handlers.AddHouseholdGroupMember)
+		households.DELETE("/:id/groups/:group_id/members/:user_id", handlers.RemoveHouseholdGroupMember)
+		households.GET("/:id/camera-permissions", handlers.GetCameraPermissions)
+		households.PUT("/:id/camera-permissions", handlers.SetCameraPermission)
+		households.DELETE("/:id/camera-permissions/:permission_id", handlers.DeleteCameraPermission)
+		households.GET("/:id/guest-grants", handlers.GetGuestGrants)
+		households.POST("/:id/guest-grants", handlers.CreateGuestGrant)
+		households.DELETE("/:id/guest-grants/:grant_id", handlers.RevokeGuestGrant)
 	}
 }
 
@@ -48,8 +164,8 @@ func MqttRoutes(prefix string, r *gin.RouterGroup) {
 	mqtt := r.Group("/mqtt")
 	mqtt.Use(handlers.AuthMiddleware())
 	{
-		mqtt.POST("/start", handlers.StartMQTT)
-		mqtt.POST("/stop", handlers.StopMQTT)
+		mqtt.POST("/start", handlers.RequireRole(models.RoleAdmin), handlers.StartMQTT)
+		mqtt.POST("/stop", handlers.RequireRole(models.RoleAdmin), handlers.StopMQTT)
 		mqtt.GET("/status", handlers.GetMQTTStatus)
 	}
 }
@@ -59,7 +175,7 @@ func FcmRoutes(prefix string, r *gin.RouterGroup) {
 	fcm.Use(handlers.AuthMiddleware())
 	{
 		fcm.GET("/status", handlers.GetFirebaseStatus)
-		fcm.POST("/test", handlers.SendTestNotification)
+		fcm.POST("/test", handlers.RequireRole(models.RoleAdmin), handlers.SendTestNotification)
 		fcm.GET("/tokens", handlers.GetFCMTokens)
 		fcm.POST("/tokens", handlers.RegisterFCMToken)
 		fcm.PUT("/tokens/:id", handlers.UpdateFCMToken)
@@ -68,13 +184,16 @@ func FcmRoutes(prefix string, r *gin.RouterGroup) {
 }
 
 func ZabbixRoutes(prefix string, r *gin.RouterGroup) {
+	zabbixAuth := handlers.AuthMiddleware(models.ScopeZabbixRead)
+	eventsAuth := handlers.AuthMiddleware(models.ScopeZabbixRead, models.ScopeEventsRead)
+	adminOnly := handlers.RequireRole(models.RoleAdmin)
+
 	zabbix := r.Group("/zabbix")
-	zabbix.Use(handlers.AuthMiddleware())
 	{
-		zabbix.GET("/all", handlers.GetZabbixAllStats)
-		zabbix.GET("/status", handlers.GetZabbixStatus)
-		zabbix.GET("/events/last", handlers.GetZabbixLastEvent)
-		zabbix.GET("/cameras", handlers.GetZabbixCameras)
-		zabbix.GET("/stats/person", handlers.GetZabbixPersonStats)
+		zabbix.GET("/all", zabbixAuth, adminOnly, handlers.GetZabbixAllStats)
+		zabbix.GET("/status", zabbixAuth, adminOnly, handlers.GetZabbixStatus)
+		zabbix.GET("/events/last", eventsAuth, adminOnly, handlers.GetZabbixLastEvent)
+		zabbix.GET("/cameras", zabbixAuth, adminOnly, handlers.GetZabbixCameras)
+		zabbix.GET("/stats/person", eventsAuth, adminOnly, handlers.GetZabbixPersonStats)
 	}
 }
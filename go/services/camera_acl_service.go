@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"sotsukenn/go/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotHouseholdMember is returned when a group or permission targets a user outside the household
+var ErrNotHouseholdMember = errors.New("user is not a member of the household")
+
+// CameraAccess holds the camera permissions that apply to one user in one household
+type CameraAccess struct {
+	unrestricted bool
+	readOnly     bool
+	userRules    map[string]models.CameraPermission
+	groupRules   map[string][]models.CameraPermission
+}
+
+// Allowed reports whether the user may perform action on camera. Cameras without rules are
+// open, except for control: PTZ and switches affect everyone watching, so members need an
+// explicit control rule. Household viewers may never control cameras.
+func (ca *CameraAccess) Allowed(camera, action string) bool {
+	if ca.readOnly && action == models.CameraActionControl {
+		return false
+	}
+	if ca.unrestricted {
+		return true
+	}
+	if rule, ok := ca.userRules[camera]; ok {
+		return rule.Allows(action)
+	}
+	rules, ok := ca.groupRules[camera]
+	if !ok {
+		return action != models.CameraActionControl
+	}
+	// Any of the user's groups may grant the action
+	for _, rule := range rules {
+		if rule.Allows(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Denied returns the cameras on which the user may not perform action
+func (ca *CameraAccess) Denied(action string) []string {
+	denied := []string{}
+	if ca.unrestricted {
+		return denied
+	}
+	for camera := range ca.userRules {
+		if !ca.Allowed(camera, action) {
+			denied = append(denied, camera)
+		}
+	}
+	for camera := range ca.groupRules {
+		if _, overridden := ca.userRules[camera]; !overridden && !ca.Allowed(camera, action) {
+			denied = append(denied, camera)
+		}
+	}
+	return denied
+}
+
+// CameraACLService resolves per-camera permissions of household members
+type CameraACLService struct {
+	db *gorm.DB
+}
+
+// NewCameraACLService creates a camera ACL service
+func NewCameraACLService(db *gorm.DB) *CameraACLService {
+	return &CameraACLService{db: db}
+}
+
+// Access loads the camera permissions of a user in a household. Owners are never restricted.
+func (as *CameraACLService) Access(householdID, userID uint) (*CameraAccess, error) {
+	member, err := NewHouseholdService(as.db).Membership(householdID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &CameraAccess{
+		unrestricted: member.Role == models.HouseholdRoleOwner,
+		readOnly:     member.Role == models.HouseholdRoleViewer,
+		userRules:    make(map[string]models.CameraPermission),
+		groupRules:   make(map[string][]models.CameraPermission),
+	}
+	if access.unrestricted {
+		return access, nil
+	}
+
+	var rules []models.CameraPermission
+	err = as.db.Where("household_id = ? AND (user_id = ? OR group_id IN (?))", householdID, userID,
+		as.db.Model(&models.HouseholdGroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.UserID != nil {
+			access.userRules[rule.Camera] = rule
+		} else {
+			access.groupRules[rule.Camera] = append(access.groupRules[rule.Camera], rule)
+		}
+	}
+	return access, nil
+}
+
+// Allowed reports whether the user may perform action on a camera of the household
+func (as *CameraACLService) Allowed(householdID, userID uint, camera, action string) bool {
+	access, err := as.Access(householdID, userID)
+	if err != nil {
+		return false
+	}
+	return access.Allowed(camera, action)
+}
+
+// EventScope returns the detection events visible to the user across all of their households
+func (as *CameraACLService) EventScope(userID uint) (EventScope, error) {
+	var instances []models.FrigateConnect
+	err := as.db.Where("household_id IN (?) AND is_active = ?", NewHouseholdService(as.db).MemberHouseholdIDs(userID), true).
+		Find(&instances).Error
+	if err != nil {
+		return EventScope{}, err
+	}
+	return as.eventScope(instances, userID)
+}
+
+// InstanceEventScope returns the detection events of one Frigate instance visible to the user
+func (as *CameraACLService) InstanceEventScope(instance *models.FrigateConnect, userID uint) (EventScope, error) {
+	return as.eventScope([]models.FrigateConnect{*instance}, userID)
+}
+
+// eventScope hides a camera of a topic prefix only if every household of the user
+// publishing under that prefix denies the user viewing its events. Prefixes that other
+// households use as well are left out, since their events cannot be attributed.
+func (as *CameraACLService) eventScope(instances []models.FrigateConnect, userID uint) (EventScope, error) {
+	scope := EventScope{TopicPrefixes: []string{}, HiddenCameras: make(map[string][]string)}
+	denials := make(map[string]map[string]int)
+	households := make(map[string]map[uint]bool)
+
+	shared, err := SharedTopicPrefixes(as.db)
+	if err != nil {
+		return EventScope{}, err
+	}
+
+	for _, instance := range instances {
+		prefix := instance.MQTTTopicPrefix
+		if instance.HouseholdID == nil || prefix == "" || shared[prefix] {
+			continue
+		}
+		if households[prefix] == nil {
+			households[prefix] = make(map[uint]bool)
+			denials[prefix] = make(map[string]int)
+			scope.TopicPrefixes = append(scope.TopicPrefixes, prefix)
+		}
+		if households[prefix][*instance.HouseholdID] {
+			continue
+		}
+		households[prefix][*instance.HouseholdID] = true
+
+		access, err := as.Access(*instance.HouseholdID, userID)
+		if err != nil {
+			return EventScope{}, fmt.Errorf("failed to load camera permissions: %w", err)
+		}
+		for _, camera := range access.Denied(models.CameraActionViewEvents) {
+			denials[prefix][camera]++
+		}
+	}
+
+	for prefix, cameras := range denials {
+		for camera, count := range cameras {
+			if count == len(households[prefix]) {
+				scope.HiddenCameras[prefix] = append(scope.HiddenCameras[prefix], camera)
+			}
+		}
+	}
+	return scope, nil
+}
+
+// NotificationRecipients returns the users that should be notified about a detection on
+// camera: members of the household publishing under the topic prefix that allows them
+// notifications. Nobody is notified for a prefix that several households use.
+func (as *CameraACLService) NotificationRecipients(topicPrefix, camera string) ([]uint, error) {
+	routable, err := TopicPrefixRoutable(as.db, topicPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if !routable {
+		return []uint{}, nil
+	}
+
+	var members []models.HouseholdMember
+	err = as.db.Where("household_id IN (?)", as.db.Model(&models.FrigateConnect{}).
+		Select("household_id").
+		Where("mqtt_topic_prefix = ? AND is_active = ?", topicPrefix, true)).
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	userIDs := []uint{}
+	for _, member := range members {
+		if seen[member.UserID] {
+			continue
+		}
+		if as.Allowed(member.HouseholdID, member.UserID, camera, models.CameraActionNotify) {
+			seen[member.UserID] = true
+			userIDs = append(userIDs, member.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+// AddGroupMember adds a household member to a group
+func (as *CameraACLService) AddGroupMember(group *models.HouseholdGroup, userID uint) error {
+	if _, err := NewHouseholdService(as.db).Membership(group.HouseholdID, userID); err != nil {
+		return ErrNotHouseholdMember
+	}
+	var existing models.HouseholdGroupMember
+	if err := as.db.Where("group_id = ? AND user_id = ?", group.ID, userID).First(&existing).Error; err == nil {
+		return nil
+	}
+	return as.db.Create(&models.HouseholdGroupMember{GroupID: group.ID, UserID: userID}).Error
+}
+
+// DeleteGroup deletes a group together with its memberships and camera permissions
+func (as *CameraACLService) DeleteGroup(group *models.HouseholdGroup) error {
+	return as.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.CameraPermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.HouseholdGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+}
+
+// SetPermission creates or replaces the permission of a user or group on a camera
+func (as *CameraACLService) SetPermission(permission *models.CameraPermission) error {
+	if permission.UserID != nil {
+		if _, err := NewHouseholdService(as.db).Membership(permission.HouseholdID, *permission.UserID); err != nil {
+			return ErrNotHouseholdMember
+		}
+	}
+
+	query := as.db.Where("household_id = ? AND camera = ?", permission.HouseholdID, permission.Camera)
+	if permission.UserID != nil {
+		query = query.Where("user_id = ?", *permission.UserID)
+	} else {
+		query = query.Where("group_id = ?", *permission.GroupID)
+	}
+
+	var existing models.CameraPermission
+	if err := query.First(&existing).Error; err == nil {
+		permission.ID = existing.ID
+		permission.CreatedAt = existing.CreatedAt
+	}
+	return as.db.Save(permission).Error
+}
+
+// removeMemberRules drops the group memberships and personal permissions of a user in a household
+func removeMemberRules(tx *gorm.DB, householdID, userID uint) error {
+	if err := tx.Where("household_id = ? AND user_id = ?", householdID, userID).Delete(&models.CameraPermission{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ? AND group_id IN (?)", userID,
+		tx.Model(&models.HouseholdGroup{}).Select("id").Where("household_id = ?", householdID)).
+		Delete(&models.HouseholdGroupMember{}).Error
+}
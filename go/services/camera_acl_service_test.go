@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"sotsukenn/go/models"
+)
+
+func TestCameraAccessDefaults(t *testing.T) {
+	member := &CameraAccess{
+		userRules: map[string]models.CameraPermission{
+			"front": {Camera: "front", ViewLive: true},
+		},
+		groupRules: map[string][]models.CameraPermission{
+			"garage": {{Camera: "garage", ViewLive: true}, {Camera: "garage", ViewLive: true, Control: true}},
+		},
+	}
+
+	for _, tc := range []struct {
+		camera, action string
+		want           bool
+	}{
+		{"yard", models.CameraActionViewLive, true},
+		{"yard", models.CameraActionNotify, true},
+		{"yard", models.CameraActionControl, false}, // control is never granted without a rule
+		{"front", models.CameraActionViewLive, true},
+		{"front", models.CameraActionViewEvents, false},
+		{"front", models.CameraActionControl, false},
+		{"garage", models.CameraActionControl, true}, // any group may grant it
+	} {
+		if got := member.Allowed(tc.camera, tc.action); got != tc.want {
+			t.Errorf("member Allowed(%s, %s) = %v, want %v", tc.camera, tc.action, got, tc.want)
+		}
+	}
+
+	owner := &CameraAccess{unrestricted: true}
+	if !owner.Allowed("yard", models.CameraActionControl) {
+		t.Error("owners should control cameras without rules")
+	}
+
+	viewer := &CameraAccess{readOnly: true, groupRules: member.groupRules}
+	if viewer.Allowed("garage", models.CameraActionControl) {
+		t.Error("viewers should never control cameras")
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/types"
+
+	"gorm.io/gorm"
+)
+
+// StreamRateTracker estimates the bitrate of go2rtc streams from the byte counters of
+// consecutive stream listings
+type StreamRateTracker struct {
+	mu      sync.Mutex
+	samples map[string]streamRateSample
+}
+
+type streamRateSample struct {
+	bytes int64
+	at    time.Time
+	bps   int64
+	known bool
+}
+
+var (
+	streamRates     *StreamRateTracker
+	streamRatesOnce sync.Once
+)
+
+// StreamRates returns the process-wide bitrate tracker
+func StreamRates() *StreamRateTracker {
+	streamRatesOnce.Do(func() {
+		streamRates = NewStreamRateTracker()
+	})
+	return streamRates
+}
+
+// NewStreamRateTracker creates an empty tracker
+func NewStreamRateTracker() *StreamRateTracker {
+	return &StreamRateTracker{samples: make(map[string]streamRateSample)}
+}
+
+// Record stores the byte counters of a stream listing taken at the given time
+func (t *StreamRateTracker) Record(frigateConnectID uint, streams types.Go2RTCStreamsResponse, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name, stream := range streams {
+		key := streamRateKey(frigateConnectID, name)
+		sample := streamRateSample{bytes: receivedBytes(stream), at: at}
+		// A restarted producer resets its counters; wait for the next sample then
+		if prev, ok := t.samples[key]; ok && at.After(prev.at) && sample.bytes >= prev.bytes {
+			sample.bps = (sample.bytes - prev.bytes) * 8 * int64(time.Second) / int64(at.Sub(prev.at))
+			sample.known = true
+		}
+		t.samples[key] = sample
+	}
+}
+
+// Rate returns the last bitrate estimate of a stream in bits per second
+func (t *StreamRateTracker) Rate(frigateConnectID uint, stream string) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sample := t.samples[streamRateKey(frigateConnectID, stream)]
+	return sample.bps, sample.known
+}
+
+func streamRateKey(frigateConnectID uint, stream string) string {
+	return fmt.Sprintf("%d/%s", frigateConnectID, stream)
+}
+
+// receivedBytes sums what the producers of a stream received
+func receivedBytes(stream types.Go2RTCStream) int64 {
+	var total int64
+	for _, producer := range stream.Producers {
+		for _, receiver := range producer.Receivers {
+			total += receiver.Bytes
+		}
+	}
+	return total
+}
+
+// CachedGo2RTCStreams returns the go2rtc streams of an instance through the shared cache.
+// Every real fetch is also recorded for bitrate estimates.
+func CachedGo2RTCStreams(db *gorm.DB, frigateConnect *models.FrigateConnect) (types.Go2RTCStreamsResponse, error) {
+	return FrigateResponses().Go2RTCStreams(frigateConnect.ID, func() (types.Go2RTCStreamsResponse, error) {
+		streams, err := NewFrigateClientForConnect(db, frigateConnect).GetGo2RTCStreamsWithToken(frigateConnect.TokenCookie)
+		if err == nil {
+			StreamRates().Record(frigateConnect.ID, streams, time.Now())
+		}
+		return streams, err
+	})
+}
+
+// CameraDetailService combines the Frigate config of cameras with their go2rtc streams
+type CameraDetailService struct {
+	db *gorm.DB
+}
+
+// NewCameraDetailService creates a camera detail service
+func NewCameraDetailService(db *gorm.DB) *CameraDetailService {
+	return &CameraDetailService{db: db}
+}
+
+// Details returns every camera of an instance. Only the go2rtc stream listing is required;
+// without Frigate's camera config the details lack the enabled state and detection settings.
+func (cs *CameraDetailService) Details(frigateConnect *models.FrigateConnect) ([]types.CameraDetail, error) {
+	streams, err := CachedGo2RTCStreams(cs.db, frigateConnect)
+	if err != nil {
+		return nil, err
+	}
+
+	cameras, config := cs.cameraConfig(frigateConnect)
+
+	details := BuildCameraDetails(streams, cameras, config, func(stream string) (int64, bool) {
+		return StreamRates().Rate(frigateConnect.ID, stream)
+	})
+	for i := range details {
+		details[i].Instance = frigateConnect.Name
+	}
+	return details, nil
+}
+
+// CameraOf returns the camera a go2rtc stream belongs to, grouped as in Details, so that
+// alternate streams such as front_sub are subject to the access rules of their camera.
+// Names that are not streams are returned without the legacy _WebRTC suffix. Without
+// Frigate's config the alternate streams cannot be attributed, so that is an error.
+func (cs *CameraDetailService) CameraOf(frigateConnect *models.FrigateConnect, stream string) (string, error) {
+	streams, err := CachedGo2RTCStreams(cs.db, frigateConnect)
+	if err != nil {
+		return "", err
+	}
+	cameras, config := cs.cameraConfig(frigateConnect)
+	if config == nil {
+		return "", fmt.Errorf("config of instance %s is unavailable", frigateConnect.Name)
+	}
+
+	if camera, ok := streamOwners(streams, cameraNames(cameras, config), config)[stream]; ok {
+		return camera, nil
+	}
+	return strings.TrimSuffix(stream, "_WebRTC"), nil
+}
+
+// cameraConfig fetches the camera listing and config of an instance through the shared
+// cache. Failures are logged and leave the result nil.
+func (cs *CameraDetailService) cameraConfig(frigateConnect *models.FrigateConnect) (types.CamerasResponse, *types.FrigateConfig) {
+	client := NewFrigateClientForConnect(cs.db, frigateConnect)
+	cameras, err := FrigateResponses().Cameras(frigateConnect.ID, func() (types.CamerasResponse, error) {
+		return client.GetCameras(frigateConnect.TokenCookie)
+	})
+	if err != nil {
+		log.Printf("[Cameras] Failed to get cameras of instance %s: %v", frigateConnect.Name, err)
+	}
+	config, err := FrigateResponses().Config(frigateConnect.ID, func() (*types.FrigateConfig, error) {
+		return client.GetConfig(frigateConnect.TokenCookie)
+	})
+	if err != nil {
+		log.Printf("[Cameras] Failed to get config of instance %s: %v", frigateConnect.Name, err)
+	}
+	return cameras, config
+}
+
+// BuildCameraDetails groups go2rtc streams by camera and fills in the camera config.
+// A stream belongs to a camera if it has the camera's name (optionally with the legacy
+// _WebRTC suffix), is one of its live view streams, or is restreamed into its ffmpeg inputs.
+// Streams of unknown cameras become cameras of their own. cameras and config may be nil.
+func BuildCameraDetails(streams types.Go2RTCStreamsResponse, cameras types.CamerasResponse, config *types.FrigateConfig, rate func(stream string) (int64, bool)) []types.CameraDetail {
+	names := cameraNames(cameras, config)
+	owner := streamOwners(streams, names, config)
+	for _, camera := range owner {
+		names[camera] = true
+	}
+
+	byCamera := make(map[string][]string)
+	for stream, camera := range owner {
+		byCamera[camera] = append(byCamera[camera], stream)
+	}
+
+	details := make([]types.CameraDetail, 0, len(names))
+	for _, camera := range sortedKeys(names) {
+		detail := types.CameraDetail{Name: camera, Codecs: []string{}, Streams: []types.CameraStreamDetail{}}
+
+		if cam, ok := cameras[camera]; ok {
+			enabled := cam.Enabled
+			detail.Enabled = &enabled
+			if cam.Models != nil && cam.Models.Object != nil {
+				detail.Detect = &types.CameraDetectDetail{
+					Enabled:         cam.Models.Object.Enabled,
+					ObjectDetection: &cam.Models.Object.Enabled,
+					MaxLabels:       cam.Models.Object.MaxLabels,
+				}
+			}
+		}
+		if config != nil {
+			if cameraConfig, ok := config.Cameras[camera]; ok {
+				enabled := cameraConfig.Enabled
+				detail.Enabled = &enabled
+				if detail.Detect == nil {
+					detail.Detect = &types.CameraDetectDetail{}
+				}
+				detail.Detect.Enabled = cameraConfig.Detect.Enabled
+				detail.Detect.FPS = cameraConfig.Detect.FPS
+				if cameraConfig.Detect.Width > 0 && cameraConfig.Detect.Height > 0 {
+					detail.Resolution = &types.CameraResolution{Width: cameraConfig.Detect.Width, Height: cameraConfig.Detect.Height}
+				}
+			}
+		}
+
+		codecs := make(map[string]bool)
+		var bitrate int64
+		bitrateKnown := false
+		streamNames := byCamera[camera]
+		sort.Strings(streamNames)
+		for _, name := range streamNames {
+			streamDetail := buildStreamDetail(name, streams[name], rate)
+			detail.Streams = append(detail.Streams, streamDetail)
+			detail.Online = detail.Online || streamDetail.Online
+			detail.Viewers += streamDetail.Viewers
+			for _, codec := range streamDetail.Codecs {
+				codecs[codec] = true
+			}
+			if streamDetail.BitrateBps != nil {
+				bitrate += *streamDetail.BitrateBps
+				bitrateKnown = true
+			}
+		}
+		detail.Codecs = append(detail.Codecs, sortedKeys(codecs)...)
+		if bitrateKnown {
+			detail.BitrateBps = &bitrate
+		}
+
+		details = append(details, detail)
+	}
+	return details
+}
+
+// cameraNames returns the cameras known to Frigate from its camera listing and config
+func cameraNames(cameras types.CamerasResponse, config *types.FrigateConfig) map[string]bool {
+	names := make(map[string]bool)
+	for name := range cameras {
+		names[name] = true
+	}
+	if config != nil {
+		for name := range config.Cameras {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// streamOwners maps every go2rtc stream to the camera it belongs to, as described on
+// BuildCameraDetails
+func streamOwners(streams types.Go2RTCStreamsResponse, names map[string]bool, config *types.FrigateConfig) map[string]string {
+	owner := make(map[string]string)
+	claim := func(stream, camera string) {
+		if _, exists := streams[stream]; exists {
+			if _, taken := owner[stream]; !taken {
+				owner[stream] = camera
+			}
+		}
+	}
+	for _, camera := range sortedKeys(names) {
+		claim(camera, camera)
+		claim(camera+"_WebRTC", camera)
+		if config != nil {
+			cameraConfig := config.Cameras[camera]
+			for _, label := range sortedKeys(cameraConfig.Live.Streams) {
+				claim(cameraConfig.Live.Streams[label], camera)
+			}
+			for _, input := range cameraConfig.FFmpeg.Inputs {
+				claim(restreamName(input.Path), camera)
+			}
+		}
+	}
+	for stream := range streams {
+		if _, taken := owner[stream]; !taken {
+			owner[stream] = strings.TrimSuffix(stream, "_WebRTC")
+		}
+	}
+	return owner
+}
+
+func buildStreamDetail(name string, stream types.Go2RTCStream, rate func(stream string) (int64, bool)) types.CameraStreamDetail {
+	detail := types.CameraStreamDetail{
+		Name:    name,
+		Online:  len(stream.Producers) > 0,
+		Codecs:  []string{},
+		Viewers: len(stream.Consumers),
+	}
+
+	codecs := make(map[string]bool)
+	for _, producer := range stream.Producers {
+		if producer.RemoteAddr != "" {
+			detail.RemoteAddrs = append(detail.RemoteAddrs, producer.RemoteAddr)
+		}
+		for _, receiver := range producer.Receivers {
+			if receiver.Codec.CodecName != "" {
+				codecs[receiver.Codec.CodecName] = true
+			}
+		}
+	}
+	detail.Codecs = append(detail.Codecs, sortedKeys(codecs)...)
+
+	if bps, known := rate(name); known && detail.Online {
+		detail.BitrateBps = &bps
+	}
+	return detail
+}
+
+// restreamName returns the go2rtc stream an ffmpeg input restreams, e.g.
+// rtsp://127.0.0.1:8554/front_sub -> front_sub
+func restreamName(input string) string {
+	parsed, err := url.Parse(input)
+	if err != nil || parsed.Scheme != "rtsp" || parsed.Port() != "8554" {
+		return ""
+	}
+	return path.Base(parsed.Path)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sotsukenn/go/types"
+)
+
+func producer(bytes int64, codecs ...string) types.Go2RTCProducer {
+	p := types.Go2RTCProducer{RemoteAddr: "192.168.1.10:554"}
+	for _, codec := range codecs {
+		p.Receivers = append(p.Receivers, types.Go2RTCReceiver{Codec: types.Go2RTCCodec{CodecName: codec}, Bytes: bytes})
+	}
+	return p
+}
+
+func TestBuildCameraDetails(t *testing.T) {
+	streams := types.Go2RTCStreamsResponse{
+		"front":         {Producers: []types.Go2RTCProducer{producer(100, "h264", "aac")}, Consumers: []types.Go2RTCConsumer{{ID: 1}}},
+		"front_sub":     {Producers: []types.Go2RTCProducer{producer(10, "h264")}, Consumers: []types.Go2RTCConsumer{{ID: 2}, {ID: 3}}},
+		"garage_WebRTC": {Producers: []types.Go2RTCProducer{producer(10, "h265")}},
+		"garage":        {},
+		"doorbell_raw":  {Producers: []types.Go2RTCProducer{producer(10, "h264")}},
+		"spare_WebRTC":  {},
+	}
+	cameras := types.CamerasResponse{
+		"garage": {Name: "garage", Enabled: false, Models: &types.CameraModels{Object: &types.ObjectDetection{Enabled: true, MaxLabels: 5}}},
+	}
+	config := &types.FrigateConfig{Cameras: map[string]types.FrigateCameraConfig{
+		"front": {
+			Enabled: true,
+			Detect:  types.FrigateDetectConfig{Enabled: true, Width: 1280, Height: 720, FPS: 5},
+			Live:    types.FrigateLiveConfig{Streams: map[string]string{"Main": "front", "Sub": "front_sub"}},
+		},
+		"doorbell": {
+			Enabled: true,
+			FFmpeg:  types.FrigateFFmpeg{Inputs: []types.FFmpegInput{{Path: "rtsp://127.0.0.1:8554/doorbell_raw"}}},
+		},
+	}}
+	rates := map[string]int64{"front": 4000, "front_sub": 500, "garage_WebRTC": 800}
+	rate := func(stream string) (int64, bool) {
+		bps, ok := rates[stream]
+		return bps, ok
+	}
+
+	details := BuildCameraDetails(streams, cameras, config, rate)
+
+	byName := make(map[string]types.CameraDetail)
+	var names []string
+	for _, detail := range details {
+		names = append(names, detail.Name)
+		byName[detail.Name] = detail
+	}
+	if want := []string{"doorbell", "front", "garage", "spare"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("cameras = %v, want %v", names, want)
+	}
+
+	front := byName["front"]
+	if len(front.Streams) != 2 || front.Streams[0].Name != "front" || front.Streams[1].Name != "front_sub" {
+		t.Errorf("front streams = %+v", front.Streams)
+	}
+	if !front.Online || front.Viewers != 3 || front.Enabled == nil || !*front.Enabled {
+		t.Errorf("front = %+v", front)
+	}
+	if !reflect.DeepEqual(front.Codecs, []string{"aac", "h264"}) {
+		t.Errorf("front codecs = %v", front.Codecs)
+	}
+	if front.BitrateBps == nil || *front.BitrateBps != 4500 {
+		t.Errorf("front bitrate = %v, want 4500", front.BitrateBps)
+	}
+	if front.Resolution == nil || *front.Resolution != (types.CameraResolution{Width: 1280, Height: 720}) {
+		t.Errorf("front resolution = %v", front.Resolution)
+	}
+	if front.Detect == nil || !front.Detect.Enabled || front.Detect.FPS != 5 {
+		t.Errorf("front detect = %+v", front.Detect)
+	}
+
+	garage := byName["garage"]
+	if len(garage.Streams) != 2 || !garage.Online || garage.Enabled == nil || *garage.Enabled {
+		t.Errorf("garage = %+v", garage)
+	}
+	if garage.Detect == nil || garage.Detect.ObjectDetection == nil || !*garage.Detect.ObjectDetection || garage.Detect.MaxLabels != 5 {
+		t.Errorf("garage detect = %+v", garage.Detect)
+	}
+	if garage.BitrateBps == nil || *garage.BitrateBps != 800 {
+		t.Errorf("garage bitrate = %v, want 800", garage.BitrateBps)
+	}
+
+	if doorbell := byName["doorbell"]; len(doorbell.Streams) != 1 || doorbell.Streams[0].Name != "doorbell_raw" || doorbell.BitrateBps != nil {
+		t.Errorf("doorbell = %+v", doorbell)
+	}
+
+	spare := byName["spare"]
+	if spare.Online || spare.Enabled != nil || spare.Detect != nil || len(spare.Streams) != 1 {
+		t.Errorf("spare = %+v", spare)
+	}
+}
+
+func TestBuildCameraDetailsWithoutConfig(t *testing.T) {
+	streams := types.Go2RTCStreamsResponse{
+		"front":        {Producers: []types.Go2RTCProducer{producer(100, "h264")}},
+		"front_WebRTC": {},
+	}
+	details := BuildCameraDetails(streams, nil, nil, func(string) (int64, bool) { return 0, false })
+	if len(details) != 1 || details[0].Name != "front" || len(details[0].Streams) != 2 || !details[0].Online {
+		t.Fatalf("details = %+v", details)
+	}
+	if details[0].Enabled != nil || details[0].BitrateBps != nil {
+		t.Errorf("unknown values are set: %+v", details[0])
+	}
+}
+
+func TestStreamRateTracker(t *testing.T) {
+	tracker := NewStreamRateTracker()
+	start := time.Now()
+	sample := func(bytes int64) types.Go2RTCStreamsResponse {
+		return types.Go2RTCStreamsResponse{"front": {Producers: []types.Go2RTCProducer{producer(bytes, "h264")}}}
+	}
+
+	tracker.Record(1, sample(1000), start)
+	if _, known := tracker.Rate(1, "front"); known {
+		t.Fatal("rate is known after one sample")
+	}
+
+	tracker.Record(1, sample(3000), start.Add(2*time.Second))
+	if bps, known := tracker.Rate(1, "front"); !known || bps != 8000 {
+		t.Errorf("Rate() = %d, %v, want 8000, true", bps, known)
+	}
+	if _, known := tracker.Rate(2, "front"); known {
+		t.Error("rate leaked to another instance")
+	}
+
+	// A restarted producer starts counting from zero again
+	tracker.Record(1, sample(10), start.Add(4*time.Second))
+	if _, known := tracker.Rate(1, "front"); known {
+		t.Error("rate is known after a counter reset")
+	}
+}
+
+func TestStreamOwnersMatchCameraGrouping(t *testing.T) {
+	streams := types.Go2RTCStreamsResponse{
+		"front": {}, "front_sub": {}, "garage_WebRTC": {}, "doorbell_raw": {}, "spare_WebRTC": {},
+	}
+	config := &types.FrigateConfig{Cameras: map[string]types.FrigateCameraConfig{
+		"front":    {Live: types.FrigateLiveConfig{Streams: map[string]string{"Sub": "front_sub"}}},
+		"garage":   {},
+		"doorbell": {FFmpeg: types.FrigateFFmpeg{Inputs: []types.FFmpegInput{{Path: "rtsp://127.0.0.1:8554/doorbell_raw"}}}},
+	}}
+
+	// Access checks resolve streams with these owners, so they must agree with the camera list
+	owners := streamOwners(streams, cameraNames(nil, config), config)
+	want := map[string]string{
+		"front": "front", "front_sub": "front", "garage_WebRTC": "garage", "doorbell_raw": "doorbell", "spare_WebRTC": "spare",
+	}
+	if !reflect.DeepEqual(owners, want) {
+		t.Fatalf("owners = %v, want %v", owners, want)
+	}
+}
@@ -0,0 +1,417 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sotsukenn/go/models"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of schedule times
+const (
+	ScheduleTimeClock   = "clock"
+	ScheduleTimeSunrise = "sunrise"
+	ScheduleTimeSunset  = "sunset"
+)
+
+// maxSunOffset bounds the offset of sunrise and sunset schedule times
+const maxSunOffset = 6 * time.Hour
+
+// scheduleLookback is how far back ScheduledState looks for the last transition; every
+// weekly schedule has one within a week
+const scheduleLookback = 8 * 24 * time.Hour
+
+// cameraSchedulerInterval is how often the scheduler publishes due transitions
+const cameraSchedulerInterval = 30 * time.Second
+
+// ErrLocationNotConfigured is returned for sunrise and sunset schedules without SCHEDULE_LATITUDE
+// and SCHEDULE_LONGITUDE
+var ErrLocationNotConfigured = errors.New("SCHEDULE_LATITUDE and SCHEDULE_LONGITUDE are not configured")
+
+// ScheduleTime is the start or end of a schedule window: a time of day, or sunrise or
+// sunset shifted by Offset
+type ScheduleTime struct {
+	Kind   string
+	Clock  int // Minutes after midnight, for clock times
+	Offset time.Duration
+}
+
+// ParseScheduleTime parses "HH:MM", "sunrise", "sunset" or a sun time with an offset
+// such as "sunset-30m" or "sunrise+1h"
+func ParseScheduleTime(value string) (ScheduleTime, error) {
+	for _, kind := range []string{ScheduleTimeSunrise, ScheduleTimeSunset} {
+		if !strings.HasPrefix(value, kind) {
+			continue
+		}
+		st := ScheduleTime{Kind: kind}
+		if offset := strings.TrimPrefix(value, kind); offset != "" {
+			if offset[0] != '+' && offset[0] != '-' {
+				return ScheduleTime{}, fmt.Errorf("invalid schedule time %q, expected an offset such as %s-30m", value, kind)
+			}
+			d, err := time.ParseDuration(offset)
+			if err != nil || d%time.Minute != 0 || d < -maxSunOffset || d > maxSunOffset {
+				return ScheduleTime{}, fmt.Errorf("invalid offset in %q, expected whole minutes up to %s", value, maxSunOffset)
+			}
+			st.Offset = d
+		}
+		return st, nil
+	}
+
+	clock, err := models.ParseClock(value)
+	if err != nil {
+		return ScheduleTime{}, fmt.Errorf("invalid schedule time %q, expected HH:MM, sunrise or sunset", value)
+	}
+	return ScheduleTime{Kind: ScheduleTimeClock, Clock: clock}, nil
+}
+
+// UsesSun reports whether the time depends on sunrise or sunset
+func (st ScheduleTime) UsesSun() bool {
+	return st.Kind != ScheduleTimeClock
+}
+
+// On returns the time on the calendar day of day. ok is false for sun times when the
+// location is unknown or the sun does not rise or set that day.
+func (st ScheduleTime) On(day time.Time, location *ScheduleLocation) (time.Time, bool) {
+	if st.Kind == ScheduleTimeClock {
+		return time.Date(day.Year(), day.Month(), day.Day(), st.Clock/60, st.Clock%60, 0, 0, day.Location()), true
+	}
+	if location == nil {
+		return time.Time{}, false
+	}
+	sunrise, sunset, ok := SunTimes(day, location.Latitude, location.Longitude)
+	if !ok {
+		return time.Time{}, false
+	}
+	if st.Kind == ScheduleTimeSunrise {
+		return sunrise.Add(st.Offset), true
+	}
+	return sunset.Add(st.Offset), true
+}
+
+// ScheduleLocation is where sunrise and sunset are computed for
+type ScheduleLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// ScheduleLocationFromEnv reads SCHEDULE_LATITUDE and SCHEDULE_LONGITUDE (decimal degrees,
+// east and north positive). It returns nil if either is unset or invalid.
+func ScheduleLocationFromEnv() *ScheduleLocation {
+	latitude, errLat := strconv.ParseFloat(os.Getenv("SCHEDULE_LATITUDE"), 64)
+	longitude, errLon := strconv.ParseFloat(os.Getenv("SCHEDULE_LONGITUDE"), 64)
+	if errLat != nil || errLon != nil || latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return nil
+	}
+	return &ScheduleLocation{Latitude: latitude, Longitude: longitude}
+}
+
+// ScheduleTransition is a switch change planned by a schedule
+type ScheduleTransition struct {
+	ScheduleID uint      `json:"schedule_id"`
+	Camera     string    `json:"camera"`
+	Switch     string    `json:"switch"`
+	State      bool      `json:"state"`
+	At         time.Time `json:"at"`
+	Overridden bool      `json:"overridden"` // Skipped because a temporary override is active
+}
+
+// ScheduleWindow parses the start and end of a schedule
+func ScheduleWindow(schedule *models.CameraSchedule) (start, end ScheduleTime, err error) {
+	if start, err = ParseScheduleTime(schedule.Start); err != nil {
+		return
+	}
+	end, err = ParseScheduleTime(schedule.End)
+	return
+}
+
+// ValidateCameraSchedule checks the switch, days and window of a schedule. Sunrise and
+// sunset times need a configured location.
+func ValidateCameraSchedule(schedule *models.CameraSchedule, location *ScheduleLocation) error {
+	if !IsValidCameraSwitch(schedule.Switch) {
+		return fmt.Errorf("unknown switch %q", schedule.Switch)
+	}
+	for _, day := range schedule.DayList() {
+		if !models.IsValidWeekday(day) {
+			return fmt.Errorf("unknown day %q, expected sun, mon, tue, wed, thu, fri or sat", day)
+		}
+	}
+	start, end, err := ScheduleWindow(schedule)
+	if err != nil {
+		return err
+	}
+	if (start.UsesSun() || end.UsesSun()) && location == nil {
+		return ErrLocationNotConfigured
+	}
+	if schedule.Start == schedule.End {
+		return errors.New("start and end must differ")
+	}
+	return nil
+}
+
+// ScheduleTransitions returns the transitions of a schedule in (from, to], oldest first.
+// Windows start on the schedule's days in the time zone of from; windows whose sun times
+// cannot be computed are skipped.
+func ScheduleTransitions(schedule *models.CameraSchedule, from, to time.Time, location *ScheduleLocation) ([]ScheduleTransition, error) {
+	start, end, err := ScheduleWindow(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions := []ScheduleTransition{}
+	add := func(at time.Time, state bool) {
+		if at.After(from) && !at.After(to) {
+			transitions = append(transitions, ScheduleTransition{
+				ScheduleID: schedule.ID,
+				Camera:     schedule.Camera,
+				Switch:     schedule.Switch,
+				State:      state,
+				At:         at,
+				Overridden: schedule.OverrideActive(at),
+			})
+		}
+	}
+
+	// A window starting the day before from may still end inside the range
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, -1)
+	for !day.After(to) {
+		if schedule.RunsOn(day.Weekday()) {
+			startAt, startOK := start.On(day, location)
+			endAt, endOK := end.On(day, location)
+			if startOK && endOK && !endAt.After(startAt) {
+				endAt, endOK = end.On(day.AddDate(0, 0, 1), location)
+			}
+			if startOK && endOK {
+				add(startAt, schedule.State)
+				add(endAt, !schedule.State)
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+	return transitions, nil
+}
+
+// ScheduledState returns the state the schedule gives its switch at t, ignoring overrides:
+// State inside a window, the opposite outside
+func ScheduledState(schedule *models.CameraSchedule, t time.Time, location *ScheduleLocation) (bool, error) {
+	transitions, err := ScheduleTransitions(schedule, t.Add(-scheduleLookback), t, location)
+	if err != nil {
+		return false, err
+	}
+	if len(transitions) == 0 {
+		return !schedule.State, nil
+	}
+	return transitions[len(transitions)-1].State, nil
+}
+
+// CameraScheduler publishes the transitions of enabled schedules through the MQTT client.
+// Transitions are edge-triggered: a switch changed by hand keeps its state until the
+// schedule's next transition, or until the MQTT client reconnects.
+type CameraScheduler struct {
+	db       *gorm.DB
+	client   *MQTTClient
+	location *ScheduleLocation
+
+	mu      sync.Mutex
+	lastRun time.Time
+	pending map[uint]bool // Switch states that failed to publish, by schedule ID
+}
+
+// NewCameraScheduler creates a scheduler; sun times use ScheduleLocationFromEnv
+func NewCameraScheduler(db *gorm.DB, client *MQTTClient) *CameraScheduler {
+	return &CameraScheduler{db: db, client: client, location: ScheduleLocationFromEnv(), pending: make(map[uint]bool)}
+}
+
+// Start runs the scheduler in the background. Whenever the MQTT client connects, including
+// at startup, every enabled schedule publishes its current state again, which catches up
+// on transitions missed while the broker or the server was down.
+func (cs *CameraScheduler) Start() {
+	cs.mu.Lock()
+	cs.lastRun = time.Now()
+	cs.mu.Unlock()
+
+	cs.client.SetOnConnect(func() {
+		cs.Reapply(time.Now())
+	})
+	if cs.client.IsConnected() {
+		go cs.Reapply(time.Now())
+	}
+
+	go func() {
+		ticker := time.NewTicker(cameraSchedulerInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			cs.Run(now)
+		}
+	}()
+}
+
+// Run publishes the transitions due since the previous run and ends expired overrides.
+// A state that fails to publish, e.g. while MQTT is down, is retried on every run until
+// it succeeds or a newer transition replaces it.
+func (cs *CameraScheduler) Run(now time.Time) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var schedules []models.CameraSchedule
+	err := cs.db.Where("enabled = ? OR override_until IS NOT NULL", true).Order("id ASC").Find(&schedules).Error
+	if err != nil {
+		log.Printf("Schedule: Failed to load schedules: %v", err)
+		return
+	}
+
+	pending := make(map[uint]bool)
+	for i := range schedules {
+		schedule := &schedules[i]
+		state, due := cs.pending[schedule.ID]
+
+		if schedule.OverrideUntil != nil && !now.Before(*schedule.OverrideUntil) {
+			// An ended override hands the switch back to the schedule
+			if err := cs.endOverride(schedule); err != nil {
+				log.Printf("Schedule: Failed to end override of schedule %d: %v", schedule.ID, err)
+				continue
+			}
+			if !schedule.Enabled {
+				continue
+			}
+			if state, err = ScheduledState(schedule, now, cs.location); err != nil {
+				log.Printf("Schedule: Invalid schedule %d: %v", schedule.ID, err)
+				continue
+			}
+			due = true
+		} else {
+			if !schedule.Enabled || schedule.OverrideActive(now) {
+				continue
+			}
+			transitions, err := ScheduleTransitions(schedule, cs.lastRun, now, cs.location)
+			if err != nil {
+				log.Printf("Schedule: Invalid schedule %d: %v", schedule.ID, err)
+				continue
+			}
+			// Only the latest state matters if several transitions fell due at once
+			if len(transitions) > 0 {
+				state, due = transitions[len(transitions)-1].State, true
+			}
+		}
+		if !due {
+			continue
+		}
+
+		if err := cs.apply(schedule, state); err != nil {
+			log.Printf("Schedule: Failed to apply schedule %d, will retry: %v", schedule.ID, err)
+			pending[schedule.ID] = state
+		}
+	}
+	cs.pending = pending
+	cs.lastRun = now
+}
+
+// Reapply publishes the state every enabled schedule gives its switch at now, or the
+// override state while an override is active. States that fail to publish are left for
+// Run to retry.
+func (cs *CameraScheduler) Reapply(now time.Time) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var schedules []models.CameraSchedule
+	if err := cs.db.Where("enabled = ?", true).Order("id ASC").Find(&schedules).Error; err != nil {
+		log.Printf("Schedule: Failed to load schedules: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		var state bool
+		if schedule.OverrideActive(now) {
+			state = *schedule.OverrideState
+		} else {
+			scheduled, err := ScheduledState(schedule, now, cs.location)
+			if err != nil {
+				log.Printf("Schedule: Invalid schedule %d: %v", schedule.ID, err)
+				continue
+			}
+			state = scheduled
+		}
+
+		if err := cs.apply(schedule, state); err != nil {
+			log.Printf("Schedule: Failed to reapply schedule %d, will retry: %v", schedule.ID, err)
+			cs.pending[schedule.ID] = state
+			continue
+		}
+		delete(cs.pending, schedule.ID)
+	}
+}
+
+// SetOverride holds the switch of a schedule at state until the given time and publishes
+// it right away
+func (cs *CameraScheduler) SetOverride(schedule *models.CameraSchedule, state bool, until time.Time) error {
+	if err := cs.apply(schedule, state); err != nil {
+		return err
+	}
+	schedule.OverrideState = &state
+	schedule.OverrideUntil = &until
+	return cs.db.Model(schedule).Updates(map[string]interface{}{"override_state": state, "override_until": until}).Error
+}
+
+// ClearOverride ends the override of a schedule and restores the state the schedule gives
+// the switch at now
+func (cs *CameraScheduler) ClearOverride(schedule *models.CameraSchedule, now time.Time) error {
+	if err := cs.endOverride(schedule); err != nil {
+		return err
+	}
+	if !schedule.Enabled {
+		return nil
+	}
+
+	state, err := ScheduledState(schedule, now, cs.location)
+	if err != nil {
+		return err
+	}
+	return cs.apply(schedule, state)
+}
+
+// endOverride removes the override of a schedule without publishing anything
+func (cs *CameraScheduler) endOverride(schedule *models.CameraSchedule) error {
+	if err := cs.db.Model(schedule).Updates(map[string]interface{}{"override_state": nil, "override_until": nil}).Error; err != nil {
+		return err
+	}
+	schedule.OverrideState = nil
+	schedule.OverrideUntil = nil
+	return nil
+}
+
+// apply publishes a switch state for the schedule's camera
+func (cs *CameraScheduler) apply(schedule *models.CameraSchedule, state bool) error {
+	var frigateConnect models.FrigateConnect
+	err := cs.db.Where("id = ? AND household_id = ? AND is_active = ?", schedule.FrigateConnectID, schedule.HouseholdID, true).
+		First(&frigateConnect).Error
+	if err != nil {
+		return fmt.Errorf("frigate instance %d not found: %w", schedule.FrigateConnectID, err)
+	}
+	if err := CheckPublishPrefix(cs.db, &frigateConnect); err != nil {
+		return err
+	}
+
+	if err := SetCameraSwitch(cs.client, &frigateConnect, schedule.Camera, schedule.Switch, state); err != nil {
+		return err
+	}
+	log.Printf("Schedule: Set %s of %s/%s to %v (schedule %d)", schedule.Switch, frigateConnect.Name, schedule.Camera, state, schedule.ID)
+	return nil
+}
+
+// Location returns where sunrise and sunset are computed for, or nil if not configured
+func (cs *CameraScheduler) Location() *ScheduleLocation {
+	return cs.location
+}
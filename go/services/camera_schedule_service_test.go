@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/types"
+)
+
+func TestParseScheduleTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    ScheduleTime
+		wantErr bool
+	}{
+		{value: "07:30", want: ScheduleTime{Kind: ScheduleTimeClock, Clock: 450}},
+		{value: "sunset", want: ScheduleTime{Kind: ScheduleTimeSunset}},
+		{value: "sunset-30m", want: ScheduleTime{Kind: ScheduleTimeSunset, Offset: -30 * time.Minute}},
+		{value: "sunrise+1h", want: ScheduleTime{Kind: ScheduleTimeSunrise, Offset: time.Hour}},
+		{value: "sunrise30m", wantErr: true},
+		{value: "sunrise+30s", wantErr: true},
+		{value: "sunset+12h", wantErr: true},
+		{value: "25:00", wantErr: true},
+		{value: "noon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseScheduleTime(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseScheduleTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseScheduleTime(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCameraSchedule(t *testing.T) {
+	location := &ScheduleLocation{Latitude: 35.68, Longitude: 139.69}
+	valid := models.CameraSchedule{Switch: CameraSwitchDetect, Days: "mon,fri", Start: "07:30", End: "sunset"}
+
+	tests := []struct {
+		name     string
+		modify   func(s *models.CameraSchedule)
+		location *ScheduleLocation
+		wantErr  error
+		wantOK   bool
+	}{
+		{name: "valid", modify: func(s *models.CameraSchedule) {}, location: location, wantOK: true},
+		{name: "sun without location", modify: func(s *models.CameraSchedule) {}, wantErr: ErrLocationNotConfigured},
+		{name: "clock without location", modify: func(s *models.CameraSchedule) { s.End = "18:00" }, wantOK: true},
+		{name: "unknown switch", modify: func(s *models.CameraSchedule) { s.Switch = "audio" }, location: location},
+		{name: "unknown day", modify: func(s *models.CameraSchedule) { s.Days = "mon,funday" }, location: location},
+		{name: "empty window", modify: func(s *models.CameraSchedule) { s.End = "07:30" }, location: location},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := valid
+			tt.modify(&schedule)
+			err := ValidateCameraSchedule(&schedule, tt.location)
+			if tt.wantOK {
+				if err != nil {
+					t.Errorf("ValidateCameraSchedule() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("ValidateCameraSchedule() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleTransitions(t *testing.T) {
+	zone := time.FixedZone("JST", 9*3600)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, zone)
+	}
+
+	// Detection off on weekday daytimes; 2026-10-16 is a Friday
+	schedule := &models.CameraSchedule{ID: 1, Camera: "living", Switch: CameraSwitchDetect, State: false, Days: "mon,tue,wed,thu,fri", Start: "08:00", End: "18:00"}
+	transitions, err := ScheduleTransitions(schedule, at(16, 0, 0), at(20, 0, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		at    time.Time
+		state bool
+	}{
+		{at(16, 8, 0), false},
+		{at(16, 18, 0), true},
+		{at(19, 8, 0), false},
+		{at(19, 18, 0), true},
+	}
+	if len(transitions) != len(want) {
+		t.Fatalf("got %d transitions, want %d: %+v", len(transitions), len(want), transitions)
+	}
+	for i, w := range want {
+		if !transitions[i].At.Equal(w.at) || transitions[i].State != w.state {
+			t.Errorf("transition %d = %v %v, want %v %v", i, transitions[i].At, transitions[i].State, w.at, w.state)
+		}
+	}
+
+	state, err := ScheduledState(schedule, at(17, 12, 0), nil)
+	if err != nil || !state {
+		t.Errorf("ScheduledState(Saturday noon) = %v, %v, want true", state, err)
+	}
+	state, err = ScheduledState(schedule, at(16, 12, 0), nil)
+	if err != nil || state {
+		t.Errorf("ScheduledState(Friday noon) = %v, %v, want false", state, err)
+	}
+}
+
+func TestScheduleTransitionsOvernightAndOverride(t *testing.T) {
+	zone := time.FixedZone("JST", 9*3600)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, zone)
+	}
+
+	// Recordings on overnight; the window starting on the 15th ends inside the range
+	until := at(16, 23, 0)
+	state := false
+	schedule := &models.CameraSchedule{ID: 2, Camera: "garden", Switch: CameraSwitchRecordings, State: true, Start: "22:00", End: "06:00", OverrideState: &state, OverrideUntil: &until}
+	transitions, err := ScheduleTransitions(schedule, at(16, 0, 0), at(17, 12, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transitions) != 3 {
+		t.Fatalf("got %d transitions, want 3: %+v", len(transitions), transitions)
+	}
+	if !transitions[0].At.Equal(at(16, 6, 0)) || transitions[0].State || !transitions[0].Overridden {
+		t.Errorf("first transition = %+v, want overridden off at 06:00", transitions[0])
+	}
+	if !transitions[1].At.Equal(at(16, 22, 0)) || !transitions[1].State || !transitions[1].Overridden {
+		t.Errorf("second transition = %+v, want overridden on at 22:00", transitions[1])
+	}
+	if !transitions[2].At.Equal(at(17, 6, 0)) || transitions[2].State || transitions[2].Overridden {
+		t.Errorf("third transition = %+v, want off at 06:00 the next day", transitions[2])
+	}
+}
+
+func TestScheduleTransitionsSun(t *testing.T) {
+	zone := time.FixedZone("JST", 9*3600)
+	location := &ScheduleLocation{Latitude: 35.6895, Longitude: 139.6917}
+	schedule := &models.CameraSchedule{ID: 3, Camera: "porch", Switch: CameraSwitchDetect, State: true, Start: "sunset-30m", End: "sunrise"}
+
+	from := time.Date(2026, 6, 21, 12, 0, 0, 0, zone)
+	transitions, err := ScheduleTransitions(schedule, from, from.Add(24*time.Hour), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transitions) != 2 {
+		t.Fatalf("got %d transitions, want 2: %+v", len(transitions), transitions)
+	}
+	_, sunset, _ := SunTimes(from, location.Latitude, location.Longitude)
+	sunrise, _, _ := SunTimes(from.AddDate(0, 0, 1), location.Latitude, location.Longitude)
+	if !transitions[0].At.Equal(sunset.Add(-30*time.Minute)) || !transitions[0].State {
+		t.Errorf("first transition = %+v, want on 30 minutes before sunset %v", transitions[0], sunset)
+	}
+	if !transitions[1].At.Equal(sunrise) || transitions[1].State {
+		t.Errorf("second transition = %+v, want off at sunrise %v", transitions[1], sunrise)
+	}
+}
+
+// newSchedulerTest returns a scheduler whose MQTT client never connects, so every publish
+// fails, and a detect schedule that turns detection off from 08:00 to 18:00
+func newSchedulerTest(t *testing.T) (*CameraScheduler, *models.CameraSchedule) {
+	t.Helper()
+	db := newTestDB(t, &models.FrigateConnect{}, &models.CameraSchedule{})
+	household := uint(1)
+	instance := models.FrigateConnect{UserID: 1, HouseholdID: &household, Name: "home", FrigateURL: "http://home", MQTTTopicPrefix: "frigate", IsActive: true}
+	if err := db.Create(&instance).Error; err != nil {
+		t.Fatal(err)
+	}
+	schedule := models.CameraSchedule{HouseholdID: household, FrigateConnectID: instance.ID, Camera: "front",
+		Switch: CameraSwitchDetect, State: false, Start: "08:00", End: "18:00", Enabled: true}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	return NewCameraScheduler(db, NewMQTTClient(types.MQTTConfig{})), &schedule
+}
+
+func TestCameraSchedulerRetriesFailedTransitions(t *testing.T) {
+	cs, schedule := newSchedulerTest(t)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 19, hour, minute, 0, 0, time.Local)
+	}
+
+	cs.lastRun = at(7, 59)
+	cs.Run(at(8, 0))
+	if state, ok := cs.pending[schedule.ID]; !ok || state {
+		t.Fatalf("pending = %v, want the failed 08:00 transition to detect off", cs.pending)
+	}
+
+	// No transition falls due, but the failed one is kept for the next attempt
+	cs.Run(at(8, 1))
+	if state, ok := cs.pending[schedule.ID]; !ok || state {
+		t.Fatalf("pending after a quiet run = %v, want detect off still pending", cs.pending)
+	}
+
+	// A newer transition replaces the pending state
+	cs.lastRun = at(17, 59)
+	cs.Run(at(18, 0))
+	if state, ok := cs.pending[schedule.ID]; !ok || !state {
+		t.Fatalf("pending after 18:00 = %v, want detect on", cs.pending)
+	}
+
+	cs.db.Model(schedule).Update("enabled", false)
+	cs.Run(at(18, 1))
+	if len(cs.pending) != 0 {
+		t.Fatalf("pending of a disabled schedule = %v, want none", cs.pending)
+	}
+}
+
+func TestCameraSchedulerReapply(t *testing.T) {
+	cs, schedule := newSchedulerTest(t)
+	noon := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+
+	cs.Reapply(noon)
+	if state, ok := cs.pending[schedule.ID]; !ok || state {
+		t.Fatalf("pending = %v, want the scheduled detect off", cs.pending)
+	}
+
+	// An active override is reapplied instead of the schedule
+	on, until := true, noon.Add(time.Hour)
+	cs.db.Model(schedule).Updates(map[string]interface{}{"override_state": on, "override_until": until})
+	cs.Reapply(noon)
+	if state := cs.pending[schedule.ID]; !state {
+		t.Fatalf("pending = %v, want the override state", cs.pending)
+	}
+}
+
+func TestCameraSchedulerSkipsSharedTopicPrefix(t *testing.T) {
+	cs, schedule := newSchedulerTest(t)
+	other := uint(2)
+	cs.db.Create(&models.FrigateConnect{UserID: 2, HouseholdID: &other, Name: "home", FrigateURL: "http://other", MQTTTopicPrefix: "frigate", IsActive: true})
+
+	if err := cs.apply(schedule, true); !errors.Is(err, ErrTopicPrefixNotRoutable) {
+		t.Fatalf("apply under a shared prefix = %v, want ErrTopicPrefixNotRoutable", err)
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"sotsukenn/go/models"
+)
+
+// Camera switches Frigate accepts on <topic_prefix>/<camera>/<switch>/set and reports on
+// <topic_prefix>/<camera>/<switch>/state
+const (
+	CameraSwitchDetect        = "detect"
+	CameraSwitchRecordings    = "recordings"
+	CameraSwitchSnapshots     = "snapshots"
+	CameraSwitchMotion        = "motion"
+	CameraSwitchNotifications = "notifications"
+)
+
+// CameraSwitchNames lists the camera switches in display order
+var CameraSwitchNames = []string{CameraSwitchDetect, CameraSwitchRecordings, CameraSwitchSnapshots, CameraSwitchMotion, CameraSwitchNotifications}
+
+// IsValidCameraSwitch reports whether name is one of the known camera switches
+func IsValidCameraSwitch(name string) bool {
+	switch name {
+	case CameraSwitchDetect, CameraSwitchRecordings, CameraSwitchSnapshots, CameraSwitchMotion, CameraSwitchNotifications:
+		return true
+	}
+	return false
+}
+
+// CameraSwitchState is the last state Frigate reported for a switch
+type CameraSwitchState struct {
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CameraSwitchTracker remembers the switch states Frigate publishes on its /state topics
+type CameraSwitchTracker struct {
+	mu     sync.RWMutex
+	states map[string]CameraSwitchState
+}
+
+var (
+	cameraSwitches     *CameraSwitchTracker
+	cameraSwitchesOnce sync.Once
+)
+
+// CameraSwitches returns the process-wide switch state tracker
+func CameraSwitches() *CameraSwitchTracker {
+	cameraSwitchesOnce.Do(func() {
+		cameraSwitches = NewCameraSwitchTracker()
+	})
+	return cameraSwitches
+}
+
+// NewCameraSwitchTracker creates an empty tracker
+func NewCameraSwitchTracker() *CameraSwitchTracker {
+	return &CameraSwitchTracker{states: make(map[string]CameraSwitchState)}
+}
+
+// HandleState records a message from a <topic_prefix>/<camera>/<switch>/state topic.
+// It reports false for topics and payloads that are not camera switch states.
+func (t *CameraSwitchTracker) HandleState(topic, payload string, at time.Time) bool {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 4 || parts[len(parts)-1] != "state" {
+		return false
+	}
+	name := parts[len(parts)-2]
+	camera := parts[len(parts)-3]
+	topicPrefix := strings.Join(parts[:len(parts)-3], "/")
+	if !IsValidCameraSwitch(name) {
+		return false
+	}
+
+	var enabled bool
+	switch strings.ToUpper(strings.TrimSpace(payload)) {
+	case "ON":
+		enabled = true
+	case "OFF":
+		enabled = false
+	default:
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.states[cameraSwitchKey(topicPrefix, camera, name)] = CameraSwitchState{Enabled: enabled, UpdatedAt: at}
+	return true
+}
+
+// States returns the known switch states of a camera; switches Frigate has not reported
+// yet are nil
+func (t *CameraSwitchTracker) States(topicPrefix, camera string) map[string]*CameraSwitchState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	states := make(map[string]*CameraSwitchState, len(CameraSwitchNames))
+	for _, name := range CameraSwitchNames {
+		if state, ok := t.states[cameraSwitchKey(topicPrefix, camera, name)]; ok {
+			states[name] = &state
+		} else {
+			states[name] = nil
+		}
+	}
+	return states
+}
+
+func cameraSwitchKey(topicPrefix, camera, name string) string {
+	return topicPrefix + "/" + camera + "/" + name
+}
+
+// CameraSwitchSetTopic returns the MQTT topic Frigate listens on to change a switch of a camera
+func CameraSwitchSetTopic(frigateConnect *models.FrigateConnect, camera, name string) string {
+	return fmt.Sprintf("%s/%s/%s/set", frigateConnect.MQTTTopicPrefix, camera, name)
+}
+
+// SetCameraSwitch asks Frigate to turn a switch of a camera on or off. The new state is
+// tracked once Frigate confirms it on the /state topic.
+func SetCameraSwitch(client *MQTTClient, frigateConnect *models.FrigateConnect, camera, name string, enabled bool) error {
+	payload := "OFF"
+	if enabled {
+		payload = "ON"
+	}
+	return client.Publish(CameraSwitchSetTopic(frigateConnect, camera, name), payload)
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCameraSwitchTrackerHandleState(t *testing.T) {
+	tracker := NewCameraSwitchTracker()
+	at := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		topic   string
+		payload string
+		want    bool
+	}{
+		{topic: "frigate/front/detect/state", payload: "OFF", want: true},
+		{topic: "frigate/front/recordings/state", payload: "ON", want: true},
+		{topic: "shop/front/detect/state", payload: "ON", want: true},
+		{topic: "frigate/front/ptz_autotracker/state", payload: "ON", want: false},
+		{topic: "frigate/front/motion/state", payload: "maybe", want: false},
+		{topic: "frigate/front/motion/set", payload: "ON", want: false},
+		{topic: "detect/state", payload: "ON", want: false},
+	}
+	for _, tt := range tests {
+		if got := tracker.HandleState(tt.topic, tt.payload, at); got != tt.want {
+			t.Errorf("HandleState(%q, %q) = %v, want %v", tt.topic, tt.payload, got, tt.want)
+		}
+	}
+
+	states := tracker.States("frigate", "front")
+	if len(states) != len(CameraSwitchNames) {
+		t.Fatalf("len(States) = %d, want %d", len(states), len(CameraSwitchNames))
+	}
+	if state := states[CameraSwitchDetect]; state == nil || state.Enabled || !state.UpdatedAt.Equal(at) {
+		t.Errorf("detect = %+v, want off at %v", state, at)
+	}
+	if state := states[CameraSwitchRecordings]; state == nil || !state.Enabled {
+		t.Errorf("recordings = %+v, want on", state)
+	}
+	if state := states[CameraSwitchMotion]; state != nil {
+		t.Errorf("motion = %+v, want unknown", state)
+	}
+
+	// Instances with another topic prefix are tracked separately
+	if state := tracker.States("shop", "front")[CameraSwitchDetect]; state == nil || !state.Enabled {
+		t.Errorf("shop detect = %+v, want on", state)
+	}
+}
+
+func TestSwitchStateTopic(t *testing.T) {
+	tests := map[string]string{
+		"frigate/events": "frigate/+/+/state",
+		"+/events":       "+/+/+/state",
+	}
+	for eventsTopic, want := range tests {
+		if got := switchStateTopic(eventsTopic); got != want {
+			t.Errorf("switchStateTopic(%q) = %q, want %q", eventsTopic, got, want)
+		}
+	}
+}
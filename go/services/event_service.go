@@ -12,13 +12,30 @@ type EventService struct {
 	db *gorm.DB
 }
 
+// EventScope 限定可见的事件：来自 TopicPrefixes 的事件，排除 HiddenCameras 中按主题前缀列出的摄像头
+type EventScope struct {
+	TopicPrefixes []string
+	HiddenCameras map[string][]string
+}
+
+// apply 将可见范围加入查询条件
+func (s EventScope) apply(query *gorm.DB) *gorm.DB {
+	query = query.Where("mqtt_topic_prefix IN ?", s.TopicPrefixes)
+	for prefix, cameras := range s.HiddenCameras {
+		if len(cameras) > 0 {
+			query = query.Where("NOT (mqtt_topic_prefix = ? AND camera IN ?)", prefix, cameras)
+		}
+	}
+	return query
+}
+
 // NewEventService 创建事件服务
 func NewEventService(db *gorm.DB) *EventService {
 	return &EventService{db: db}
 }
 
-// SaveDetectionEvent 保存检测事件
-func (es *EventService) SaveDetectionEvent(event models.FrigateEvent) error {
+// SaveDetectionEvent 保存检测事件，topicPrefix 为发布事件的Frigate的MQTT主题前缀
+func (es *EventService) SaveDetectionEvent(event models.FrigateEvent, topicPrefix string) error {
 	// 只处理 "new" 类型的事件
 	if event.Type != models.EventTypeNew {
 		return nil
@@ -51,25 +68,26 @@ func (es *EventService) SaveDetectionEvent(event models.FrigateEvent) error {
 
 	// 创建新的检测事件记录
 	detectionEvent := models.DetectionEvent{
-		EventID:    event.After.ID,
-		Camera:     event.After.Camera,
-		Label:      event.After.Label,
-		SubLabel:   subLabel,
-		StartTime:  event.After.StartTime,
-		EndTime:    event.After.EndTime,
-		TopScore:   event.After.TopScore,
-		Score:      event.After.Score,
-		Active:     event.After.Active,
-		Stationary: event.After.Stationary,
-		IsCurrent:  true,
+		MQTTTopicPrefix: topicPrefix,
+		EventID:         event.After.ID,
+		Camera:          event.After.Camera,
+		Label:           event.After.Label,
+		SubLabel:        subLabel,
+		StartTime:       event.After.StartTime,
+		EndTime:         event.After.EndTime,
+		TopScore:        event.After.TopScore,
+		Score:           event.After.Score,
+		Active:          event.After.Active,
+		Stationary:      event.After.Stationary,
+		IsCurrent:       true,
 	}
 
 	// 开始事务
 	tx := es.db.Begin()
 
-	// 将相同摄像头和标签的旧事件的is_current设置为false
+	// 将同一Frigate相同摄像头和标签的旧事件的is_current设置为false
 	tx.Model(&models.DetectionEvent{}).
-		Where("camera = ? AND label = ? AND is_current = ?", event.After.Camera, event.After.Label, true).
+		Where("mqtt_topic_prefix = ? AND camera = ? AND label = ? AND is_current = ?", topicPrefix, event.After.Camera, event.After.Label, true).
 		Update("is_current", false)
 
 	// 保存新事件
@@ -82,10 +100,10 @@ func (es *EventService) SaveDetectionEvent(event models.FrigateEvent) error {
 	return tx.Commit().Error
 }
 
-// GetLastEventTime 获取最后事件时间
-func (es *EventService) GetLastEventTime(camera, label string) (float64, error) {
+// GetLastEventTime 获取 scope 范围内的最后事件时间
+func (es *EventService) GetLastEventTime(scope EventScope, camera, label string) (float64, error) {
 	var event models.DetectionEvent
-	query := es.db.Where("is_current = ?", true)
+	query := scope.apply(es.db.Where("is_current = ?", true))
 
 	if camera != "" {
 		query = query.Where("camera = ?", camera)
@@ -105,20 +123,20 @@ func (es *EventService) GetLastEventTime(camera, label string) (float64, error)
 	return event.StartTime, nil
 }
 
-// GetPersonDetectionCount 获取人类检测总数和识别到的人员列表
-func (es *EventService) GetPersonDetectionCount() (int64, []string, error) {
+// GetPersonDetectionCount 获取 scope 范围内的人类检测总数和识别到的人员列表
+func (es *EventService) GetPersonDetectionCount(scope EventScope) (int64, []string, error) {
 	var count int64
 	var subLabels []string
 
 	// 统计person事件总数
-	if err := es.db.Model(&models.DetectionEvent{}).
+	if err := scope.apply(es.db.Model(&models.DetectionEvent{})).
 		Where("label = ?", "person").
 		Count(&count).Error; err != nil {
 		return 0, nil, err
 	}
 
 	// 获取所有不同的sub_label (Re-ID结果)
-	if err := es.db.Model(&models.DetectionEvent{}).
+	if err := scope.apply(es.db.Model(&models.DetectionEvent{})).
 		Where("label = ? AND sub_label != '' AND sub_label IS NOT NULL", "person").
 		Distinct("sub_label").
 		Order("sub_label ASC").
@@ -129,10 +147,10 @@ func (es *EventService) GetPersonDetectionCount() (int64, []string, error) {
 	return count, subLabels, nil
 }
 
-// GetEventsByTimeRange 获取指定时间范围内的事件
-func (es *EventService) GetEventsByTimeRange(startTime, endTime int64) ([]models.DetectionEvent, error) {
+// GetEventsByTimeRange 获取 scope 范围内指定时间范围的事件
+func (es *EventService) GetEventsByTimeRange(scope EventScope, startTime, endTime int64) ([]models.DetectionEvent, error) {
 	var events []models.DetectionEvent
-	err := es.db.Where("start_time >= ? AND start_time <= ?",
+	err := scope.apply(es.db).Where("start_time >= ? AND start_time <= ?",
 		startTime, endTime).
 		Order("start_time DESC").
 		Find(&events).Error
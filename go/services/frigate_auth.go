@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"sotsukenn/go/encryption"
+	"sotsukenn/go/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoFrigateCredentials means a connection has no stored password to log in again with
+var ErrNoFrigateCredentials = errors.New("no stored Frigate credentials")
+
+// reauthLocks serialises re-logins per FrigateConnect so concurrent 401s log in only once
+var reauthLocks sync.Map
+
+// NewFrigateClientForConnect creates a client for a user's Frigate connection that logs
+// in again with the stored credentials when the token expires, and saves the new token
+func NewFrigateClientForConnect(db *gorm.DB, fc *models.FrigateConnect) *FrigateClient {
+	client := NewFrigateClient(fc.FrigateURL)
+	client.Reauth = func(rejectedToken string) (string, error) {
+		return reauthenticate(db, fc, rejectedToken)
+	}
+	return client
+}
+
+// StoreFrigateCredentials reports whether Frigate passwords may be kept for re-login.
+// It requires FRIGATE_STORE_CREDENTIALS=true and configured encryption keys, so the
+// password is never written in plaintext.
+func StoreFrigateCredentials() bool {
+	if os.Getenv("FRIGATE_STORE_CREDENTIALS") != "true" {
+		return false
+	}
+	keyring, err := encryption.Default()
+	return err == nil && keyring.Enabled()
+}
+
+// RememberFrigatePassword keeps a password that Frigate just accepted on the connection,
+// or clears a stored one when credential storage is turned off. The caller saves fc.
+func RememberFrigatePassword(fc *models.FrigateConnect, password string) {
+	if StoreFrigateCredentials() {
+		fc.FrigatePassword = password
+	} else {
+		fc.FrigatePassword = ""
+	}
+}
+
+func reauthenticate(db *gorm.DB, fc *models.FrigateConnect, rejectedToken string) (string, error) {
+	lock, _ := reauthLocks.LoadOrStore(fc.ID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var current models.FrigateConnect
+	if err := db.First(&current, fc.ID).Error; err != nil {
+		return "", err
+	}
+
+	// Another request already logged in again while we were waiting
+	if current.TokenCookie != "" && current.TokenCookie != rejectedToken {
+		fc.TokenCookie = current.TokenCookie
+		return current.TokenCookie, nil
+	}
+
+	if current.FrigatePassword == "" {
+		return "", ErrNoFrigateCredentials
+	}
+
+	username := current.FrigateUsername
+	if username == "" {
+		var user models.User
+		if err := db.First(&user, current.UserID).Error; err != nil {
+			return "", err
+		}
+		username = user.Username
+	}
+
+	token, err := NewFrigateClient(current.FrigateURL).Login(username, current.FrigatePassword)
+	if err != nil {
+		// The password was changed in Frigate; stop retrying with it
+		if errors.Is(err, ErrFrigateCredentialsRejected) {
+			db.Model(&current).Select("FrigatePassword").Updates(&models.FrigateConnect{FrigatePassword: ""})
+			log.Printf("[Frigate] Stored credentials of connection %d were rejected and have been cleared", current.ID)
+		}
+		return "", err
+	}
+
+	now := time.Now()
+	current.TokenCookie = token
+	current.LastVerifiedAt = &now
+	if err := db.Save(&current).Error; err != nil {
+		return "", err
+	}
+
+	fc.TokenCookie = token
+	fc.LastVerifiedAt = &now
+	log.Printf("[Frigate] Re-authenticated connection %d for user %d", current.ID, current.UserID)
+	return token, nil
+}
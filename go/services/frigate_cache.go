@@ -0,0 +1,196 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"sotsukenn/go/types"
+	"sotsukenn/go/utils"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// maxFrigateCacheEntries bounds the cache; expired entries are dropped first when it is full
+const maxFrigateCacheEntries = 1024
+
+// CachedSnapshot is a snapshot image shared between requests
+type CachedSnapshot struct {
+	Data         []byte
+	ContentType  string
+	ETag         string // Strong validator derived from the image content
+	LastModified time.Time
+}
+
+// FrigateCache shares recent Frigate responses between requests, per Frigate instance.
+// Concurrent misses of the same entry are coalesced into a single upstream request.
+// Errors are never cached.
+type FrigateCache struct {
+	mu      sync.Mutex
+	entries map[string]frigateCacheEntry
+	group   singleflight.Group
+
+	streamsTTL  time.Duration
+	camerasTTL  time.Duration
+	snapshotTTL time.Duration
+}
+
+type frigateCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+var (
+	frigateCache     *FrigateCache
+	frigateCacheOnce sync.Once
+)
+
+// FrigateResponses returns the process-wide cache. FRIGATE_CACHE_STREAMS_TTL (default 5s),
+// FRIGATE_CACHE_CAMERAS_TTL (default 60s) and FRIGATE_CACHE_SNAPSHOT_TTL (default 2s)
+// set how long go2rtc stream listings, camera config (/api/cameras and /api/config) and
+// latest snapshots are reused.
+func FrigateResponses() *FrigateCache {
+	frigateCacheOnce.Do(func() {
+		frigateCache = NewFrigateCache(
+			utils.GetEnvDuration("FRIGATE_CACHE_STREAMS_TTL", 5*time.Second),
+			utils.GetEnvDuration("FRIGATE_CACHE_CAMERAS_TTL", time.Minute),
+			utils.GetEnvDuration("FRIGATE_CACHE_SNAPSHOT_TTL", 2*time.Second),
+		)
+	})
+	return frigateCache
+}
+
+// NewFrigateCache creates a cache with the given lifetimes
+func NewFrigateCache(streamsTTL, camerasTTL, snapshotTTL time.Duration) *FrigateCache {
+	return &FrigateCache{
+		entries:     make(map[string]frigateCacheEntry),
+		streamsTTL:  streamsTTL,
+		camerasTTL:  camerasTTL,
+		snapshotTTL: snapshotTTL,
+	}
+}
+
+// Go2RTCStreams returns the go2rtc stream listing of an instance, calling fetch on a miss
+func (c *FrigateCache) Go2RTCStreams(frigateConnectID uint, fetch func() (types.Go2RTCStreamsResponse, error)) (types.Go2RTCStreamsResponse, error) {
+	value, err := c.get(fmt.Sprintf("%d/streams", frigateConnectID), c.streamsTTL, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(types.Go2RTCStreamsResponse), nil
+}
+
+// Cameras returns the camera config of an instance, calling fetch on a miss
+func (c *FrigateCache) Cameras(frigateConnectID uint, fetch func() (types.CamerasResponse, error)) (types.CamerasResponse, error) {
+	value, err := c.get(fmt.Sprintf("%d/cameras", frigateConnectID), c.camerasTTL, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(types.CamerasResponse), nil
+}
+
+// Config returns the Frigate config of an instance, calling fetch on a miss
+func (c *FrigateCache) Config(frigateConnectID uint, fetch func() (*types.FrigateConfig, error)) (*types.FrigateConfig, error) {
+	value, err := c.get(fmt.Sprintf("%d/config", frigateConnectID), c.camerasTTL, func() (interface{}, error) {
+		return fetch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*types.FrigateConfig), nil
+}
+
+// Snapshot returns the latest snapshot of a camera with the given options, calling fetch on a miss
+func (c *FrigateCache) Snapshot(frigateConnectID uint, camera string, params url.Values, fetch func() ([]byte, string, error)) (*CachedSnapshot, error) {
+	key := fmt.Sprintf("%d/snapshot/%s?%s", frigateConnectID, url.PathEscape(camera), params.Encode())
+	value, err := c.get(key, c.snapshotTTL, func() (interface{}, error) {
+		data, contentType, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		snapshot := &CachedSnapshot{
+			Data:         data,
+			ContentType:  contentType,
+			ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+			LastModified: time.Now().UTC().Truncate(time.Second),
+		}
+		// An unchanged image keeps its modification time, e.g. of a camera that is offline
+		if previous, ok := c.peek(key).(*CachedSnapshot); ok && previous.ETag == snapshot.ETag {
+			snapshot.LastModified = previous.LastModified
+		}
+		return snapshot, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*CachedSnapshot), nil
+}
+
+// Invalidate forgets everything cached for an instance, e.g. after its URL changed
+func (c *FrigateCache) Invalidate(frigateConnectID uint) {
+	prefix := fmt.Sprintf("%d/", frigateConnectID)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *FrigateCache) get(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	entry, exists := c.entries[key]
+	c.mu.Unlock()
+	if exists && time.Now().Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.set(key, value, ttl)
+		return value, nil
+	})
+	return value, err
+}
+
+// peek returns the value stored under key even if it expired, or nil
+func (c *FrigateCache) peek(key string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key].value
+}
+
+func (c *FrigateCache) set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxFrigateCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	// Still full of live entries: make room at random
+	for k := range c.entries {
+		if len(c.entries) < maxFrigateCacheEntries {
+			break
+		}
+		delete(c.entries, k)
+	}
+
+	c.entries[key] = frigateCacheEntry{value: value, expiresAt: now.Add(ttl)}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sotsukenn/go/types"
+)
+
+func TestFrigateCacheCoalescesConcurrentMisses(t *testing.T) {
+	cache := NewFrigateCache(time.Minute, time.Minute, time.Minute)
+	var calls int32
+	release := make(chan struct{})
+	fetch := func() (types.Go2RTCStreamsResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return types.Go2RTCStreamsResponse{"front": {}}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streams, err := cache.Go2RTCStreams(1, fetch)
+			if err != nil || len(streams) != 1 {
+				t.Errorf("Go2RTCStreams() = %v, %v", streams, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if _, err := cache.Go2RTCStreams(1, fetch); err != nil {
+		t.Fatalf("Go2RTCStreams() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fetch called %d times, want 1", got)
+	}
+}
+
+func TestFrigateCacheExpiryErrorsAndInvalidate(t *testing.T) {
+	cache := NewFrigateCache(20*time.Millisecond, time.Minute, time.Minute)
+	calls := 0
+	fetch := func() (types.Go2RTCStreamsResponse, error) {
+		calls++
+		return types.Go2RTCStreamsResponse{}, nil
+	}
+
+	tests := []struct {
+		name      string
+		action    func()
+		fetch     func() (types.Go2RTCStreamsResponse, error)
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "miss", fetch: fetch, wantCalls: 1},
+		{name: "hit", fetch: fetch, wantCalls: 1},
+		{name: "expired", action: func() { time.Sleep(30 * time.Millisecond) }, fetch: fetch, wantCalls: 2},
+		{name: "invalidated", action: func() { cache.Invalidate(1) }, fetch: fetch, wantCalls: 3},
+		{name: "other instance is separate", action: func() { cache.Go2RTCStreams(2, fetch) }, fetch: fetch, wantCalls: 4},
+		{
+			name:   "errors are not cached",
+			action: func() { cache.Invalidate(1) },
+			fetch: func() (types.Go2RTCStreamsResponse, error) {
+				return nil, errors.New("frigate down")
+			},
+			wantErr:   true,
+			wantCalls: 4,
+		},
+		{name: "retry after error", fetch: fetch, wantCalls: 5},
+	}
+
+	for _, tt := range tests {
+		if tt.action != nil {
+			tt.action()
+		}
+		_, err := cache.Go2RTCStreams(1, tt.fetch)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("%s: fetch called %d times, want %d", tt.name, calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestFrigateCacheSnapshotValidators(t *testing.T) {
+	cache := NewFrigateCache(time.Minute, time.Minute, 10*time.Millisecond)
+	image := []byte("jpeg-1")
+	fetch := func() ([]byte, string, error) { return image, "image/jpeg", nil }
+	params := url.Values{"h": {"360"}}
+
+	first, err := cache.Snapshot(1, "front", params, fetch)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if first.ETag == "" || first.ContentType != "image/jpeg" {
+		t.Fatalf("Snapshot() = %+v", first)
+	}
+
+	// Same image after expiry: same validators
+	time.Sleep(1100 * time.Millisecond)
+	same, _ := cache.Snapshot(1, "front", params, fetch)
+	if same.ETag != first.ETag || !same.LastModified.Equal(first.LastModified) {
+		t.Errorf("unchanged image got new validators: %+v vs %+v", same, first)
+	}
+
+	// New image: new ETag
+	time.Sleep(20 * time.Millisecond)
+	image = []byte("jpeg-2")
+	changed, _ := cache.Snapshot(1, "front", params, fetch)
+	if changed.ETag == first.ETag || !changed.LastModified.After(first.LastModified) {
+		t.Errorf("changed image kept validators: %+v vs %+v", changed, first)
+	}
+
+	// Other options are cached separately
+	other, _ := cache.Snapshot(1, "front", url.Values{"h": {"720"}}, func() ([]byte, string, error) {
+		return []byte("big"), "image/jpeg", nil
+	})
+	if other.ETag == changed.ETag {
+		t.Error("snapshots with different options share an entry")
+	}
+}
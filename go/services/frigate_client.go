@@ -1,20 +1,36 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"sotsukenn/go/types"
+
+	"github.com/gorilla/websocket"
 )
 
+// ErrFrigateCredentialsRejected is returned by Login when Frigate refuses the username or password
+var ErrFrigateCredentialsRejected = errors.New("frigate rejected the credentials")
+
+// ReauthFunc obtains a new token after Frigate rejected the given one
+type ReauthFunc func(rejectedToken string) (string, error)
+
 // FrigateClient handles communication with Frigate API
 type FrigateClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+
+	// Reauth is optional; when set, token-authenticated requests answered with 401
+	// log in again through it and are retried once with the new token
+	Reauth ReauthFunc
 }
 
 // NewFrigateClient creates a new Frigate API client
@@ -27,6 +43,35 @@ func NewFrigateClient(baseURL string) *FrigateClient {
 	}
 }
 
+// doWithToken sends req with a Bearer token. If Frigate answers 401 and Reauth is set,
+// the request is retried once with a fresh token; otherwise the 401 response is returned.
+func (fc *FrigateClient) doWithToken(req *http.Request, token string) (*http.Response, error) {
+	return fc.doWithTokenUsing(fc.HTTPClient, req, token)
+}
+
+// doWithTokenUsing is doWithToken with a specific HTTP client, e.g. one without a timeout for streams
+func (fc *FrigateClient) doWithTokenUsing(client *http.Client, req *http.Request, token string) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || fc.Reauth == nil {
+		return resp, err
+	}
+
+	newToken, reauthErr := fc.Reauth(token)
+	if reauthErr != nil {
+		if !errors.Is(reauthErr, ErrNoFrigateCredentials) {
+			log.Printf("[Frigate] Re-authentication failed: %v", reauthErr)
+		}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	retry.Header.Set("Authorization", "Bearer "+newToken)
+	return client.Do(retry)
+}
+
 // Login authenticates with Frigate API and returns token from set-cookie
 func (fc *FrigateClient) Login(username, password string) (string, error) {
 	loginReq := types.FrigateLoginRequest{
@@ -55,6 +100,10 @@ func (fc *FrigateClient) Login(username, password string) (string, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		// Only a refusal of the credentials unlinks them; other errors may be transient
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return "", fmt.Errorf("%w: %s (status: %d)", ErrFrigateCredentialsRejected, string(body), resp.StatusCode)
+		}
 		return "", fmt.Errorf("frigate login failed: %s (status: %d)", string(body), resp.StatusCode)
 	}
 
@@ -68,7 +117,8 @@ func (fc *FrigateClient) Login(username, password string) (string, error) {
 	return "", fmt.Errorf("no frigate_token cookie found in response")
 }
 
-// VerifyToken checks if the provided token is valid using Bearer token
+// VerifyToken checks if the provided token is valid using Bearer token.
+// With Reauth set, an expired token also counts as valid once re-login succeeded.
 func (fc *FrigateClient) VerifyToken(token string) (bool, error) {
 	url := fmt.Sprintf("%s/api/auth", fc.BaseURL)
 	req, err := http.NewRequest("GET", url, nil)
@@ -76,10 +126,7 @@ func (fc *FrigateClient) VerifyToken(token string) (bool, error) {
 		return false, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Use Authorization: Bearer <token> header
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	resp, err := fc.HTTPClient.Do(req)
+	resp, err := fc.doWithToken(req, token)
 	if err != nil {
 		return false, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -97,9 +144,7 @@ func (fc *FrigateClient) GetCameras(token string) (types.CamerasResponse, error)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	resp, err := fc.HTTPClient.Do(req)
+	resp, err := fc.doWithToken(req, token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -118,6 +163,60 @@ func (fc *FrigateClient) GetCameras(token string) (types.CamerasResponse, error)
 	return cameras, nil
 }
 
+// GetConfig retrieves the parts of Frigate's configuration described by types.FrigateConfig
+func (fc *FrigateClient) GetConfig(token string) (*types.FrigateConfig, error) {
+	url := fmt.Sprintf("%s/api/config", fc.BaseURL)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := fc.doWithToken(req, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get config: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	var config types.FrigateConfig
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &config, nil
+}
+
+// GetPTZInfo retrieves the PTZ features and presets of a camera
+func (fc *FrigateClient) GetPTZInfo(cameraName, token string) (*types.PTZInfo, error) {
+	infoURL := fmt.Sprintf("%s/api/%s/ptz/info", fc.BaseURL, url.PathEscape(cameraName))
+	req, err := http.NewRequest("GET", infoURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := fc.doWithToken(req, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get PTZ info: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	var info types.PTZInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &info, nil
+}
+
 // GetStreamURL returns the URL for a camera stream
 // streamType can be: "mjpg" (MJPEG), "mp4" (H.264), "rtsp" (RTSP)
 func (fc *FrigateClient) GetStreamURL(cameraName, streamType string) string {
@@ -137,9 +236,7 @@ func (fc *FrigateClient) GetSnapshot(cameraName, token string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := fc.HTTPClient.Do(req)
+	resp, err := fc.doWithToken(req, token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -160,9 +257,7 @@ func (fc *FrigateClient) GetStreams(token string) (types.GetStreamsResponse, err
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	resp, err := fc.HTTPClient.Do(req)
+	resp, err := fc.doWithToken(req, token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -226,10 +321,7 @@ func (fc *FrigateClient) GetGo2RTCStreamsWithToken(token string) (types.Go2RTCSt
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Set Bearer token
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	resp, err := fc.HTTPClient.Do(req)
+	resp, err := fc.doWithToken(req, token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -248,23 +340,25 @@ func (fc *FrigateClient) GetGo2RTCStreamsWithToken(token string) (types.Go2RTCSt
 	return streams, nil
 }
 
-// GetLatestSnapshotURL returns the URL for the latest snapshot from a camera
-func (fc *FrigateClient) GetLatestSnapshotURL(cameraName string) string {
-	return fmt.Sprintf("%s/api/%s/latest.jpg", fc.BaseURL, cameraName)
+// GetLatestSnapshotURL returns the URL for the latest snapshot from a camera.
+// params are passed to Frigate's latest.jpg, e.g. h, quality and bbox.
+func (fc *FrigateClient) GetLatestSnapshotURL(cameraName string, params url.Values) string {
+	snapshotURL := fmt.Sprintf("%s/api/%s/latest.jpg", fc.BaseURL, url.PathEscape(cameraName))
+	if len(params) > 0 {
+		snapshotURL += "?" + params.Encode()
+	}
+	return snapshotURL
 }
 
 // GetLatestSnapshot retrieves the latest snapshot image from the specified camera
 // Returns image data, Content-Type, and error
-func (fc *FrigateClient) GetLatestSnapshot(cameraName, token string) ([]byte, string, error) {
-	url := fc.GetLatestSnapshotURL(cameraName)
-	req, err := http.NewRequest("GET", url, nil)
+func (fc *FrigateClient) GetLatestSnapshot(cameraName, token string, params url.Values) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", fc.GetLatestSnapshotURL(cameraName, params), nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := fc.HTTPClient.Do(req)
+	resp, err := fc.doWithToken(req, token)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to send request: %w", err)
 	}
@@ -290,5 +384,128 @@ func (fc *FrigateClient) GetLatestSnapshot(cameraName, token string) ([]byte, st
 // GetMJPEGStreamURL returns the MJPEG stream URL for a camera
 // Note: Authentication should be done via Authorization header with Bearer token
 func (fc *FrigateClient) GetMJPEGStreamURL(cameraName string) string {
-	return fmt.Sprintf("%s/api/%s", fc.BaseURL, cameraName)
+	return fmt.Sprintf("%s/api/%s", fc.BaseURL, url.PathEscape(cameraName))
+}
+
+// OpenMJPEGStream connects to the MJPEG stream of a camera. The response body is a
+// multipart/x-mixed-replace stream that stays open until ctx is cancelled; the caller closes it.
+func (fc *FrigateClient) OpenMJPEGStream(ctx context.Context, cameraName, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fc.GetMJPEGStreamURL(cameraName), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// The client timeout would cut the stream off, so only the context bounds it
+	streamClient := *fc.HTTPClient
+	streamClient.Timeout = 0
+
+	resp, err := fc.doWithTokenUsing(&streamClient, req, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to open stream: status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// GetGo2RTCAPIURL returns the URL of a go2rtc API path as proxied by Frigate, e.g.
+// GetGo2RTCAPIURL("stream.mp4", url.Values{"src": {"front"}})
+func (fc *FrigateClient) GetGo2RTCAPIURL(apiPath string, params url.Values) string {
+	apiURL := fmt.Sprintf("%s/api/go2rtc/%s", fc.BaseURL, apiPath)
+	if len(params) > 0 {
+		apiURL += "?" + params.Encode()
+	}
+	return apiURL
+}
+
+// GetGo2RTCHLSBase returns the directory go2rtc serves HLS playlists and segments from
+func (fc *FrigateClient) GetGo2RTCHLSBase() string {
+	return fc.GetGo2RTCAPIURL("hls/", nil)
+}
+
+// GetGo2RTCFile fetches a short go2rtc resource such as an HLS playlist or segment.
+// Returns data, Content-Type, and error
+func (fc *FrigateClient) GetGo2RTCFile(ctx context.Context, apiPath string, params url.Values, token string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fc.GetGo2RTCAPIURL(apiPath, params), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := fc.doWithToken(req, token)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to get %s: status %d", apiPath, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// OpenGo2RTCStream connects to a continuous go2rtc stream such as stream.mp4. The response
+// body stays open until ctx is cancelled; the caller closes it.
+func (fc *FrigateClient) OpenGo2RTCStream(ctx context.Context, apiPath string, params url.Values, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fc.GetGo2RTCAPIURL(apiPath, params), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// The client timeout would cut the stream off, so only the context bounds it
+	streamClient := *fc.HTTPClient
+	streamClient.Timeout = 0
+
+	resp, err := fc.doWithTokenUsing(&streamClient, req, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to open stream: status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// GetGo2RTCWebSocketURL returns the go2rtc WebSocket API of a stream as exposed by Frigate's live view
+func (fc *FrigateClient) GetGo2RTCWebSocketURL(streamName string) string {
+	wsURL := fmt.Sprintf("%s/live/webrtc/api/ws?%s", fc.BaseURL, url.Values{"src": {streamName}}.Encode())
+	if strings.HasPrefix(wsURL, "https://") {
+		return "wss://" + strings.TrimPrefix(wsURL, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(wsURL, "http://")
+}
+
+// DialGo2RTCWebSocket opens go2rtc's WebSocket API for a stream, used for WebRTC signaling.
+// Like doWithToken, a rejected token is renewed through Reauth once.
+func (fc *FrigateClient) DialGo2RTCWebSocket(ctx context.Context, streamName, token string) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: fc.HTTPClient.Timeout}
+
+	dial := func(token string) (*websocket.Conn, *http.Response, error) {
+		header := http.Header{"Authorization": {"Bearer " + token}}
+		return dialer.DialContext(ctx, fc.GetGo2RTCWebSocketURL(streamName), header)
+	}
+
+	conn, resp, err := dial(token)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusUnauthorized && fc.Reauth != nil {
+		newToken, reauthErr := fc.Reauth(token)
+		if reauthErr == nil {
+			conn, resp, err = dial(newToken)
+		} else if !errors.Is(reauthErr, ErrNoFrigateCredentials) {
+			log.Printf("[Frigate] Re-authentication failed: %v", reauthErr)
+		}
+	}
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("failed to open go2rtc websocket: status %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to open go2rtc websocket: %w", err)
+	}
+	return conn, nil
 }
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFrigateLoginRejectsOnlyRefusedCredentials(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		rejected bool
+	}{
+		{http.StatusUnauthorized, true},
+		{http.StatusForbidden, true},
+		{http.StatusBadRequest, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusBadGateway, false},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+		_, err := NewFrigateClient(server.URL).Login("admin", "secret")
+		server.Close()
+
+		if err == nil {
+			t.Fatalf("status %d: Login succeeded", tc.status)
+		}
+		if got := errors.Is(err, ErrFrigateCredentialsRejected); got != tc.rejected {
+			t.Errorf("status %d: credentials rejected = %v, want %v (%v)", tc.status, got, tc.rejected, err)
+		}
+	}
+}
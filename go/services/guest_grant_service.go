@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/utils"
+
+	"gorm.io/gorm"
+)
+
+// ErrGuestGrantInvalid is returned for unknown, revoked or expired guest grants
+var ErrGuestGrantInvalid = errors.New("guest grant is invalid, revoked or expired")
+
+// GuestGrantService issues and redeems time-limited guest access to cameras
+type GuestGrantService struct {
+	db         *gorm.DB
+	sessionTTL time.Duration
+}
+
+// NewGuestGrantService creates a guest grant service; guest sessions last at most
+// GUEST_SESSION_TTL (default 12 hours) and never outlive their grant
+func NewGuestGrantService(db *gorm.DB) *GuestGrantService {
+	return &GuestGrantService{
+		db:         db,
+		sessionTTL: utils.GetEnvDuration("GUEST_SESSION_TTL", 12*time.Hour),
+	}
+}
+
+// GuestFamilyID is the token family of the sessions redeemed from a grant,
+// so revoking the grant can drop them from the token store
+func GuestFamilyID(grantID uint) string {
+	return fmt.Sprintf("guest-%d", grantID)
+}
+
+// Create saves a grant with a new redeem code. The raw code is only returned here.
+func (gs *GuestGrantService) Create(grant *models.GuestGrant) (string, error) {
+	raw, err := utils.GenerateSecureToken(24)
+	if err != nil {
+		return "", err
+	}
+
+	grant.CodeHash = models.HashToken(raw)
+	if err := gs.db.Create(grant).Error; err != nil {
+		return "", fmt.Errorf("failed to save guest grant: %w", err)
+	}
+	return raw, nil
+}
+
+// Redeem looks up the grant of a redeem code. Codes can be redeemed repeatedly until the grant ends.
+func (gs *GuestGrantService) Redeem(raw string) (*models.GuestGrant, error) {
+	var grant models.GuestGrant
+	if err := gs.db.Where("code_hash = ?", models.HashToken(raw)).First(&grant).Error; err != nil {
+		return nil, ErrGuestGrantInvalid
+	}
+	if !grant.IsUsable() {
+		return nil, ErrGuestGrantInvalid
+	}
+
+	now := time.Now()
+	gs.db.Model(&grant).UpdateColumn("last_used_at", &now)
+	return &grant, nil
+}
+
+// Active loads a grant that is neither revoked nor expired
+func (gs *GuestGrantService) Active(grantID uint) (*models.GuestGrant, error) {
+	var grant models.GuestGrant
+	if err := gs.db.First(&grant, grantID).Error; err != nil {
+		return nil, ErrGuestGrantInvalid
+	}
+	if !grant.IsUsable() {
+		return nil, ErrGuestGrantInvalid
+	}
+	return &grant, nil
+}
+
+// SessionExpiry returns when a session redeemed now from grant must end
+func (gs *GuestGrantService) SessionExpiry(grant *models.GuestGrant) time.Time {
+	expiresAt := time.Now().Add(gs.sessionTTL)
+	if grant.ExpiresAt.Before(expiresAt) {
+		return grant.ExpiresAt
+	}
+	return expiresAt
+}
+
+// Revoke ends a grant immediately; sessions redeemed from it are refused from then on
+func (gs *GuestGrantService) Revoke(grant *models.GuestGrant) error {
+	now := time.Now()
+	grant.RevokedAt = &now
+	return gs.db.Model(grant).Update("revoked_at", &now).Error
+}
+
+// CleanExpired deletes grants that can no longer be redeemed
+func (gs *GuestGrantService) CleanExpired() error {
+	return gs.db.Where("expires_at <= ? OR revoked_at IS NOT NULL", time.Now()).Delete(&models.GuestGrant{}).Error
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hlsFileName matches the files go2rtc serves below /api/hls/, e.g. playlist.m3u8 or segment.ts
+var hlsFileName = regexp.MustCompile(`^[a-z0-9_]+\.(m3u8|ts|m4s|mp4)$`)
+
+// hlsURIAttribute matches URI="..." attributes of tags such as #EXT-X-MAP
+var hlsURIAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// IsHLSFileName reports whether name is a go2rtc HLS file that may be proxied
+func IsHLSFileName(name string) bool {
+	return hlsFileName.MatchString(name)
+}
+
+// HLSRewriter points the URIs of go2rtc HLS playlists at the server's authenticated proxy
+type HLSRewriter struct {
+	// HLSBase is go2rtc's HLS directory behind Frigate, e.g. https://frigate/api/go2rtc/hls/
+	HLSBase *url.URL
+	// ProxyPrefix replaces HLSBase, e.g. /api/camera/front/hls/
+	ProxyPrefix string
+	// Query is added to every rewritten URI, e.g. the instance
+	Query url.Values
+}
+
+// Rewrite rewrites the playlist fetched from playlistURL. URIs outside of HLSBase are
+// refused so a playlist cannot make the proxy fetch arbitrary Frigate paths.
+func (r *HLSRewriter) Rewrite(playlist []byte, playlistURL *url.URL) ([]byte, error) {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			var rewriteErr error
+			line = hlsURIAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				uri := hlsURIAttribute.FindStringSubmatch(attr)[1]
+				proxied, err := r.rewriteURI(uri, playlistURL)
+				if err != nil {
+					rewriteErr = err
+					return attr
+				}
+				return `URI="` + proxied + `"`
+			})
+			if rewriteErr != nil {
+				return nil, rewriteErr
+			}
+		default:
+			proxied, err := r.rewriteURI(line, playlistURL)
+			if err != nil {
+				return nil, err
+			}
+			line = proxied
+		}
+
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func (r *HLSRewriter) rewriteURI(uri string, playlistURL *url.URL) (string, error) {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid playlist URI %q: %w", uri, err)
+	}
+	resolved := playlistURL.ResolveReference(ref)
+
+	name := path.Base(resolved.Path)
+	if resolved.Scheme != r.HLSBase.Scheme || resolved.Host != r.HLSBase.Host ||
+		path.Dir(resolved.Path)+"/" != r.HLSBase.Path || !IsHLSFileName(name) {
+		return "", fmt.Errorf("playlist URI %q is outside of the HLS directory", uri)
+	}
+
+	query := resolved.Query()
+	for key, values := range r.Query {
+		query[key] = values
+	}
+	return r.ProxyPrefix + name + "?" + query.Encode(), nil
+}
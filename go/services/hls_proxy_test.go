@@ -0,0 +1,96 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestHLSRewriterRewrite(t *testing.T) {
+	hlsBase, _ := url.Parse("https://frigate.example.com/api/go2rtc/hls/")
+	rewriter := &HLSRewriter{
+		HLSBase:     hlsBase,
+		ProxyPrefix: "/api/camera/front/hls/",
+		Query:       url.Values{"instance": {"home"}},
+	}
+
+	tests := []struct {
+		name        string
+		playlistURL string
+		playlist    string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "master playlist",
+			playlistURL: "https://frigate.example.com/api/go2rtc/stream.m3u8?src=front",
+			playlist:    "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=192000\nhls/playlist.m3u8?id=abc\n",
+			want:        "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=192000\n/api/camera/front/hls/playlist.m3u8?id=abc&instance=home\n",
+		},
+		{
+			name:        "media playlist with init segment",
+			playlistURL: "https://frigate.example.com/api/go2rtc/hls/playlist.m3u8?id=abc",
+			playlist:    "#EXTM3U\r\n#EXT-X-MAP:URI=\"init.mp4?id=abc\"\r\n#EXTINF:0.500,\r\nsegment.m4s?id=abc&n=7\r\n",
+			want:        "#EXTM3U\n#EXT-X-MAP:URI=\"/api/camera/front/hls/init.mp4?id=abc&instance=home\"\n#EXTINF:0.500,\n/api/camera/front/hls/segment.m4s?id=abc&instance=home&n=7\n",
+		},
+		{
+			name:        "instance from upstream is overridden",
+			playlistURL: "https://frigate.example.com/api/go2rtc/hls/playlist.m3u8?id=abc",
+			playlist:    "segment.ts?id=abc&instance=other\n",
+			want:        "/api/camera/front/hls/segment.ts?id=abc&instance=home\n",
+		},
+		{
+			name:        "absolute URI on another host",
+			playlistURL: "https://frigate.example.com/api/go2rtc/hls/playlist.m3u8?id=abc",
+			playlist:    "https://evil.example.com/api/go2rtc/hls/segment.ts\n",
+			wantErr:     true,
+		},
+		{
+			name:        "URI outside of the HLS directory",
+			playlistURL: "https://frigate.example.com/api/go2rtc/hls/playlist.m3u8?id=abc",
+			playlist:    "../../config/raw\n",
+			wantErr:     true,
+		},
+		{
+			name:        "attribute URI outside of the HLS directory",
+			playlistURL: "https://frigate.example.com/api/go2rtc/hls/playlist.m3u8?id=abc",
+			playlist:    "#EXT-X-MAP:URI=\"/api/login.mp4\"\n",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlistURL, _ := url.Parse(tt.playlistURL)
+			got, err := rewriter.Rewrite([]byte(tt.playlist), playlistURL)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Rewrite() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rewrite() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Rewrite() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHLSFileName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"playlist.m3u8":  true,
+		"segment.ts":     true,
+		"init.mp4":       true,
+		"segment.m4s":    true,
+		"../streams":     false,
+		"playlist.m3u8/": false,
+		"config.json":    false,
+		"":               false,
+	} {
+		if got := IsHLSFileName(name); got != want {
+			t.Errorf("IsHLSFileName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInviteInvalid is returned for unknown, used or expired invite codes
+	ErrInviteInvalid = errors.New("household invite is invalid, used or expired")
+	// ErrAlreadyMember is returned when the user already belongs to the household
+	ErrAlreadyMember = errors.New("user is already a member of the household")
+	// ErrLastOwner is returned when a change would leave a household without an owner
+	ErrLastOwner = errors.New("household must keep at least one owner")
+)
+
+// HouseholdService manages households, their members and invites
+type HouseholdService struct {
+	db        *gorm.DB
+	inviteTTL time.Duration
+}
+
+// NewHouseholdService creates a household service; invites expire after HOUSEHOLD_INVITE_TTL (default 7 days)
+func NewHouseholdService(db *gorm.DB) *HouseholdService {
+	return &HouseholdService{
+		db:        db,
+		inviteTTL: utils.GetEnvDuration("HOUSEHOLD_INVITE_TTL", 7*24*time.Hour),
+	}
+}
+
+// Create creates a household with ownerID as its first owner
+func (hs *HouseholdService) Create(name string, ownerID uint) (*models.Household, error) {
+	household := models.Household{Name: name, CreatedBy: ownerID}
+	err := hs.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&household).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.HouseholdMember{
+			HouseholdID: household.ID,
+			UserID:      ownerID,
+			Role:        models.HouseholdRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create household: %w", err)
+	}
+	return &household, nil
+}
+
+// DefaultHousehold returns the first household in which the user can manage Frigate
+// instances, creating a household named after the user if there is none
+func (hs *HouseholdService) DefaultHousehold(user *models.User) (*models.Household, error) {
+	var household models.Household
+	err := hs.db.
+		Joins("JOIN household_members ON household_members.household_id = households.id").
+		Where("household_members.user_id = ? AND household_members.role IN ?", user.ID,
+			[]string{models.HouseholdRoleOwner, models.HouseholdRoleMember}).
+		Order("households.id ASC").
+		First(&household).Error
+	if err == nil {
+		return &household, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return hs.Create(user.Username, user.ID)
+}
+
+// Membership returns the user's membership of a household
+func (hs *HouseholdService) Membership(householdID, userID uint) (*models.HouseholdMember, error) {
+	var member models.HouseholdMember
+	if err := hs.db.Where("household_id = ? AND user_id = ?", householdID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// MemberHouseholdIDs returns a subquery selecting the IDs of the households the user belongs to
+func (hs *HouseholdService) MemberHouseholdIDs(userID uint) *gorm.DB {
+	return hs.db.Model(&models.HouseholdMember{}).Select("household_id").Where("user_id = ?", userID)
+}
+
+// UpdateMemberRole changes a member's household role
+func (hs *HouseholdService) UpdateMemberRole(member *models.HouseholdMember, role string) error {
+	if member.Role == models.HouseholdRoleOwner && role != models.HouseholdRoleOwner && hs.isLastOwner(member) {
+		return ErrLastOwner
+	}
+	member.Role = role
+	return hs.db.Model(member).Update("role", role).Error
+}
+
+// RemoveMember removes a member from a household along with their group memberships and camera permissions
+func (hs *HouseholdService) RemoveMember(member *models.HouseholdMember) error {
+	if member.Role == models.HouseholdRoleOwner && hs.isLastOwner(member) {
+		return ErrLastOwner
+	}
+	return hs.db.Transaction(func(tx *gorm.DB) error {
+		if err := removeMemberRules(tx, member.HouseholdID, member.UserID); err != nil {
+			return err
+		}
+		return tx.Delete(member).Error
+	})
+}
+
+// Delete removes a household together with its members, groups, camera permissions, invites, guest grants,
+// camera schedules and Frigate instances
+func (hs *HouseholdService) Delete(householdID uint) error {
+	return hs.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("household_id = ?", householdID).Delete(&models.FrigateConnect{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("household_id = ?", householdID).Delete(&models.CameraPermission{}).Error; err != nil {
+			return err
+		}
+		groupIDs := tx.Model(&models.HouseholdGroup{}).Select("id").Where("household_id = ?", householdID)
+		if err := tx.Where("group_id IN (?)", groupIDs).Delete(&models.HouseholdGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("household_id = ?", householdID).Delete(&models.HouseholdGroup{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("household_id = ?", householdID).Delete(&models.GuestGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("household_id = ?", householdID).Delete(&models.CameraSchedule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("household_id = ?", householdID).Delete(&models.HouseholdInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("household_id = ?", householdID).Delete(&models.HouseholdMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Household{}, householdID).Error
+	})
+}
+
+// RemoveUser drops all memberships, group memberships and camera permissions of a user, e.g. when the account is deleted.
+// It fails with ErrLastOwner if the user is the only owner of a household.
+func (hs *HouseholdService) RemoveUser(userID uint) error {
+	return hs.db.Transaction(func(tx *gorm.DB) error {
+		var orphaned []uint
+		otherOwners := tx.Model(&models.HouseholdMember{}).Select("household_id").
+			Where("role = ? AND user_id <> ?", models.HouseholdRoleOwner, userID)
+		err := tx.Model(&models.HouseholdMember{}).
+			Where("user_id = ? AND role = ? AND household_id NOT IN (?)", userID, models.HouseholdRoleOwner, otherOwners).
+			Pluck("household_id", &orphaned).Error
+		if err != nil {
+			return err
+		}
+		if len(orphaned) > 0 {
+			return fmt.Errorf("%w: households %v", ErrLastOwner, orphaned)
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.CameraPermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.HouseholdGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.HouseholdMember{}).Error
+	})
+}
+
+// CreateInvite issues a single-use invite code granting role. The raw code is only returned here.
+func (hs *HouseholdService) CreateInvite(householdID uint, role string, createdBy uint) (string, *models.HouseholdInvite, error) {
+	raw, err := utils.GenerateSecureToken(24)
+	if err != nil {
+		return "", nil, err
+	}
+
+	invite := models.HouseholdInvite{
+		HouseholdID: householdID,
+		CodeHash:    models.HashToken(raw),
+		Role:        role,
+		CreatedBy:   createdBy,
+		ExpiresAt:   time.Now().Add(hs.inviteTTL),
+	}
+	if err := hs.db.Create(&invite).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to save invite: %w", err)
+	}
+	return raw, &invite, nil
+}
+
+// AcceptInvite consumes an invite code and adds the user to its household
+func (hs *HouseholdService) AcceptInvite(raw string, userID uint) (*models.HouseholdMember, error) {
+	var invite models.HouseholdInvite
+	if err := hs.db.Where("code_hash = ?", models.HashToken(raw)).First(&invite).Error; err != nil {
+		return nil, ErrInviteInvalid
+	}
+	if invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return nil, ErrInviteInvalid
+	}
+
+	if _, err := hs.Membership(invite.HouseholdID, userID); err == nil {
+		return nil, ErrAlreadyMember
+	}
+
+	member := models.HouseholdMember{
+		HouseholdID: invite.HouseholdID,
+		UserID:      userID,
+		Role:        invite.Role,
+	}
+	err := hs.db.Transaction(func(tx *gorm.DB) error {
+		// Mark the invite used first; only one concurrent request can win this update
+		result := tx.Model(&models.HouseholdInvite{}).
+			Where("id = ? AND accepted_at IS NULL", invite.ID).
+			Updates(map[string]interface{}{"accepted_by": userID, "accepted_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInviteInvalid
+		}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// CleanExpiredInvites deletes invites that can no longer be accepted
+func (hs *HouseholdService) CleanExpiredInvites() error {
+	return hs.db.Where("expires_at <= ? OR accepted_at IS NOT NULL", time.Now()).Delete(&models.HouseholdInvite{}).Error
+}
+
+func (hs *HouseholdService) isLastOwner(member *models.HouseholdMember) bool {
+	var owners int64
+	hs.db.Model(&models.HouseholdMember{}).
+		Where("household_id = ? AND role = ? AND id <> ?", member.HouseholdID, models.HouseholdRoleOwner, member.ID).
+		Count(&owners)
+	return owners == 0
+}
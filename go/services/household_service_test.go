@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"sotsukenn/go/models"
+)
+
+func TestRemoveUserKeepsAnOwner(t *testing.T) {
+	db := newTestDB(t, &models.HouseholdMember{}, &models.HouseholdGroupMember{}, &models.CameraPermission{})
+	hs := &HouseholdService{db: db}
+
+	members := []models.HouseholdMember{
+		{HouseholdID: 1, UserID: 1, Role: models.HouseholdRoleOwner},
+		{HouseholdID: 1, UserID: 2, Role: models.HouseholdRoleOwner},
+		{HouseholdID: 2, UserID: 1, Role: models.HouseholdRoleOwner},
+		{HouseholdID: 2, UserID: 3, Role: models.HouseholdRoleMember},
+	}
+	if err := db.Create(&members).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// User 1 is the only owner of household 2
+	if err := hs.RemoveUser(1); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("removing the last owner: got %v", err)
+	}
+	var count int64
+	db.Model(&models.HouseholdMember{}).Where("user_id = ?", 1).Count(&count)
+	if count != 2 {
+		t.Fatalf("memberships after refused removal = %d, want 2", count)
+	}
+
+	// Household 1 keeps user 1 as owner
+	if err := hs.RemoveUser(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := hs.RemoveUser(3); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&models.HouseholdMember{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("memberships left = %d, want 2", count)
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleService limits password guessing per client IP and per username.
+// Once a key reaches its attempt limit it is locked out, and every further
+// lockout doubles the duration up to a maximum.
+type LoginThrottleService struct {
+	db              *gorm.DB
+	maxUserAttempts int
+	maxIPAttempts   int
+	window          time.Duration
+	baseLockout     time.Duration
+	maxLockout      time.Duration
+}
+
+// NewLoginThrottleService creates a login throttle service configured from the environment
+func NewLoginThrottleService(db *gorm.DB) *LoginThrottleService {
+	return &LoginThrottleService{
+		db:              db,
+		maxUserAttempts: getEnvInt("LOGIN_MAX_ATTEMPTS_PER_USER", 5),
+		maxIPAttempts:   getEnvInt("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+		window:          utils.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+		baseLockout:     utils.GetEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+		maxLockout:      utils.GetEnvDuration("LOGIN_LOCKOUT_MAX", 24*time.Hour),
+	}
+}
+
+// Check returns the time until which the IP or username is locked out, if any
+func (ls *LoginThrottleService) Check(ip, username string) (time.Time, bool) {
+	var throttles []models.LoginThrottle
+	ls.db.Where("key IN ? AND locked_until > ?", []string{ipKey(ip), userKey(username)}, time.Now()).Find(&throttles)
+
+	var lockedUntil time.Time
+	for _, t := range throttles {
+		if t.LockedUntil.After(lockedUntil) {
+			lockedUntil = *t.LockedUntil
+		}
+	}
+	return lockedUntil, !lockedUntil.IsZero()
+}
+
+// RecordFailure counts a failed attempt for both the IP and the username.
+// It returns the lockout expiry if the attempt caused a lockout.
+func (ls *LoginThrottleService) RecordFailure(ip, username string) (time.Time, bool) {
+	var lockedUntil time.Time
+	for key, limit := range map[string]int{ipKey(ip): ls.maxIPAttempts, userKey(username): ls.maxUserAttempts} {
+		until, err := ls.recordFailure(key, limit)
+		if err != nil {
+			log.Printf("[Login] Failed to record failed attempt for %s: %v", key, err)
+			continue
+		}
+		if until.After(lockedUntil) {
+			lockedUntil = until
+		}
+	}
+	return lockedUntil, !lockedUntil.IsZero()
+}
+
+// RecordSuccess clears the failure history of a username after a successful login.
+// The IP counter is left alone so one valid account cannot reset an attacker's budget.
+func (ls *LoginThrottleService) RecordSuccess(username string) {
+	ls.db.Where("key = ?", userKey(username)).Delete(&models.LoginThrottle{})
+}
+
+// List returns every tracked key, or only active lockouts when lockedOnly is set
+func (ls *LoginThrottleService) List(lockedOnly bool) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+	query := ls.db.Order("updated_at DESC")
+	if lockedOnly {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+	err := query.Find(&throttles).Error
+	return throttles, err
+}
+
+// Clear removes the lockout and failure history of a throttle entry
+func (ls *LoginThrottleService) Clear(id uint) error {
+	result := ls.db.Delete(&models.LoginThrottle{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CleanStale deletes entries that are not locked and have had no failures for a day
+func (ls *LoginThrottleService) CleanStale() error {
+	now := time.Now()
+	return ls.db.
+		Where("(locked_until IS NULL OR locked_until <= ?) AND last_failure_at <= ?", now, now.Add(-24*time.Hour)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// recordFailure counts a failure with atomic updates so concurrent attempts against the
+// same key are all counted
+func (ls *LoginThrottleService) recordFailure(key string, limit int) (time.Time, error) {
+	now := time.Now()
+
+	err := ls.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Key: key}).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Failures older than the window no longer count; a quiet day also resets the backoff
+	notLocked := "(locked_until IS NULL OR locked_until <= ?)"
+	err = ls.db.Model(&models.LoginThrottle{}).
+		Where("key = ? AND last_failure_at <= ? AND "+notLocked, key, now.Add(-ls.window), now).
+		Update("failures", 0).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	err = ls.db.Model(&models.LoginThrottle{}).
+		Where("key = ? AND last_failure_at <= ? AND "+notLocked, key, now.Add(-24*time.Hour), now).
+		Update("lockouts", 0).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	err = ls.db.Model(&models.LoginThrottle{}).
+		Where("key = ?", key).
+		Updates(map[string]interface{}{
+			"failures":        gorm.Expr("failures + 1"),
+			"last_failure_at": now,
+		}).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var throttle models.LoginThrottle
+	if err := ls.db.Where("key = ?", key).First(&throttle).Error; err != nil {
+		return time.Time{}, err
+	}
+	if throttle.Failures < limit {
+		return time.Time{}, nil
+	}
+
+	// Only the attempt that still sees the counter at the limit starts the lockout
+	lockedUntil := now.Add(ls.lockoutDuration(throttle.Lockouts))
+	result := ls.db.Model(&models.LoginThrottle{}).
+		Where("id = ? AND failures >= ?", throttle.ID, limit).
+		Updates(map[string]interface{}{
+			"locked_until": lockedUntil,
+			"lockouts":     gorm.Expr("lockouts + 1"),
+			"failures":     0,
+		})
+	if result.Error != nil {
+		return time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, nil
+	}
+
+	log.Printf("[Login] %s locked out until %s after repeated failures", key, lockedUntil.Format(time.RFC3339))
+	return lockedUntil, nil
+}
+
+// lockoutDuration doubles the base lockout for every previous lockout
+func (ls *LoginThrottleService) lockoutDuration(previousLockouts int) time.Duration {
+	duration := ls.baseLockout
+	for i := 0; i < previousLockouts; i++ {
+		duration *= 2
+		if duration >= ls.maxLockout {
+			return ls.maxLockout
+		}
+	}
+	return duration
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"sotsukenn/go/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a throwaway SQLite database with the given models migrated
+func newTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func newThrottleTestService(t *testing.T) *LoginThrottleService {
+	return &LoginThrottleService{
+		db:              newTestDB(t, &models.LoginThrottle{}),
+		maxUserAttempts: 1000,
+		maxIPAttempts:   1000,
+		window:          15 * time.Minute,
+		baseLockout:     time.Minute,
+		maxLockout:      time.Hour,
+	}
+}
+
+func TestLoginThrottleCountsConcurrentFailures(t *testing.T) {
+	ls := newThrottleTestService(t)
+
+	const attempts = 25
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ls.recordFailure("user:alice", 1000); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var throttle models.LoginThrottle
+	if err := ls.db.Where("key = ?", "user:alice").First(&throttle).Error; err != nil {
+		t.Fatal(err)
+	}
+	if throttle.Failures != attempts {
+		t.Fatalf("failures = %d, want %d", throttle.Failures, attempts)
+	}
+}
+
+func TestLoginThrottleLocksOnceAtLimit(t *testing.T) {
+	ls := newThrottleTestService(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	lockouts := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			until, err := ls.recordFailure("user:bob", 5)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !until.IsZero() {
+				mu.Lock()
+				lockouts++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if lockouts != 1 {
+		t.Fatalf("lockouts reported = %d, want 1", lockouts)
+	}
+	var throttle models.LoginThrottle
+	ls.db.Where("key = ?", "user:bob").First(&throttle)
+	if !throttle.IsLocked() || throttle.Lockouts != 1 || throttle.Failures != 0 {
+		t.Fatalf("throttle = %+v, want one active lockout and a reset counter", throttle)
+	}
+}
+
+func TestLoginThrottleForgetsOldFailures(t *testing.T) {
+	ls := newThrottleTestService(t)
+
+	old := time.Now().Add(-time.Hour)
+	ls.db.Create(&models.LoginThrottle{Key: "ip:10.0.0.1", Failures: 4, Lockouts: 2, LastFailureAt: &old})
+
+	if _, err := ls.recordFailure("ip:10.0.0.1", 5); err != nil {
+		t.Fatal(err)
+	}
+	var throttle models.LoginThrottle
+	ls.db.Where("key = ?", "ip:10.0.0.1").First(&throttle)
+	if throttle.Failures != 1 || throttle.Lockouts != 2 {
+		t.Fatalf("throttle = %+v, want failures reset to 1 and lockouts kept", throttle)
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrTooManyStreams is returned when the concurrent stream limit is reached
+var ErrTooManyStreams = errors.New("too many concurrent streams")
+
+// maxMJPEGFrameSize bounds a single frame so a broken upstream cannot exhaust memory
+const maxMJPEGFrameSize = 8 << 20
+
+// mjpegClientBuffer is the number of frames queued per viewer. A viewer that falls
+// further behind skips to the newest frames instead of slowing the shared upstream.
+const mjpegClientBuffer = 2
+
+// OpenStreamFunc opens an upstream MJPEG stream; the connection must end when ctx is cancelled
+type OpenStreamFunc func(ctx context.Context) (*http.Response, error)
+
+// MJPEGHub shares one upstream connection per camera among all of its viewers
+type MJPEGHub struct {
+	mu         sync.Mutex
+	upstreams  map[string]*mjpegUpstream
+	clients    int
+	maxClients int
+}
+
+type mjpegUpstream struct {
+	key         string
+	cancel      context.CancelFunc
+	subscribers map[*MJPEGSubscription]struct{}
+	lastFrame   []byte
+}
+
+// MJPEGSubscription receives the frames of one upstream stream for one viewer
+type MJPEGSubscription struct {
+	hub      *MJPEGHub
+	upstream *mjpegUpstream
+	frames   chan []byte
+	err      error
+	closed   bool
+}
+
+var (
+	mjpegHub     *MJPEGHub
+	mjpegHubOnce sync.Once
+)
+
+// MJPEGStreams returns the process-wide MJPEG hub; MJPEG_MAX_STREAMS (default 20)
+// limits the number of viewers across all cameras
+func MJPEGStreams() *MJPEGHub {
+	mjpegHubOnce.Do(func() {
+		mjpegHub = NewMJPEGHub(getEnvInt("MJPEG_MAX_STREAMS", 20))
+	})
+	return mjpegHub
+}
+
+// NewMJPEGHub creates a hub allowing at most maxClients concurrent viewers
+func NewMJPEGHub(maxClients int) *MJPEGHub {
+	return &MJPEGHub{
+		upstreams:  make(map[string]*mjpegUpstream),
+		maxClients: maxClients,
+	}
+}
+
+// Subscribe adds a viewer to the stream identified by key, opening the upstream
+// with open if nobody is watching it yet. The caller must Close the subscription.
+func (h *MJPEGHub) Subscribe(key string, open OpenStreamFunc) (*MJPEGSubscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients >= h.maxClients {
+		return nil, ErrTooManyStreams
+	}
+
+	upstream, exists := h.upstreams[key]
+	if !exists {
+		ctx, cancel := context.WithCancel(context.Background())
+		upstream = &mjpegUpstream{
+			key:         key,
+			cancel:      cancel,
+			subscribers: make(map[*MJPEGSubscription]struct{}),
+		}
+		h.upstreams[key] = upstream
+		go h.run(ctx, upstream, open)
+		log.Printf("[MJPEG] Opened upstream %s", key)
+	}
+
+	sub := &MJPEGSubscription{
+		hub:      h,
+		upstream: upstream,
+		frames:   make(chan []byte, mjpegClientBuffer),
+	}
+	// Late joiners start with the most recent frame instead of a blank screen
+	if upstream.lastFrame != nil {
+		sub.frames <- upstream.lastFrame
+	}
+	upstream.subscribers[sub] = struct{}{}
+	h.clients++
+	return sub, nil
+}
+
+// Frames delivers JPEG frames; it is closed when the upstream ends
+func (s *MJPEGSubscription) Frames() <-chan []byte {
+	return s.frames
+}
+
+// Err returns why the upstream ended, once Frames is closed
+func (s *MJPEGSubscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close removes the viewer; the upstream is disconnected when its last viewer leaves
+func (s *MJPEGSubscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	h.clients--
+	delete(s.upstream.subscribers, s)
+	close(s.frames)
+
+	if len(s.upstream.subscribers) == 0 && h.upstreams[s.upstream.key] == s.upstream {
+		delete(h.upstreams, s.upstream.key)
+		s.upstream.cancel()
+		log.Printf("[MJPEG] Closed upstream %s, no viewers left", s.upstream.key)
+	}
+}
+
+// run reads frames from the upstream and fans them out until it fails or is cancelled
+func (h *MJPEGHub) run(ctx context.Context, upstream *mjpegUpstream, open OpenStreamFunc) {
+	err := h.read(ctx, upstream, open)
+	if ctx.Err() != nil {
+		return
+	}
+
+	log.Printf("[MJPEG] Upstream %s ended: %v", upstream.key, err)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.upstreams[upstream.key] == upstream {
+		delete(h.upstreams, upstream.key)
+	}
+	for sub := range upstream.subscribers {
+		sub.err = err
+		sub.closed = true
+		h.clients--
+		close(sub.frames)
+	}
+	upstream.subscribers = map[*MJPEGSubscription]struct{}{}
+	upstream.cancel()
+}
+
+func (h *MJPEGHub) read(ctx context.Context, upstream *mjpegUpstream, open OpenStreamFunc) error {
+	resp, err := open(ctx)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("stream closed by Frigate")
+			}
+			return err
+		}
+
+		frame, err := io.ReadAll(io.LimitReader(part, maxMJPEGFrameSize+1))
+		if err != nil {
+			return err
+		}
+		if len(frame) > maxMJPEGFrameSize {
+			return fmt.Errorf("frame exceeds %d bytes", maxMJPEGFrameSize)
+		}
+		if len(frame) > 0 {
+			h.broadcast(upstream, frame)
+		}
+	}
+}
+
+// broadcast queues frame for every viewer without ever blocking on a slow one
+func (h *MJPEGHub) broadcast(upstream *mjpegUpstream, frame []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	upstream.lastFrame = frame
+	for sub := range upstream.subscribers {
+		select {
+		case sub.frames <- frame:
+			continue
+		default:
+		}
+		// Buffer full: drop the oldest queued frame to make room for the newest
+		select {
+		case <-sub.frames:
+		default:
+		}
+		select {
+		case sub.frames <- frame:
+		default:
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testBoundary = "frame"
+
+// fakeMJPEG serves multipart MJPEG streams written by the test
+type fakeMJPEG struct {
+	opens   int32
+	streams chan *fakeMJPEGStream
+}
+
+type fakeMJPEGStream struct {
+	ctx context.Context
+	pw  *io.PipeWriter
+}
+
+func newFakeMJPEG() *fakeMJPEG {
+	return &fakeMJPEG{streams: make(chan *fakeMJPEGStream, 4)}
+}
+
+func (f *fakeMJPEG) open(ctx context.Context) (*http.Response, error) {
+	atomic.AddInt32(&f.opens, 1)
+	pr, pw := io.Pipe()
+	go func() {
+		<-ctx.Done()
+		pw.CloseWithError(ctx.Err())
+	}()
+	f.streams <- &fakeMJPEGStream{ctx: ctx, pw: pw}
+	return &http.Response{
+		Header: http.Header{"Content-Type": {"multipart/x-mixed-replace; boundary=" + testBoundary}},
+		Body:   pr,
+	}, nil
+}
+
+func (f *fakeMJPEG) stream(t *testing.T) *fakeMJPEGStream {
+	t.Helper()
+	select {
+	case s := <-f.streams:
+		if _, err := s.pw.Write([]byte("--" + testBoundary + "\r\n")); err != nil {
+			t.Fatalf("writing the opening delimiter: %v", err)
+		}
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream was not opened")
+		return nil
+	}
+}
+
+// send writes one complete part; the trailing delimiter lets the reader finish the frame
+func (s *fakeMJPEGStream) send(t *testing.T, frame string) {
+	t.Helper()
+	part := fmt.Sprintf("Content-Type: image/jpeg\r\n\r\n%s\r\n--%s\r\n", frame, testBoundary)
+	if _, err := s.pw.Write([]byte(part)); err != nil {
+		t.Fatalf("writing frame %q: %v", frame, err)
+	}
+}
+
+func (s *fakeMJPEGStream) cancelled() bool {
+	select {
+	case <-s.ctx.Done():
+		return true
+	case <-time.After(2 * time.Second):
+		return false
+	}
+}
+
+func receive(t *testing.T, sub *MJPEGSubscription) string {
+	t.Helper()
+	select {
+	case frame, ok := <-sub.Frames():
+		if !ok {
+			t.Fatal("frames closed unexpectedly")
+		}
+		return string(frame)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no frame received")
+		return ""
+	}
+}
+
+func TestMJPEGHubFansOutOneUpstream(t *testing.T) {
+	hub := NewMJPEGHub(10)
+	upstream := newFakeMJPEG()
+
+	first, err := hub.Subscribe("1/front", upstream.open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := hub.Subscribe("1/front", upstream.open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	stream := upstream.stream(t)
+	for _, frame := range []string{"a", "b"} {
+		stream.send(t, frame)
+		if got := receive(t, first); got != frame {
+			t.Fatalf("first viewer got %q, want %q", got, frame)
+		}
+		if got := receive(t, second); got != frame {
+			t.Fatalf("second viewer got %q, want %q", got, frame)
+		}
+	}
+	if opens := atomic.LoadInt32(&upstream.opens); opens != 1 {
+		t.Fatalf("upstream opened %d times, want 1", opens)
+	}
+
+	// A late joiner starts with the most recent frame
+	third, err := hub.Subscribe("1/front", upstream.open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	if got := receive(t, third); got != "b" {
+		t.Fatalf("late viewer got %q, want the last frame", got)
+	}
+}
+
+func TestMJPEGHubDropsFramesForSlowViewers(t *testing.T) {
+	hub := NewMJPEGHub(10)
+	upstream := newFakeMJPEG()
+
+	fast, _ := hub.Subscribe("1/front", upstream.open)
+	defer fast.Close()
+	slow, _ := hub.Subscribe("1/front", upstream.open)
+	defer slow.Close()
+
+	stream := upstream.stream(t)
+	frames := []string{"1", "2", "3", "4", "5"}
+	for _, frame := range frames {
+		stream.send(t, frame)
+		if got := receive(t, fast); got != frame {
+			t.Fatalf("fast viewer got %q, want %q", got, frame)
+		}
+	}
+	// Wait for the broadcast of the last frame to finish
+	fast.Err()
+
+	// The slow viewer kept only the newest frames and never held up the fast one
+	if got := receive(t, slow); got != "4" {
+		t.Fatalf("slow viewer got %q, want %q", got, "4")
+	}
+	if got := receive(t, slow); got != "5" {
+		t.Fatalf("slow viewer got %q, want %q", got, "5")
+	}
+	select {
+	case frame := <-slow.Frames():
+		t.Fatalf("slow viewer got extra frame %q", frame)
+	default:
+	}
+}
+
+func TestMJPEGHubLimitsViewers(t *testing.T) {
+	hub := NewMJPEGHub(2)
+	front := newFakeMJPEG()
+	back := newFakeMJPEG()
+
+	a, err := hub.Subscribe("1/front", front.open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := hub.Subscribe("1/back", back.open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// The limit counts viewers across all cameras
+	if _, err := hub.Subscribe("1/front", front.open); !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("third viewer: err = %v, want ErrTooManyStreams", err)
+	}
+
+	a.Close()
+	a.Close() // closing twice must not free a second slot
+	c, err := hub.Subscribe("1/front", front.open)
+	if err != nil {
+		t.Fatalf("viewer after one left: %v", err)
+	}
+	defer c.Close()
+	if _, err := hub.Subscribe("1/back", back.open); !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("viewer over the limit after a double close: err = %v, want ErrTooManyStreams", err)
+	}
+}
+
+func TestMJPEGHubClosesUpstreamWithLastViewer(t *testing.T) {
+	hub := NewMJPEGHub(10)
+	upstream := newFakeMJPEG()
+
+	first, _ := hub.Subscribe("1/front", upstream.open)
+	second, _ := hub.Subscribe("1/front", upstream.open)
+	stream := upstream.stream(t)
+
+	first.Close()
+	select {
+	case <-stream.ctx.Done():
+		t.Fatal("upstream closed while a viewer is still watching")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	second.Close()
+	if !stream.cancelled() {
+		t.Fatal("upstream still open after the last viewer left")
+	}
+
+	// The next viewer opens a fresh upstream
+	third, _ := hub.Subscribe("1/front", upstream.open)
+	defer third.Close()
+	upstream.stream(t)
+	if opens := atomic.LoadInt32(&upstream.opens); opens != 2 {
+		t.Fatalf("upstream opened %d times, want 2", opens)
+	}
+}
+
+func TestMJPEGHubEndsViewersWhenUpstreamFails(t *testing.T) {
+	hub := NewMJPEGHub(1)
+	upstream := newFakeMJPEG()
+
+	sub, _ := hub.Subscribe("1/front", upstream.open)
+	defer sub.Close()
+	stream := upstream.stream(t)
+	stream.send(t, "a")
+	receive(t, sub)
+
+	stream.pw.CloseWithError(errors.New("connection reset"))
+	select {
+	case _, ok := <-sub.Frames():
+		if ok {
+			t.Fatal("expected frames to close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("frames not closed after the upstream failed")
+	}
+	if sub.Err() == nil {
+		t.Fatal("Err should explain why the stream ended")
+	}
+
+	// The failed viewer no longer counts towards the limit
+	next, err := hub.Subscribe("1/front", upstream.open)
+	if err != nil {
+		t.Fatalf("Subscribe after upstream failure: %v", err)
+	}
+	next.Close()
+}
@@ -12,28 +12,18 @@ import (
 
 // MonitoringService 处理Frigate健康检查和摄像头状态
 type MonitoringService struct {
-	db            *gorm.DB
-	frigateClient *FrigateClient
+	db *gorm.DB
 }
 
 // NewMonitoringService 创建监控服务
-func NewMonitoringService(db *gorm.DB, baseURL string) *MonitoringService {
+func NewMonitoringService(db *gorm.DB) *MonitoringService {
 	return &MonitoringService{
-		db:            db,
-		frigateClient: NewFrigateClient(baseURL),
+		db: db,
 	}
 }
 
-// GetFrigateStatus 获取Frigate状态
-func (ms *MonitoringService) GetFrigateStatus(userID uint) (*types.FrigateStatus, error) {
-	// 获取用户的Frigate配置
-	var frigateConnect models.FrigateConnect
-	err := ms.db.Where("user_id = ? AND is_active = ?", userID, true).
-		First(&frigateConnect).Error
-	if err != nil {
-		return nil, fmt.Errorf("frigate configuration not found: %w", err)
-	}
-
+// GetFrigateStatus 获取指定Frigate实例的状态
+func (ms *MonitoringService) GetFrigateStatus(frigateConnect *models.FrigateConnect) (*types.FrigateStatus, error) {
 	status := &types.FrigateStatus{
 		LastCheckTime: time.Now(),
 	}
@@ -41,8 +31,9 @@ func (ms *MonitoringService) GetFrigateStatus(userID uint) (*types.FrigateStatus
 	// 测试连接并测量响应时间
 	start := time.Now()
 
-	// 使用VerifyToken检查连接
-	tokenValid, err := ms.frigateClient.VerifyToken(frigateConnect.TokenCookie)
+	// 使用VerifyToken检查连接（token过期时会用保存的凭据自动重新登录）
+	client := NewFrigateClientForConnect(ms.db, frigateConnect)
+	tokenValid, err := client.VerifyToken(frigateConnect.TokenCookie)
 
 	elapsed := time.Since(start)
 	status.ResponseTime = elapsed.Milliseconds()
@@ -63,18 +54,10 @@ func (ms *MonitoringService) GetFrigateStatus(userID uint) (*types.FrigateStatus
 	return status, nil
 }
 
-// GetCameraStatus 获取摄像头在线状态
-func (ms *MonitoringService) GetCameraStatus(userID uint) (*types.CameraStatus, error) {
-	// 获取用户的Frigate配置
-	var frigateConnect models.FrigateConnect
-	err := ms.db.Where("user_id = ? AND is_active = ?", userID, true).
-		First(&frigateConnect).Error
-	if err != nil {
-		return nil, fmt.Errorf("frigate configuration not found: %w", err)
-	}
-
-	// 获取go2rtc流信息
-	streams, err := ms.frigateClient.GetGo2RTCStreamsWithToken(frigateConnect.TokenCookie)
+// GetCameraStatus 获取指定Frigate实例下摄像头的在线状态
+func (ms *MonitoringService) GetCameraStatus(frigateConnect *models.FrigateConnect) (*types.CameraStatus, error) {
+	// 获取go2rtc流信息（与其他请求共享缓存）
+	streams, err := CachedGo2RTCStreams(ms.db, frigateConnect)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get streams: %w", err)
 	}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+)
+
+// mosaicGap is the space in pixels between and around the tiles of a mosaic
+const mosaicGap = 2
+
+// MaxMosaicSize caps the width and height of a mosaic, so a canvas takes at most 64 MB as RGBA
+const MaxMosaicSize = 4096
+
+var (
+	mosaicLimiter     *StreamLimiter
+	mosaicLimiterOnce sync.Once
+)
+
+// MosaicRenders returns the process-wide limiter for mosaic renders, which each hold a
+// full canvas and the decoded snapshots in memory; MOSAIC_MAX_RENDERS (default 2) sets the limit
+func MosaicRenders() *StreamLimiter {
+	mosaicLimiterOnce.Do(func() {
+		mosaicLimiter = NewStreamLimiter(getEnvInt("MOSAIC_MAX_RENDERS", 2))
+	})
+	return mosaicLimiter
+}
+
+// Mosaic colors
+var (
+	mosaicBackground  = color.RGBA{0x10, 0x10, 0x10, 0xff}
+	mosaicPlaceholder = color.RGBA{0x30, 0x30, 0x30, 0xff}
+	mosaicCaptionBar  = color.RGBA{0, 0, 0, 0xa0} // Premultiplied, drawn over the snapshot
+	mosaicText        = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	mosaicOnline      = color.RGBA{0x2e, 0xb8, 0x4f, 0xff}
+	mosaicOffline     = color.RGBA{0xd9, 0x36, 0x36, 0xff}
+)
+
+// MosaicTile is one camera of a mosaic
+type MosaicTile struct {
+	Caption string
+	Online  bool
+	Image   image.Image // Latest snapshot, nil if none could be fetched
+}
+
+// MosaicLayout sets the grid of a mosaic
+type MosaicLayout struct {
+	Columns    int
+	TileWidth  int
+	TileHeight int
+	Captions   bool
+}
+
+// MosaicSize returns the size of a mosaic of n tiles
+func (l MosaicLayout) MosaicSize(n int) (width, height int) {
+	rows := (n + l.Columns - 1) / l.Columns
+	if rows < 1 {
+		rows = 1
+	}
+	return l.Columns*(l.TileWidth+mosaicGap) + mosaicGap, rows*(l.TileHeight+mosaicGap) + mosaicGap
+}
+
+// Fits reports whether a mosaic of n tiles stays within MaxMosaicSize
+func (l MosaicLayout) Fits(n int) bool {
+	width, height := l.MosaicSize(n)
+	return width <= MaxMosaicSize && height <= MaxMosaicSize
+}
+
+// RenderMosaic draws the tiles into a grid, left to right and top to bottom. Snapshots are
+// scaled to fit their tile; every tile gets an online/offline badge and, if enabled, a caption.
+func RenderMosaic(tiles []MosaicTile, layout MosaicLayout) *image.RGBA {
+	width, height := layout.MosaicSize(len(tiles))
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(mosaicBackground), image.Point{}, draw.Src)
+
+	scale := mosaicTextScale(layout.TileWidth)
+	for i, tile := range tiles {
+		x := mosaicGap + (i%layout.Columns)*(layout.TileWidth+mosaicGap)
+		y := mosaicGap + (i/layout.Columns)*(layout.TileHeight+mosaicGap)
+		rect := image.Rect(x, y, x+layout.TileWidth, y+layout.TileHeight)
+
+		draw.Draw(canvas, rect, image.NewUniform(mosaicPlaceholder), image.Point{}, draw.Src)
+		if tile.Image != nil {
+			drawScaled(canvas, rect, tile.Image)
+		} else {
+			drawCenteredText(canvas, rect, "NO IMAGE", scale)
+		}
+
+		drawBadge(canvas, rect, tile.Online, scale)
+		if layout.Captions {
+			drawCaption(canvas, rect, tile.Caption, scale)
+		}
+	}
+	return canvas
+}
+
+// mosaicTextScale enlarges the 5x7 font on big tiles
+func mosaicTextScale(tileWidth int) int {
+	if scale := tileWidth / 240; scale > 1 {
+		return scale
+	}
+	return 1
+}
+
+// drawScaled draws src into rect, keeping its aspect ratio and centering it.
+// Nearest-neighbor sampling is enough since Frigate already scales snapshots to the tile height.
+func drawScaled(dst *image.RGBA, rect image.Rectangle, src image.Image) {
+	bounds := src.Bounds()
+	if bounds.Empty() {
+		return
+	}
+
+	w, h := rect.Dx(), rect.Dy()
+	if bounds.Dx()*h > bounds.Dy()*w {
+		h = bounds.Dy() * w / bounds.Dx()
+	} else {
+		w = bounds.Dx() * h / bounds.Dy()
+	}
+	if w < 1 || h < 1 {
+		return
+	}
+	offsetX := rect.Min.X + (rect.Dx()-w)/2
+	offsetY := rect.Min.Y + (rect.Dy()-h)/2
+
+	for y := 0; y < h; y++ {
+		sy := bounds.Min.Y + (2*y+1)*bounds.Dy()/(2*h)
+		for x := 0; x < w; x++ {
+			sx := bounds.Min.X + (2*x+1)*bounds.Dx()/(2*w)
+			dst.Set(offsetX+x, offsetY+y, src.At(sx, sy))
+		}
+	}
+}
+
+// drawBadge draws an ONLINE or OFFLINE label into the top right corner of rect
+func drawBadge(dst *image.RGBA, rect image.Rectangle, online bool, scale int) {
+	label, background := "OFFLINE", mosaicOffline
+	if online {
+		label, background = "ONLINE", mosaicOnline
+	}
+
+	padding := 2 * scale
+	width := textWidth(label, scale) + 2*padding
+	height := glyphHeight*scale + 2*padding
+	badge := image.Rect(rect.Max.X-width-padding, rect.Min.Y+padding, rect.Max.X-padding, rect.Min.Y+padding+height).Intersect(rect)
+	draw.Draw(dst, badge, image.NewUniform(background), image.Point{}, draw.Src)
+	drawText(dst, badge, badge.Min.X+padding, badge.Min.Y+padding, label, scale)
+}
+
+// drawCaption draws text on a translucent bar along the bottom of rect
+func drawCaption(dst *image.RGBA, rect image.Rectangle, text string, scale int) {
+	padding := 2 * scale
+	bar := image.Rect(rect.Min.X, rect.Max.Y-glyphHeight*scale-2*padding, rect.Max.X, rect.Max.Y).Intersect(rect)
+	draw.Draw(dst, bar, image.NewUniform(mosaicCaptionBar), image.Point{}, draw.Over)
+	drawText(dst, bar, bar.Min.X+padding, bar.Min.Y+padding, text, scale)
+}
+
+func drawCenteredText(dst *image.RGBA, rect image.Rectangle, text string, scale int) {
+	x := rect.Min.X + (rect.Dx()-textWidth(text, scale))/2
+	y := rect.Min.Y + (rect.Dy()-glyphHeight*scale)/2
+	drawText(dst, rect, x, y, text, scale)
+}
+
+// drawText draws text with the built-in font, clipped to clip. Letters are upper-cased;
+// characters without a glyph are drawn as '?'.
+func drawText(dst *image.RGBA, clip image.Rectangle, x, y int, text string, scale int) {
+	for _, r := range strings.ToUpper(text) {
+		glyph, ok := mosaicFont[r]
+		if !ok {
+			glyph = mosaicFont['?']
+		}
+		for col, bits := range glyph {
+			for row := 0; row < glyphHeight; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				dot := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale).Intersect(clip)
+				draw.Draw(dst, dot, image.NewUniform(mosaicText), image.Point{}, draw.Src)
+			}
+		}
+		x += glyphAdvance * scale
+		if x >= clip.Max.X {
+			return
+		}
+	}
+}
+
+func textWidth(text string, scale int) int {
+	n := len([]rune(text))
+	if n == 0 {
+		return 0
+	}
+	return (n*glyphAdvance - 1) * scale
+}
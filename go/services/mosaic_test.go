@@ -0,0 +1,91 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestMosaicLayoutSize(t *testing.T) {
+	layout := MosaicLayout{Columns: 3, TileWidth: 100, TileHeight: 50}
+
+	tests := []struct {
+		tiles      int
+		wantWidth  int
+		wantHeight int
+	}{
+		{tiles: 0, wantWidth: 308, wantHeight: 54},
+		{tiles: 3, wantWidth: 308, wantHeight: 54},
+		{tiles: 4, wantWidth: 308, wantHeight: 106},
+		{tiles: 9, wantWidth: 308, wantHeight: 158},
+	}
+
+	for _, tt := range tests {
+		width, height := layout.MosaicSize(tt.tiles)
+		if width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("MosaicSize(%d) = %dx%d, want %dx%d", tt.tiles, width, height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestMosaicLayoutFits(t *testing.T) {
+	tests := []struct {
+		layout MosaicLayout
+		tiles  int
+		want   bool
+	}{
+		{MosaicLayout{Columns: 8, TileWidth: 320, TileHeight: 180}, 64, true},
+		{MosaicLayout{Columns: 3, TileWidth: 1280, TileHeight: 720}, 9, true},
+		{MosaicLayout{Columns: 4, TileWidth: 1280, TileHeight: 720}, 16, false},
+		{MosaicLayout{Columns: 8, TileWidth: 1280, TileHeight: 720}, 64, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.layout.Fits(tt.tiles); got != tt.want {
+			width, height := tt.layout.MosaicSize(tt.tiles)
+			t.Errorf("Fits(%d) for %dx%d = %v, want %v", tt.tiles, width, height, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMosaic(t *testing.T) {
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	snapshot := image.NewRGBA(image.Rect(0, 0, 160, 90))
+	draw.Draw(snapshot, snapshot.Bounds(), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	layout := MosaicLayout{Columns: 2, TileWidth: 160, TileHeight: 90, Captions: true}
+	tiles := []MosaicTile{
+		{Caption: "front", Online: true, Image: snapshot},
+		{Caption: "back", Online: false},
+	}
+	mosaic := RenderMosaic(tiles, layout)
+
+	if got, want := mosaic.Bounds(), image.Rect(0, 0, 326, 94); got != want {
+		t.Fatalf("bounds = %v, want %v", got, want)
+	}
+
+	// Snapshot in the first tile, placeholder in the second, background in the gaps
+	if got := mosaic.RGBAAt(mosaicGap+10, mosaicGap+40); got != blue {
+		t.Errorf("first tile = %v, want snapshot %v", got, blue)
+	}
+	if got := mosaic.RGBAAt(mosaicGap+160+mosaicGap+10, mosaicGap+40); got != mosaicPlaceholder {
+		t.Errorf("second tile = %v, want placeholder %v", got, mosaicPlaceholder)
+	}
+	if got := mosaic.RGBAAt(0, 0); got != mosaicBackground {
+		t.Errorf("gap = %v, want background %v", got, mosaicBackground)
+	}
+
+	// Badges sit in the top right corner of each tile
+	if got := mosaic.RGBAAt(mosaicGap+160-4, mosaicGap+3); got != mosaicOnline {
+		t.Errorf("first badge = %v, want online %v", got, mosaicOnline)
+	}
+	if got := mosaic.RGBAAt(2*mosaicGap+2*160-4, mosaicGap+3); got != mosaicOffline {
+		t.Errorf("second badge = %v, want offline %v", got, mosaicOffline)
+	}
+
+	// The caption bar darkens the bottom of the snapshot
+	if got := mosaic.RGBAAt(mosaicGap+159, mosaicGap+89); got == blue {
+		t.Errorf("caption bar was not drawn")
+	}
+}
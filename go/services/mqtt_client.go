@@ -2,9 +2,11 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +16,12 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// mqttPublishTimeout bounds how long Publish waits for the broker to acknowledge a command
+const mqttPublishTimeout = 5 * time.Second
+
+// ErrMQTTNotConnected is returned when a command is published while the client is disconnected
+var ErrMQTTNotConnected = errors.New("MQTT client is not connected")
+
 // MQTTClient handles MQTT connections and subscriptions
 type MQTTClient struct {
 	client              mqtt.Client
@@ -23,9 +31,11 @@ type MQTTClient struct {
 	username            string
 	password            string
 	topic               string
+	stateTopic          string // Camera switch states, e.g. frigate/+/+/state
 	connected           bool
 	mu                  sync.RWMutex
 	onMessage           func(models.FrigateEvent)
+	onConnect           func() // Called after every connect and reconnect
 	notificationService *NotificationService
 	eventService        *EventService // New: Event service for persisting events
 }
@@ -73,24 +83,33 @@ func NewMQTTClient(config types.MQTTConfig) *MQTTClient {
 	opts.SetKeepAlive(60 * time.Second)
 	opts.SetPingTimeout(1 * time.Second)
 
-	// Set connection handler
-	opts.OnConnect = func(client mqtt.Client) {
-		log.Printf("MQTT: Connected to broker %s", broker)
-	}
-
-	opts.OnConnectionLost = func(client mqtt.Client, err error) {
-		log.Printf("MQTT: Connection lost: %v", err)
-	}
-
 	client := &MQTTClient{
-		client:     mqtt.NewClient(opts),
 		brokerURL:  config.BrokerURL,
 		brokerPort: config.BrokerPort,
 		clientID:   config.ClientID,
 		topic:      config.Topic,
+		stateTopic: switchStateTopic(config.Topic),
 		connected:  false,
 	}
 
+	// Set connection handler. paho calls it in its own goroutine, so it waits for Connect
+	// to finish before reading the hook.
+	opts.OnConnect = func(mqtt.Client) {
+		log.Printf("MQTT: Connected to broker %s", broker)
+		client.mu.RLock()
+		onConnect := client.onConnect
+		client.mu.RUnlock()
+		if onConnect != nil {
+			onConnect()
+		}
+	}
+
+	opts.OnConnectionLost = func(client mqtt.Client, err error) {
+		log.Printf("MQTT: Connection lost: %v", err)
+	}
+
+	client.client = mqtt.NewClient(opts)
+
 	// Set default message handler
 	client.onMessage = func(event models.FrigateEvent) {
 		// Default: log camera and label
@@ -153,6 +172,39 @@ func (mc *MQTTClient) Subscribe() error {
 	}
 
 	log.Printf("MQTT: Subscribed to topic: %s", topic)
+
+	token = mc.client.Subscribe(mc.stateTopic, 0, mc.stateHandler)
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("failed to subscribe to switch states: %w", token.Error())
+	}
+
+	log.Printf("MQTT: Subscribed to topic: %s", mc.stateTopic)
+	return nil
+}
+
+// switchStateTopic derives the camera switch state subscription from the events topic:
+// frigate/events becomes frigate/+/+/state and +/events becomes +/+/+/state
+func switchStateTopic(eventsTopic string) string {
+	return strings.TrimSuffix(eventsTopic, "/events") + "/+/+/state"
+}
+
+// Publish sends a command to Frigate, e.g. frigate/front/ptz. Commands are sent with QoS 1
+// and not retained, so they are not replayed when Frigate reconnects.
+func (mc *MQTTClient) Publish(topic, payload string) error {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	if !mc.connected {
+		return ErrMQTTNotConnected
+	}
+
+	token := mc.client.Publish(topic, 1, false, payload)
+	if !token.WaitTimeout(mqttPublishTimeout) {
+		return fmt.Errorf("failed to publish to %s: timed out", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
 	return nil
 }
 
@@ -163,6 +215,13 @@ func (mc *MQTTClient) SetMessageHandler(handler func(models.FrigateEvent)) {
 	mc.onMessage = handler
 }
 
+// SetOnConnect sets a function called after every connect and automatic reconnect
+func (mc *MQTTClient) SetOnConnect(handler func()) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.onConnect = handler
+}
+
 // SetNotificationService sets the notification service for sending FCM notifications
 func (mc *MQTTClient) SetNotificationService(ns *NotificationService) {
 	mc.mu.Lock()
@@ -181,6 +240,9 @@ func (mc *MQTTClient) SetEventService(es *EventService) {
 func (mc *MQTTClient) messageHandler(client mqtt.Client, msg mqtt.Message) {
 	payload := msg.Payload()
 
+	// Frigate publishes events on <topic_prefix>/events; the prefix identifies the instance
+	topicPrefix := strings.TrimSuffix(msg.Topic(), "/events")
+
 	// Parse JSON payload
 	var event models.FrigateEvent
 	if err := json.Unmarshal(payload, &event); err != nil {
@@ -198,7 +260,7 @@ func (mc *MQTTClient) messageHandler(client mqtt.Client, msg mqtt.Message) {
 
 	// Save event to database if event service is configured
 	if eventSvc != nil {
-		if err := eventSvc.SaveDetectionEvent(event); err != nil {
+		if err := eventSvc.SaveDetectionEvent(event, topicPrefix); err != nil {
 			log.Printf("MQTT: Failed to save detection event: %v", err)
 		}
 	}
@@ -209,12 +271,18 @@ func (mc *MQTTClient) messageHandler(client mqtt.Client, msg mqtt.Message) {
 
 	// Send FCM notification if service is configured
 	if notificationSvc != nil {
-		if err := notificationSvc.SendNotification(event); err != nil {
+		if err := notificationSvc.SendNotification(event, topicPrefix); err != nil {
 			log.Printf("MQTT: Failed to send FCM notification: %v", err)
 		}
 	}
 }
 
+// stateHandler tracks the camera switch states Frigate publishes, e.g. frigate/front/detect/state.
+// Other /state topics such as ptz_autotracker are ignored.
+func (mc *MQTTClient) stateHandler(client mqtt.Client, msg mqtt.Message) {
+	CameraSwitches().HandleState(msg.Topic(), string(msg.Payload()), time.Now())
+}
+
 // IsConnected returns the connection status
 func (mc *MQTTClient) IsConnected() bool {
 	mc.mu.RLock()
@@ -228,9 +296,10 @@ func (mc *MQTTClient) GetStatus() map[string]interface{} {
 	defer mc.mu.RUnlock()
 
 	return map[string]interface{}{
-		"connected": mc.connected,
-		"broker":    fmt.Sprintf("%s:%s", mc.brokerURL, mc.brokerPort),
-		"client_id": mc.clientID,
-		"topic":     mc.topic,
+		"connected":   mc.connected,
+		"broker":      fmt.Sprintf("%s:%s", mc.brokerURL, mc.brokerPort),
+		"client_id":   mc.clientID,
+		"topic":       mc.topic,
+		"state_topic": mc.stateTopic,
 	}
 }
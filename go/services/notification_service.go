@@ -156,15 +156,16 @@ func (ns *NotificationService) extractPersonName(subLabel interface{}) string {
 	return ""
 }
 
-// SendNotification sends FCM notification to all users for a Frigate event
-func (ns *NotificationService) SendNotification(event models.FrigateEvent) error {
+// SendNotification sends FCM notification for a Frigate event to the members of the
+// households whose Frigate instance publishes under topicPrefix, subject to camera permissions
+func (ns *NotificationService) SendNotification(event models.FrigateEvent, topicPrefix string) error {
 	// Check if we should send notification
 	if !ns.ShouldSendNotification(event) {
 		return nil
 	}
 
 	// Check debounce
-	debounceKey := event.After.ID + "_" + event.Type
+	debounceKey := topicPrefix + "_" + event.After.ID + "_" + event.Type
 	if ns.isDebounced(debounceKey) {
 		log.Printf("[FCM] Notification debounced: %s", debounceKey)
 		return nil
@@ -173,9 +174,14 @@ func (ns *NotificationService) SendNotification(event models.FrigateEvent) error
 	// Generate notification content
 	title, body, data := ns.GenerateNotificationContent(event)
 
-	// Get all active FCM tokens from all users
+	// Get active FCM tokens of the household members allowed to be notified about this camera
+	userIDs, err := NewCameraACLService(ns.db).NotificationRecipients(topicPrefix, event.After.Camera)
+	if err != nil {
+		return err
+	}
+
 	var tokens []models.FCMToken
-	err := ns.db.Where("is_active = ?", true).Find(&tokens).Error
+	err = ns.db.Where("is_active = ? AND user_id IN ?", true, userIDs).Find(&tokens).Error
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid, used or expired")
+
+// PasswordService changes local passwords and manages admin-issued reset tokens
+type PasswordService struct {
+	db       *gorm.DB
+	resetTTL time.Duration
+}
+
+// NewPasswordService creates a password service
+// PASSWORD_RESET_TOKEN_TTL controls how long a reset token stays valid (default 24h)
+func NewPasswordService(db *gorm.DB) *PasswordService {
+	return &PasswordService{
+		db:       db,
+		resetTTL: utils.GetEnvDuration("PASSWORD_RESET_TOKEN_TTL", 24*time.Hour),
+	}
+}
+
+// SetPassword stores a new local password for the user.
+// The password is local from now on and no longer follows the Frigate password.
+func (ps *PasswordService) SetPassword(userID uint, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return setLocalPassword(ps.db, userID, hashedPassword)
+}
+
+func hashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hashedPassword), nil
+}
+
+func setLocalPassword(db *gorm.DB, userID uint, hashedPassword string) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password":                 hashedPassword,
+		"frigate_managed_password": false,
+	}).Error
+}
+
+// SyncFrigatePassword replaces the local copy of a Frigate-managed password after
+// Frigate accepted it, so a password changed in Frigate keeps working here
+func (ps *PasswordService) SyncFrigatePassword(userID uint, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return ps.db.Model(&models.User{}).
+		Where("id = ? AND frigate_managed_password = ?", userID, true).
+		Update("password", hashedPassword).Error
+}
+
+// IssueResetToken creates a single-use reset token for the user, replacing any
+// unused token issued before. The raw token is only returned here.
+func (ps *PasswordService) IssueResetToken(userID, issuedBy uint) (string, *models.PasswordResetToken, error) {
+	raw, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: models.HashToken(raw),
+		CreatedBy: issuedBy,
+		ExpiresAt: time.Now().Add(ps.resetTTL),
+	}
+
+	err = ps.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", userID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save reset token: %w", err)
+	}
+	return raw, &record, nil
+}
+
+// ResetWithToken consumes a reset token and sets the new password of its user
+func (ps *PasswordService) ResetWithToken(raw, password string) (*models.User, error) {
+	var record models.PasswordResetToken
+	if err := ps.db.Where("token_hash = ?", models.HashToken(raw)).First(&record).Error; err != nil {
+		return nil, ErrResetTokenInvalid
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, ErrResetTokenInvalid
+	}
+
+	var user models.User
+	if err := ps.db.First(&user, record.UserID).Error; err != nil {
+		return nil, ErrResetTokenInvalid
+	}
+
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token is only used up together with the new password; only one concurrent
+	// request can win the used_at update
+	err = ps.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", record.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrResetTokenInvalid
+		}
+		return setLocalPassword(tx, user.ID, hashedPassword)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CleanExpired deletes reset tokens that can no longer be used
+func (ps *PasswordService) CleanExpired() error {
+	return ps.db.Where("expires_at <= ? OR used_at IS NOT NULL", time.Now()).Delete(&models.PasswordResetToken{}).Error
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sotsukenn/go/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestResetWithTokenKeepsTokenWhenPasswordFails(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.PasswordResetToken{})
+	ps := &PasswordService{db: db, resetTTL: time.Hour}
+	user := models.User{Username: "alice", Password: "old-hash"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	raw, _, err := ps.IssueResetToken(user.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bcrypt refuses passwords over 72 bytes; the token must survive that
+	if _, err := ps.ResetWithToken(raw, strings.Repeat("x", 73)); err == nil {
+		t.Fatal("ResetWithToken accepted a password bcrypt cannot hash")
+	}
+	if _, err := ps.ResetWithToken(raw, "new-password"); err != nil {
+		t.Fatalf("ResetWithToken after a failed attempt = %v, want the token still usable", err)
+	}
+
+	db.First(&user, user.ID)
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("new-password")) != nil {
+		t.Fatal("password was not changed")
+	}
+	if _, err := ps.ResetWithToken(raw, "another-password"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Fatalf("second use of the token = %v, want ErrResetTokenInvalid", err)
+	}
+}
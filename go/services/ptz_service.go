@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/types"
+)
+
+// PTZ features reported by Frigate's /api/<camera>/ptz/info
+const (
+	PTZFeaturePanTilt = "pt"
+	PTZFeatureZoom    = "zoom"
+)
+
+// ErrPTZUnsupported is returned when a camera lacks the feature a command needs
+var ErrPTZUnsupported = errors.New("camera does not support this PTZ command")
+
+// ErrPTZInvalidCommand is returned for unknown directions and presets
+var ErrPTZInvalidCommand = errors.New("invalid PTZ command")
+
+// ptzMoves and ptzZooms map API directions to Frigate's continuous PTZ commands
+var (
+	ptzMoves = map[string]string{
+		"left":  "MOVE_LEFT",
+		"right": "MOVE_RIGHT",
+		"up":    "MOVE_UP",
+		"down":  "MOVE_DOWN",
+	}
+	ptzZooms = map[string]string{
+		"in":  "ZOOM_IN",
+		"out": "ZOOM_OUT",
+	}
+)
+
+// PTZCommand is a payload for Frigate's <topic_prefix>/<camera>/ptz topic
+type PTZCommand struct {
+	Payload string
+	Feature string // Feature the camera must report, empty if none
+	Preset  string // Preset the camera must have, empty if none
+}
+
+// PTZMove starts moving the camera until PTZStop is sent
+func PTZMove(direction string) (PTZCommand, error) {
+	payload, ok := ptzMoves[direction]
+	if !ok {
+		return PTZCommand{}, fmt.Errorf("%w: direction must be left, right, up or down", ErrPTZInvalidCommand)
+	}
+	return PTZCommand{Payload: payload, Feature: PTZFeaturePanTilt}, nil
+}
+
+// PTZZoom starts zooming the camera until PTZStop is sent
+func PTZZoom(direction string) (PTZCommand, error) {
+	payload, ok := ptzZooms[direction]
+	if !ok {
+		return PTZCommand{}, fmt.Errorf("%w: direction must be in or out", ErrPTZInvalidCommand)
+	}
+	return PTZCommand{Payload: payload, Feature: PTZFeatureZoom}, nil
+}
+
+// PTZStop stops any movement and zoom
+func PTZStop() PTZCommand {
+	return PTZCommand{Payload: "STOP"}
+}
+
+// PTZPreset moves the camera to one of its ONVIF presets
+func PTZPreset(preset string) (PTZCommand, error) {
+	if preset == "" {
+		return PTZCommand{}, fmt.Errorf("%w: preset is required", ErrPTZInvalidCommand)
+	}
+	return PTZCommand{Payload: "preset_" + preset, Preset: preset}, nil
+}
+
+// Check verifies that the camera described by info can carry out the command
+func (c PTZCommand) Check(info *types.PTZInfo) error {
+	if c.Feature != "" && !containsString(info.Features, c.Feature) {
+		return ErrPTZUnsupported
+	}
+	if c.Preset != "" && !containsString(info.Presets, c.Preset) {
+		return fmt.Errorf("%w: unknown preset %q", ErrPTZInvalidCommand, c.Preset)
+	}
+	return nil
+}
+
+// PTZTopic returns the MQTT topic Frigate listens on for PTZ commands of a camera
+func PTZTopic(frigateConnect *models.FrigateConnect, camera string) string {
+	return fmt.Sprintf("%s/%s/ptz", frigateConnect.MQTTTopicPrefix, camera)
+}
+
+// SendPTZ publishes a command for a camera of the instance through the MQTT client
+func SendPTZ(client *MQTTClient, frigateConnect *models.FrigateConnect, camera string, command PTZCommand) error {
+	return client.Publish(PTZTopic(frigateConnect, camera), command.Payload)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
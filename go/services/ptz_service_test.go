@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/types"
+)
+
+func TestPTZCommands(t *testing.T) {
+	info := &types.PTZInfo{Features: []string{PTZFeaturePanTilt}, Presets: []string{"driveway"}}
+
+	tests := []struct {
+		name        string
+		command     func() (PTZCommand, error)
+		wantPayload string
+		wantErr     error // From building the command
+		wantCheck   error // From checking it against info
+	}{
+		{name: "move", command: func() (PTZCommand, error) { return PTZMove("left") }, wantPayload: "MOVE_LEFT"},
+		{name: "unknown direction", command: func() (PTZCommand, error) { return PTZMove("north") }, wantErr: ErrPTZInvalidCommand},
+		{name: "zoom unsupported", command: func() (PTZCommand, error) { return PTZZoom("in") }, wantPayload: "ZOOM_IN", wantCheck: ErrPTZUnsupported},
+		{name: "stop", command: func() (PTZCommand, error) { return PTZStop(), nil }, wantPayload: "STOP"},
+		{name: "preset", command: func() (PTZCommand, error) { return PTZPreset("driveway") }, wantPayload: "preset_driveway"},
+		{name: "unknown preset", command: func() (PTZCommand, error) { return PTZPreset("garden") }, wantPayload: "preset_garden", wantCheck: ErrPTZInvalidCommand},
+		{name: "empty preset", command: func() (PTZCommand, error) { return PTZPreset("") }, wantErr: ErrPTZInvalidCommand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := tt.command()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if command.Payload != tt.wantPayload {
+				t.Errorf("payload = %q, want %q", command.Payload, tt.wantPayload)
+			}
+			if err := command.Check(info); !errors.Is(err, tt.wantCheck) {
+				t.Errorf("Check() = %v, want %v", err, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestPTZTopic(t *testing.T) {
+	frigateConnect := &models.FrigateConnect{MQTTTopicPrefix: "shop"}
+	if got, want := PTZTopic(frigateConnect, "front"), "shop/front/ptz"; got != want {
+		t.Errorf("PTZTopic() = %q, want %q", got, want)
+	}
+}
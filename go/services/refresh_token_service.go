@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sotsukenn/go/models"
+	"sotsukenn/go/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// RefreshTokenService issues and rotates server-side refresh tokens
+type RefreshTokenService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+// NewRefreshTokenService creates a refresh token service
+// REFRESH_TOKEN_TTL controls how long a refresh token stays valid (default 30 days)
+func NewRefreshTokenService(db *gorm.DB) *RefreshTokenService {
+	return &RefreshTokenService{
+		db:  db,
+		ttl: utils.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	}
+}
+
+// TTL returns the lifetime of newly issued refresh tokens
+func (rs *RefreshTokenService) TTL() time.Duration {
+	return rs.ttl
+}
+
+// NewFamily returns a fresh family identifier for a new login
+func (rs *RefreshTokenService) NewFamily() (string, error) {
+	return utils.GenerateSecureToken(16)
+}
+
+// Issue creates a new refresh token in the given family and returns the raw token
+func (rs *RefreshTokenService) Issue(userID uint, familyID string) (string, *models.RefreshToken, error) {
+	return rs.issue(rs.db, userID, familyID)
+}
+
+func (rs *RefreshTokenService) issue(db *gorm.DB, userID uint, familyID string) (string, *models.RefreshToken, error) {
+	raw, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := models.RefreshToken{
+		TokenHash: models.HashToken(raw),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(rs.ttl),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return raw, &record, nil
+}
+
+// Rotate exchanges a refresh token for a new one in the same family.
+// Presenting a token that was already used revokes the whole family and
+// returns ErrRefreshTokenReused together with the offending record.
+func (rs *RefreshTokenService) Rotate(raw string) (string, *models.RefreshToken, error) {
+	var record models.RefreshToken
+	err := rs.db.Where("token_hash = ?", models.HashToken(raw)).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, ErrRefreshTokenInvalid
+		}
+		return "", nil, err
+	}
+
+	if record.RevokedAt != nil || time.Now().After(record.ExpiresAt) {
+		return "", &record, ErrRefreshTokenInvalid
+	}
+
+	if record.UsedAt != nil {
+		return "", &record, rs.reuseDetected(&record)
+	}
+
+	// Marking the token used and issuing its successor happen together, so a failed
+	// insert does not leave the family without a usable token
+	var newRaw string
+	var newRecord *models.RefreshToken
+	err = rs.db.Transaction(func(tx *gorm.DB) error {
+		// The used_at guard makes concurrent exchanges of the same token lose
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", record.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		newRaw, newRecord, err = rs.issue(tx, record.UserID, record.FamilyID)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		return "", &record, rs.reuseDetected(&record)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return newRaw, newRecord, nil
+}
+
+// Revert undoes a Rotate whose new token never reached the client: the successor
+// is deleted and the presented token becomes usable again, so a retry with it is
+// not mistaken for reuse. Nothing is restored once the successor has been used.
+func (rs *RefreshTokenService) Revert(raw string, successor *models.RefreshToken) error {
+	return rs.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", successor.ID).
+			Delete(&models.RefreshToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenInvalid
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("token_hash = ? AND family_id = ? AND revoked_at IS NULL", models.HashToken(raw), successor.FamilyID).
+			Update("used_at", nil).Error
+	})
+}
+
+// RevokeFamily revokes every outstanding refresh token of a family
+func (rs *RefreshTokenService) RevokeFamily(familyID string) error {
+	return rs.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUser revokes every outstanding refresh token of a user
+func (rs *RefreshTokenService) RevokeUser(userID uint) error {
+	return rs.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// CleanExpired deletes refresh tokens that can no longer be exchanged
+func (rs *RefreshTokenService) CleanExpired() error {
+	return rs.db.Where("expires_at <= ?", time.Now()).Delete(&models.RefreshToken{}).Error
+}
+
+func (rs *RefreshTokenService) reuseDetected(record *models.RefreshToken) error {
+	log.Printf("[Auth] Refresh token reuse detected for user %d, revoking family %s", record.UserID, record.FamilyID)
+	if err := rs.RevokeFamily(record.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+	return ErrRefreshTokenReused
+}
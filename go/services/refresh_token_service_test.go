@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sotsukenn/go/models"
+)
+
+func newRefreshTestService(t *testing.T) *RefreshTokenService {
+	return &RefreshTokenService{
+		db:  newTestDB(t, &models.RefreshToken{}),
+		ttl: time.Hour,
+	}
+}
+
+func TestRefreshTokenRotate(t *testing.T) {
+	rs := newRefreshTestService(t)
+
+	first, _, err := rs.Issue(1, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, record, err := rs.Rotate(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || record.FamilyID != "family" || record.UserID != 1 {
+		t.Fatalf("unexpected successor %+v", record)
+	}
+
+	if _, _, err := rs.Rotate(second); err != nil {
+		t.Fatalf("successor should be exchangeable: %v", err)
+	}
+	if _, _, err := rs.Rotate("unknown"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("unknown token: got %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	rs := newRefreshTestService(t)
+
+	first, _, err := rs.Issue(1, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := rs.Issue(1, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := rs.Rotate(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, record, err := rs.Rotate(first)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed token: got %v", err)
+	}
+	if record == nil || record.FamilyID != "family" {
+		t.Fatalf("reuse should report the offending record, got %+v", record)
+	}
+	if _, _, err := rs.Rotate(second); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("successor after reuse: got %v", err)
+	}
+	if _, _, err := rs.Rotate(other); err != nil {
+		t.Fatalf("other family should be untouched: %v", err)
+	}
+}
+
+func TestRefreshTokenRevokeFamily(t *testing.T) {
+	rs := newRefreshTestService(t)
+
+	token, _, err := rs.Issue(1, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.RevokeFamily("family"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rs.Rotate(token); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("revoked token: got %v", err)
+	}
+}
+
+func TestRefreshTokenRevert(t *testing.T) {
+	rs := newRefreshTestService(t)
+
+	first, _, err := rs.Issue(1, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, record, err := rs.Rotate(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Revert(first, record); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := rs.Rotate(lost); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("reverted successor: got %v", err)
+	}
+	second, record, err := rs.Rotate(first)
+	if err != nil {
+		t.Fatalf("retry with the old token should succeed: %v", err)
+	}
+
+	// Once the successor has been used there is nothing to undo
+	if _, _, err := rs.Rotate(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Revert(first, record); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("revert of a used successor: got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"time"
+
+	"sotsukenn/go/models"
+
+	"gorm.io/gorm"
+)
+
+// lastSeenInterval limits how often a session's last-seen time is written
+const lastSeenInterval = time.Minute
+
+// SessionService records per-device login sessions
+type SessionService struct {
+	db *gorm.DB
+}
+
+// NewSessionService creates a session service
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// Create records a new session for a token family
+func (ss *SessionService) Create(userID uint, familyID, deviceName, ip, userAgent string) (*models.UserSession, error) {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	if len(deviceName) > 100 {
+		deviceName = deviceName[:100]
+	}
+
+	session := models.UserSession{
+		UserID:     userID,
+		FamilyID:   familyID,
+		DeviceName: deviceName,
+		IPAddress:  ip,
+		UserAgent:  userAgent,
+		LastSeenAt: time.Now(),
+	}
+	if err := ss.db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Touch updates the last-seen time and IP of a session, at most once per minute
+func (ss *SessionService) Touch(familyID, ip string) {
+	now := time.Now()
+	ss.db.Model(&models.UserSession{}).
+		Where("family_id = ? AND last_seen_at < ?", familyID, now.Add(-lastSeenInterval)).
+		Updates(map[string]interface{}{"last_seen_at": now, "ip_address": ip})
+}
+
+// ListActive returns the sessions of a user that can still be refreshed, most recently used first
+func (ss *SessionService) ListActive(userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := ss.db.
+		Where("user_id = ? AND family_id IN (?)", userID, ss.liveFamilies()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// FindActive returns an active session of a user by ID
+func (ss *SessionService) FindActive(userID, id uint) (*models.UserSession, error) {
+	var session models.UserSession
+	err := ss.db.
+		Where("id = ? AND user_id = ? AND family_id IN (?)", id, userID, ss.liveFamilies()).
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// DeactivateFCMTokens deactivates the push tokens registered from the given sessions
+func (ss *SessionService) DeactivateFCMTokens(familyIDs ...string) error {
+	if len(familyIDs) == 0 {
+		return nil
+	}
+	return ss.db.Model(&models.FCMToken{}).
+		Where("family_id IN ?", familyIDs).
+		Update("is_active", false).Error
+}
+
+// CleanInactive deletes sessions whose token family can no longer be refreshed
+func (ss *SessionService) CleanInactive() error {
+	return ss.db.
+		Where("family_id NOT IN (?) AND created_at < ?", ss.liveFamilies(), time.Now().Add(-lastSeenInterval)).
+		Delete(&models.UserSession{}).Error
+}
+
+// liveFamilies selects the token families that still hold an unused, unrevoked refresh token
+func (ss *SessionService) liveFamilies() *gorm.DB {
+	return ss.db.Model(&models.RefreshToken{}).
+		Select("family_id").
+		Where("used_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+}
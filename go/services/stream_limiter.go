@@ -0,0 +1,39 @@
+package services
+
+import "sync"
+
+// StreamLimiter caps the number of concurrent long-running streams that each hold
+// their own upstream connection
+type StreamLimiter struct {
+	slots chan struct{}
+}
+
+var (
+	go2rtcLimiter     *StreamLimiter
+	go2rtcLimiterOnce sync.Once
+)
+
+// Go2RTCStreams returns the process-wide limiter for go2rtc MP4 streams;
+// GO2RTC_MAX_STREAMS (default 20) sets the limit
+func Go2RTCStreams() *StreamLimiter {
+	go2rtcLimiterOnce.Do(func() {
+		go2rtcLimiter = NewStreamLimiter(getEnvInt("GO2RTC_MAX_STREAMS", 20))
+	})
+	return go2rtcLimiter
+}
+
+// NewStreamLimiter creates a limiter allowing at most max concurrent streams
+func NewStreamLimiter(max int) *StreamLimiter {
+	return &StreamLimiter{slots: make(chan struct{}, max)}
+}
+
+// Acquire takes a slot or fails with ErrTooManyStreams; call release when the stream ends
+func (l *StreamLimiter) Acquire() (release func(), err error) {
+	select {
+	case l.slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-l.slots }) }, nil
+	default:
+		return nil, ErrTooManyStreams
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"math"
+	"time"
+)
+
+// julianUnixEpoch is the Julian date of 1970-01-01 00:00 UTC
+const julianUnixEpoch = 2440587.5
+
+// julian2000 is the Julian date of 2000-01-01 12:00 UTC (J2000)
+const julian2000 = 2451545.0
+
+// SunTimes computes sunrise and sunset on the calendar day of date at the given latitude and
+// longitude (degrees, east positive) with the sunrise equation; results are within a minute
+// or two. ok is false during polar day or night.
+func SunTimes(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, ok bool) {
+	// Days since J2000 at noon of the calendar day, shifted to local mean solar time
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(toJulian(noon) - julian2000)
+	meanSolarTime := n - longitude/360
+
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarTime, 360)
+	m := radians(anomaly)
+	center := 1.9148*math.Sin(m) + 0.0200*math.Sin(2*m) + 0.0003*math.Sin(3*m)
+	eclipticLongitude := radians(math.Mod(anomaly+center+180+102.9372, 360))
+	transit := julian2000 + meanSolarTime + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*eclipticLongitude)
+
+	declination := math.Asin(math.Sin(eclipticLongitude) * math.Sin(radians(23.4397)))
+	phi := radians(latitude)
+	// -0.833° accounts for refraction and the radius of the sun's disc
+	cosHourAngle := (math.Sin(radians(-0.833)) - math.Sin(phi)*math.Sin(declination)) / (math.Cos(phi) * math.Cos(declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+
+	sunrise = fromJulian(transit - hourAngle/360).In(date.Location())
+	sunset = fromJulian(transit + hourAngle/360).In(date.Location())
+	return sunrise, sunset, true
+}
+
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(j float64) time.Time {
+	return time.Unix(0, int64((j-julianUnixEpoch)*86400*float64(time.Second))).Truncate(time.Second)
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
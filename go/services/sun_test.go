@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	newYork := time.FixedZone("EDT", -4*3600)
+
+	tests := []struct {
+		name        string
+		date        time.Time
+		lat, lon    float64
+		wantSunrise string
+		wantSunset  string
+	}{
+		{name: "Tokyo summer solstice", date: time.Date(2026, 6, 21, 0, 0, 0, 0, tokyo), lat: 35.6895, lon: 139.6917, wantSunrise: "04:25", wantSunset: "19:00"},
+		{name: "Tokyo winter solstice", date: time.Date(2026, 12, 21, 0, 0, 0, 0, tokyo), lat: 35.6895, lon: 139.6917, wantSunrise: "06:47", wantSunset: "16:32"},
+		{name: "New York equinox", date: time.Date(2026, 9, 22, 0, 0, 0, 0, newYork), lat: 40.7128, lon: -74.0060, wantSunrise: "06:45", wantSunset: "18:54"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, sunset, ok := SunTimes(tt.date, tt.lat, tt.lon)
+			if !ok {
+				t.Fatal("SunTimes() reported no sunrise")
+			}
+			assertNear(t, "sunrise", sunrise, tt.date, tt.wantSunrise)
+			assertNear(t, "sunset", sunset, tt.date, tt.wantSunset)
+		})
+	}
+}
+
+func TestSunTimesPolarNight(t *testing.T) {
+	if _, _, ok := SunTimes(time.Date(2026, 12, 21, 0, 0, 0, 0, time.UTC), 78.22, 15.65); ok {
+		t.Error("SunTimes() in Longyearbyen at the winter solstice reported a sunrise")
+	}
+}
+
+// assertNear checks that got is within 3 minutes of the "HH:MM" want on the day of date
+func assertNear(t *testing.T, name string, got, date time.Time, want string) {
+	t.Helper()
+	clock, err := time.Parse("15:04", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTime := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, date.Location())
+	if diff := got.Sub(wantTime); diff < -3*time.Minute || diff > 3*time.Minute {
+		t.Errorf("%s = %s, want about %s", name, got.In(date.Location()).Format("2006-01-02 15:04"), want)
+	}
+}
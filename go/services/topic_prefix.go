@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"sotsukenn/go/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTopicPrefixInvalid is returned for prefixes that are not a plain MQTT topic
+	ErrTopicPrefixInvalid = errors.New("MQTT topic prefix must be a single non-empty topic level without /, + or #")
+	// ErrTopicPrefixInUse is returned when another Frigate instance already uses the prefix
+	ErrTopicPrefixInUse = errors.New("MQTT topic prefix is already used by another Frigate instance")
+	// ErrTopicPrefixNotRoutable is returned when commands for an instance cannot be published
+	// because its prefix is unset or also used by another household
+	ErrTopicPrefixNotRoutable = errors.New("MQTT topic prefix of the Frigate instance is unset or used by another household")
+)
+
+// ValidateTopicPrefix checks that prefix is a single MQTT topic level such as "frigate".
+// Several instances share one broker through the +/events and +/+/+/state subscriptions,
+// which only match prefixes of one level.
+func ValidateTopicPrefix(prefix string) error {
+	if prefix == "" || strings.HasPrefix(prefix, "$") || strings.ContainsAny(prefix, "/+#\x00") {
+		return ErrTopicPrefixInvalid
+	}
+	return nil
+}
+
+// CheckTopicPrefixAvailable returns ErrTopicPrefixInUse if an instance other than excludeID
+// uses prefix. All instances share one broker, so a prefix identifies one Frigate server.
+func CheckTopicPrefixAvailable(db *gorm.DB, prefix string, excludeID uint) error {
+	var count int64
+	err := db.Model(&models.FrigateConnect{}).
+		Where("mqtt_topic_prefix = ? AND id <> ?", prefix, excludeID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTopicPrefixInUse
+	}
+	return nil
+}
+
+// SharedTopicPrefixes returns the prefixes that instances of more than one household use.
+// Such prefixes predate the uniqueness check; their messages cannot be attributed to a
+// household, so they are neither shown nor notified nor published to.
+func SharedTopicPrefixes(db *gorm.DB) (map[string]bool, error) {
+	var prefixes []string
+	err := db.Model(&models.FrigateConnect{}).
+		Where("household_id IS NOT NULL").
+		Group("mqtt_topic_prefix").
+		Having("COUNT(DISTINCT household_id) > 1").
+		Pluck("mqtt_topic_prefix", &prefixes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	shared := make(map[string]bool, len(prefixes))
+	for _, prefix := range prefixes {
+		shared[prefix] = true
+	}
+	return shared, nil
+}
+
+// TopicPrefixRoutable reports whether MQTT messages under prefix belong to a single
+// household. An empty prefix, left when a Frigate login found the default taken, is not.
+func TopicPrefixRoutable(db *gorm.DB, prefix string) (bool, error) {
+	if prefix == "" {
+		return false, nil
+	}
+	var count int64
+	err := db.Model(&models.FrigateConnect{}).
+		Where("mqtt_topic_prefix = ? AND household_id IS NOT NULL", prefix).
+		Distinct("household_id").
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count <= 1, nil
+}
+
+// CheckPublishPrefix returns ErrTopicPrefixNotRoutable unless the prefix of frigateConnect
+// belongs to its household alone. Commands under a shared prefix would also reach the
+// Frigate servers of other households.
+func CheckPublishPrefix(db *gorm.DB, frigateConnect *models.FrigateConnect) error {
+	routable, err := TopicPrefixRoutable(db, frigateConnect.MQTTTopicPrefix)
+	if err != nil {
+		return err
+	}
+	if !routable {
+		return ErrTopicPrefixNotRoutable
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sotsukenn/go/models"
+)
+
+func TestValidateTopicPrefix(t *testing.T) {
+	for _, prefix := range []string{"frigate", "frigate-2", "site_garage"} {
+		if err := ValidateTopicPrefix(prefix); err != nil {
+			t.Errorf("ValidateTopicPrefix(%q) = %v, want nil", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"", "+", "#", "frigate/#", "a/+/b", "/frigate", "frigate/", "a//b", "site/garage", "$SYS"} {
+		if err := ValidateTopicPrefix(prefix); !errors.Is(err, ErrTopicPrefixInvalid) {
+			t.Errorf("ValidateTopicPrefix(%q) = %v, want ErrTopicPrefixInvalid", prefix, err)
+		}
+	}
+}
+
+// topicMatches reports whether an MQTT topic filter with + wildcards matches topic
+func topicMatches(filter, topic string) bool {
+	filterLevels, topicLevels := strings.Split(filter, "/"), strings.Split(topic, "/")
+	if len(filterLevels) != len(topicLevels) {
+		return false
+	}
+	for i, level := range filterLevels {
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTopicPrefixRouting(t *testing.T) {
+	const eventsTopic = "+/events"
+	tracker := NewCameraSwitchTracker()
+
+	for _, prefix := range []string{"frigate", "frigate-2"} {
+		if err := ValidateTopicPrefix(prefix); err != nil {
+			t.Fatal(err)
+		}
+		if !topicMatches(eventsTopic, prefix+"/events") {
+			t.Errorf("events of %q are not received with %s", prefix, eventsTopic)
+		}
+		stateTopic := prefix + "/front/detect/state"
+		if !topicMatches(switchStateTopic(eventsTopic), stateTopic) {
+			t.Errorf("switch states of %q are not received with %s", prefix, switchStateTopic(eventsTopic))
+		}
+		tracker.HandleState(stateTopic, "ON", time.Now())
+		if state := tracker.States(prefix, "front")[CameraSwitchDetect]; state == nil || !state.Enabled {
+			t.Errorf("detect state of %q = %+v, want on", prefix, state)
+		}
+	}
+
+	// A multi-level prefix would never be received, which is why it is rejected
+	if topicMatches(eventsTopic, "site/garage/events") || topicMatches(switchStateTopic(eventsTopic), "site/garage/front/detect/state") {
+		t.Fatal("filters unexpectedly match a multi-level prefix")
+	}
+	if err := ValidateTopicPrefix("site/garage"); !errors.Is(err, ErrTopicPrefixInvalid) {
+		t.Fatalf("ValidateTopicPrefix(site/garage) = %v, want ErrTopicPrefixInvalid", err)
+	}
+}
+
+func TestTopicPrefixOwnership(t *testing.T) {
+	db := newTestDB(t, &models.FrigateConnect{})
+	home, cabin := uint(1), uint(2)
+	db.Create(&models.FrigateConnect{UserID: 1, HouseholdID: &home, Name: "a", FrigateURL: "http://a", MQTTTopicPrefix: "frigate"})
+	db.Create(&models.FrigateConnect{UserID: 1, HouseholdID: &home, Name: "b", FrigateURL: "http://b", MQTTTopicPrefix: "shop"})
+
+	if err := CheckTopicPrefixAvailable(db, "frigate", 0); !errors.Is(err, ErrTopicPrefixInUse) {
+		t.Fatalf("CheckTopicPrefixAvailable(frigate) = %v, want ErrTopicPrefixInUse", err)
+	}
+	if err := CheckTopicPrefixAvailable(db, "frigate", 1); err != nil {
+		t.Fatalf("an instance keeping its own prefix = %v, want nil", err)
+	}
+	if ok, _ := TopicPrefixRoutable(db, "frigate"); !ok {
+		t.Fatal("a prefix of one household should be routable")
+	}
+	if ok, _ := TopicPrefixRoutable(db, ""); ok {
+		t.Fatal("an empty prefix should not be routable")
+	}
+
+	// Rows from before the uniqueness check may share a prefix across households
+	db.Create(&models.FrigateConnect{UserID: 2, HouseholdID: &cabin, Name: "a", FrigateURL: "http://c", MQTTTopicPrefix: "frigate"})
+	shared, err := SharedTopicPrefixes(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shared["frigate"] || shared["shop"] || len(shared) != 1 {
+		t.Fatalf("SharedTopicPrefixes = %v, want only frigate", shared)
+	}
+	if ok, _ := TopicPrefixRoutable(db, "frigate"); ok {
+		t.Fatal("a prefix of two households should not be routable")
+	}
+
+	// Commands are only published under a prefix that one household owns
+	if err := CheckPublishPrefix(db, &models.FrigateConnect{HouseholdID: &home, MQTTTopicPrefix: "frigate"}); !errors.Is(err, ErrTopicPrefixNotRoutable) {
+		t.Fatalf("CheckPublishPrefix(frigate) = %v, want ErrTopicPrefixNotRoutable", err)
+	}
+	if err := CheckPublishPrefix(db, &models.FrigateConnect{HouseholdID: &home, MQTTTopicPrefix: ""}); !errors.Is(err, ErrTopicPrefixNotRoutable) {
+		t.Fatalf("CheckPublishPrefix of an empty prefix = %v, want ErrTopicPrefixNotRoutable", err)
+	}
+	if err := CheckPublishPrefix(db, &models.FrigateConnect{HouseholdID: &home, MQTTTopicPrefix: "shop"}); err != nil {
+		t.Fatalf("CheckPublishPrefix(shop) = %v, want nil", err)
+	}
+}
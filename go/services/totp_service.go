@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"sotsukenn/go/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	totpPeriod            = 30 // Seconds per time step
+	totpDigits            = 6
+	totpSkew              = 1 // Accepted clock drift in time steps on either side
+	recoveryCodeCount     = 10
+	recoveryCodeAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeHalfWidth = 5
+)
+
+// TOTPService implements RFC 6238 time-based one-time passwords and recovery codes
+type TOTPService struct {
+	db     *gorm.DB
+	issuer string
+}
+
+// NewTOTPService creates a TOTP service; the issuer shown in authenticator apps comes from TOTP_ISSUER
+func NewTOTPService(db *gorm.DB) *TOTPService {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Sotsukenn"
+	}
+	return &TOTPService{db: db, issuer: issuer}
+}
+
+// GenerateSecret returns a new random 160-bit secret encoded as base32
+func (ts *TOTPService) GenerateSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI that authenticator apps read from a QR code
+func (ts *TOTPService) ProvisioningURI(account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", ts.issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(ts.issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// VerifyCode checks a TOTP code against the user's secret.
+// Each time step is accepted only once so an observed code cannot be replayed.
+func (ts *TOTPService) VerifyCode(user *models.User, code string) bool {
+	step, ok := matchStep(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return false
+	}
+
+	result := ts.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused recovery code
+func (ts *TOTPService) VerifySecondFactor(user *models.User, code string) bool {
+	if ts.VerifyCode(user, code) {
+		return true
+	}
+	return ts.UseRecoveryCode(user.ID, code)
+}
+
+// GenerateRecoveryCodes replaces the user's recovery codes and returns the new raw codes
+func (ts *TOTPService) GenerateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: models.HashToken(normalizeRecoveryCode(code))}
+	}
+
+	err := ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode consumes a recovery code, returning false if it is unknown or already used
+func (ts *TOTPService) UseRecoveryCode(userID uint, code string) bool {
+	code = normalizeRecoveryCode(code)
+	if len(code) != 2*recoveryCodeHalfWidth {
+		return false
+	}
+
+	result := ts.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, models.HashToken(code)).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// RemainingRecoveryCodes returns the number of unused recovery codes of the user
+func (ts *TOTPService) RemainingRecoveryCodes(userID uint) int64 {
+	var count int64
+	ts.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// Disable turns off two-factor authentication and removes the secret and recovery codes
+func (ts *TOTPService) Disable(userID uint) error {
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// matchStep returns the time step within the allowed skew that produces code
+func matchStep(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits || secret == "" {
+		return 0, false
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(hotp(key, uint64(step))), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// hotp computes an RFC 4226 one-time password for counter
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// randomRecoveryCode returns a code such as "k7m2p-x9qrt"
+func randomRecoveryCode() (string, error) {
+	buf := make([]byte, 2*recoveryCodeHalfWidth)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	var sb strings.Builder
+	for i, b := range buf {
+		if i == recoveryCodeHalfWidth {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+	}
+	return sb.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
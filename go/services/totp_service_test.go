@@ -0,0 +1,75 @@
+package services
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"sotsukenn/go/models"
+)
+
+// rfc6238Key is the SHA-1 seed of the RFC 6238 test vectors
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfc6238Key)
+
+	// The RFC lists 8 digits; 6-digit codes are their last six
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		if got := hotp(rfc6238Key, uint64(tc.unix/totpPeriod)); got != tc.code {
+			t.Errorf("hotp at %d = %s, want %s", tc.unix, got, tc.code)
+		}
+		step, ok := matchStep(secret, tc.code, time.Unix(tc.unix, 0))
+		if !ok || step != tc.unix/totpPeriod {
+			t.Errorf("matchStep at %d = %d, %v, want step %d", tc.unix, step, ok, tc.unix/totpPeriod)
+		}
+	}
+
+	// One step of clock drift is accepted, two are not
+	if _, ok := matchStep(secret, "287082", time.Unix(59+totpPeriod, 0)); !ok {
+		t.Error("a code from the previous step should be accepted")
+	}
+	if _, ok := matchStep(secret, "287082", time.Unix(59+2*totpPeriod, 0)); ok {
+		t.Error("a code from two steps ago should be rejected")
+	}
+}
+
+func TestTOTPRejectsReplayedCode(t *testing.T) {
+	db := newTestDB(t, &models.User{})
+	ts := &TOTPService{db: db, issuer: "test"}
+	user := models.User{
+		Username:    "alice",
+		Password:    "hash",
+		TOTPSecret:  base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfc6238Key),
+		TOTPEnabled: true,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	code := hotp(rfc6238Key, uint64(time.Now().Unix()/totpPeriod))
+	if !ts.VerifyCode(&user, code) {
+		t.Fatal("a current code should be accepted")
+	}
+	if ts.VerifyCode(&user, code) {
+		t.Fatal("the same code should not be accepted twice")
+	}
+
+	// A second login with a stale copy of the user is stopped by the stored last step
+	var stale models.User
+	db.First(&stale, user.ID)
+	stale.TOTPLastStep = 0
+	if ts.VerifyCode(&stale, code) {
+		t.Fatal("a replay through a stale user record should be rejected")
+	}
+}
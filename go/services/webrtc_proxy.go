@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"sotsukenn/go/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrWebRTCSessionNotFound is returned for unknown, ended or foreign signaling sessions
+var ErrWebRTCSessionNotFound = errors.New("webrtc session not found")
+
+const (
+	// webrtcAnswerTimeout bounds the wait for go2rtc's SDP answer
+	webrtcAnswerTimeout = 10 * time.Second
+	// webrtcAccessCheckInterval is how often a session's access is re-checked
+	webrtcAccessCheckInterval = 30 * time.Second
+	// webrtcMaxPendingCandidates bounds go2rtc candidates waiting for the client to collect them
+	webrtcMaxPendingCandidates = 64
+)
+
+// DialWebSocketFunc opens the go2rtc WebSocket API of a stream
+type DialWebSocketFunc func(ctx context.Context) (*websocket.Conn, error)
+
+// go2rtcMessage is a message of go2rtc's WebSocket API
+type go2rtcMessage struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// WebRTCSession relays the signaling of one WebRTC viewer to go2rtc. Media flows directly
+// between the viewer and go2rtc; the go2rtc WebSocket stays open for the lifetime of the
+// viewer and closing it ends the peer connection.
+type WebRTCSession struct {
+	ID    string
+	Owner string // User or guest grant that started the session
+	Key   string // Instance and camera the session belongs to
+
+	conn       *websocket.Conn
+	writeMu    sync.Mutex
+	mu         sync.Mutex
+	candidates []string // go2rtc ICE candidates not yet delivered to the viewer
+	done       chan struct{}
+	closeOnce  sync.Once
+	release    func()
+}
+
+// WebRTCSessions tracks running WebRTC signaling sessions
+type WebRTCSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*WebRTCSession
+	limiter  *StreamLimiter
+}
+
+var (
+	webrtcSessions     *WebRTCSessions
+	webrtcSessionsOnce sync.Once
+)
+
+// WebRTCSignaling returns the process-wide session registry. WebRTC viewers count
+// towards GO2RTC_MAX_STREAMS together with MP4 streams.
+func WebRTCSignaling() *WebRTCSessions {
+	webrtcSessionsOnce.Do(func() {
+		webrtcSessions = NewWebRTCSessions(Go2RTCStreams())
+	})
+	return webrtcSessions
+}
+
+// NewWebRTCSessions creates a session registry whose sessions take slots of limiter
+func NewWebRTCSessions(limiter *StreamLimiter) *WebRTCSessions {
+	return &WebRTCSessions{
+		sessions: make(map[string]*WebRTCSession),
+		limiter:  limiter,
+	}
+}
+
+// Start relays an SDP offer to go2rtc and returns the session with go2rtc's SDP answer.
+// stillAllowed is called periodically; the session ends once it returns false.
+func (s *WebRTCSessions) Start(ctx context.Context, owner, key, offer string, dial DialWebSocketFunc, stillAllowed func() bool) (*WebRTCSession, string, error) {
+	release, err := s.limiter.Acquire()
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := utils.GenerateSecureToken(18)
+	if err != nil {
+		release()
+		return nil, "", err
+	}
+
+	conn, err := dial(ctx)
+	if err != nil {
+		release()
+		return nil, "", err
+	}
+
+	session := &WebRTCSession{
+		ID:      id,
+		Owner:   owner,
+		Key:     key,
+		conn:    conn,
+		done:    make(chan struct{}),
+		release: release,
+	}
+
+	if err := session.send("webrtc/offer", offer); err != nil {
+		session.Close()
+		return nil, "", fmt.Errorf("failed to send offer: %w", err)
+	}
+	answer, err := session.awaitAnswer()
+	if err != nil {
+		session.Close()
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+
+	go s.readLoop(session)
+	go s.watchAccess(session, stillAllowed)
+	log.Printf("[WebRTC] Started session for %s (%s)", key, owner)
+	return session, answer, nil
+}
+
+// Get returns the running session id of owner for key
+func (s *WebRTCSessions) Get(id, owner, key string) (*WebRTCSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, exists := s.sessions[id]
+	if !exists || session.Owner != owner || session.Key != key {
+		return nil, ErrWebRTCSessionNotFound
+	}
+	return session, nil
+}
+
+// End closes the session id of owner for key
+func (s *WebRTCSessions) End(id, owner, key string) error {
+	session, err := s.Get(id, owner, key)
+	if err != nil {
+		return err
+	}
+	s.remove(session)
+	return nil
+}
+
+func (s *WebRTCSessions) remove(session *WebRTCSession) {
+	s.mu.Lock()
+	if s.sessions[session.ID] == session {
+		delete(s.sessions, session.ID)
+	}
+	s.mu.Unlock()
+	session.Close()
+}
+
+// readLoop collects go2rtc's trickled ICE candidates until the WebSocket closes
+func (s *WebRTCSessions) readLoop(session *WebRTCSession) {
+	defer s.remove(session)
+	session.conn.SetReadDeadline(time.Time{})
+	for {
+		var msg go2rtcMessage
+		if err := session.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case "webrtc/candidate":
+			session.mu.Lock()
+			if len(session.candidates) < webrtcMaxPendingCandidates {
+				session.candidates = append(session.candidates, msg.Value)
+			}
+			session.mu.Unlock()
+		case "error":
+			log.Printf("[WebRTC] go2rtc error for %s: %s", session.Key, msg.Value)
+			return
+		}
+	}
+}
+
+// watchAccess ends the session once its viewer lost access
+func (s *WebRTCSessions) watchAccess(session *WebRTCSession, stillAllowed func() bool) {
+	ticker := time.NewTicker(webrtcAccessCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !stillAllowed() {
+				log.Printf("[WebRTC] Ended session for %s (%s), access revoked", session.Key, session.Owner)
+				s.remove(session)
+				return
+			}
+		case <-session.done:
+			return
+		}
+	}
+}
+
+// awaitAnswer reads go2rtc messages until the SDP answer arrives. Candidates sent
+// before the answer are kept for the viewer.
+func (ws *WebRTCSession) awaitAnswer() (string, error) {
+	ws.conn.SetReadDeadline(time.Now().Add(webrtcAnswerTimeout))
+	for {
+		var msg go2rtcMessage
+		if err := ws.conn.ReadJSON(&msg); err != nil {
+			return "", fmt.Errorf("failed to read answer: %w", err)
+		}
+		switch msg.Type {
+		case "webrtc/answer":
+			return msg.Value, nil
+		case "webrtc/candidate":
+			ws.candidates = append(ws.candidates, msg.Value)
+		case "error":
+			return "", fmt.Errorf("go2rtc refused the offer: %s", msg.Value)
+		}
+	}
+}
+
+// AddCandidates forwards the viewer's ICE candidates from an SDP fragment
+// (application/trickle-ice-sdpfrag) and returns the candidates go2rtc trickled since
+// the last call in the same format.
+func (ws *WebRTCSession) AddCandidates(sdpFrag string) (string, error) {
+	for _, line := range strings.Split(sdpFrag, "\n") {
+		line = strings.TrimSpace(line)
+		if candidate, ok := strings.CutPrefix(line, "a="); ok && strings.HasPrefix(candidate, "candidate:") {
+			if err := ws.send("webrtc/candidate", candidate); err != nil {
+				return "", fmt.Errorf("failed to send candidate: %w", err)
+			}
+		}
+	}
+	return ws.PendingCandidates(), nil
+}
+
+// PendingCandidates returns and forgets go2rtc's undelivered candidates as an SDP fragment
+func (ws *WebRTCSession) PendingCandidates() string {
+	ws.mu.Lock()
+	candidates := ws.candidates
+	ws.candidates = nil
+	ws.mu.Unlock()
+
+	var frag strings.Builder
+	for _, candidate := range candidates {
+		frag.WriteString("a=" + candidate + "\r\n")
+	}
+	return frag.String()
+}
+
+// Close ends the session and its peer connection in go2rtc
+func (ws *WebRTCSession) Close() {
+	ws.closeOnce.Do(func() {
+		close(ws.done)
+		ws.conn.Close()
+		ws.release()
+	})
+}
+
+func (ws *WebRTCSession) send(msgType, value string) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	ws.conn.SetWriteDeadline(time.Now().Add(webrtcAnswerTimeout))
+	return ws.conn.WriteJSON(go2rtcMessage{Type: msgType, Value: value})
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeGo2RTC answers offers like go2rtc's WebSocket API and records received candidates
+func fakeGo2RTC(t *testing.T, received chan<- string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg go2rtcMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case "webrtc/offer":
+				conn.WriteJSON(go2rtcMessage{Type: "webrtc/candidate", Value: "candidate:1 1 udp 1 10.0.0.2 8555 typ host"})
+				conn.WriteJSON(go2rtcMessage{Type: "webrtc/answer", Value: "v=0 answer"})
+				conn.WriteJSON(go2rtcMessage{Type: "webrtc/candidate", Value: "candidate:2 1 tcp 1 10.0.0.2 8555 typ host"})
+			case "webrtc/candidate":
+				received <- msg.Value
+			}
+		}
+	}))
+}
+
+func dialTestServer(server *httptest.Server) DialWebSocketFunc {
+	return func(ctx context.Context) (*websocket.Conn, error) {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		return conn, err
+	}
+}
+
+func TestWebRTCSessionSignaling(t *testing.T) {
+	received := make(chan string, 1)
+	server := fakeGo2RTC(t, received)
+	defer server.Close()
+
+	sessions := NewWebRTCSessions(NewStreamLimiter(1))
+	allowed := func() bool { return true }
+
+	session, answer, err := sessions.Start(context.Background(), "user:1", "1/front", "v=0 offer", dialTestServer(server), allowed)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if answer != "v=0 answer" {
+		t.Errorf("answer = %q", answer)
+	}
+
+	// The only slot is taken until the session ends
+	if _, _, err := sessions.Start(context.Background(), "user:1", "1/front", "v=0 offer", dialTestServer(server), allowed); err != ErrTooManyStreams {
+		t.Errorf("second Start() error = %v, want ErrTooManyStreams", err)
+	}
+
+	if _, err := sessions.Get(session.ID, "user:2", "1/front"); err != ErrWebRTCSessionNotFound {
+		t.Errorf("Get() by another owner error = %v, want ErrWebRTCSessionNotFound", err)
+	}
+	if _, err := sessions.Get(session.ID, "user:1", "1/back"); err != ErrWebRTCSessionNotFound {
+		t.Errorf("Get() for another camera error = %v, want ErrWebRTCSessionNotFound", err)
+	}
+
+	frag := "a=ice-ufrag:abcd\r\na=candidate:3 1 udp 1 192.168.1.5 50000 typ host\r\na=end-of-candidates\r\n"
+	deadline := time.Now().Add(2 * time.Second)
+	var pending string
+	for {
+		got, err := session.AddCandidates(frag)
+		if err != nil {
+			t.Fatalf("AddCandidates() error = %v", err)
+		}
+		pending += got
+		frag = ""
+		if strings.Contains(pending, "candidate:2") || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	want := "a=candidate:1 1 udp 1 10.0.0.2 8555 typ host\r\na=candidate:2 1 tcp 1 10.0.0.2 8555 typ host\r\n"
+	if pending != want {
+		t.Errorf("pending candidates = %q, want %q", pending, want)
+	}
+
+	select {
+	case candidate := <-received:
+		if candidate != "candidate:3 1 udp 1 192.168.1.5 50000 typ host" {
+			t.Errorf("go2rtc received %q", candidate)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("go2rtc received no candidate")
+	}
+
+	if err := sessions.End(session.ID, "user:1", "1/front"); err != nil {
+		t.Fatalf("End() error = %v", err)
+	}
+	if _, err := sessions.Get(session.ID, "user:1", "1/front"); err != ErrWebRTCSessionNotFound {
+		t.Errorf("Get() after End error = %v, want ErrWebRTCSessionNotFound", err)
+	}
+	next, _, err := sessions.Start(context.Background(), "user:1", "1/front", "v=0 offer", dialTestServer(server), allowed)
+	if err != nil {
+		t.Fatalf("Start() after End error = %v", err)
+	}
+	next.Close()
+}
@@ -0,0 +1,39 @@
+package types
+
+// CameraDetail combines a camera's Frigate config with the live state of its go2rtc streams
+type CameraDetail struct {
+	Name       string               `json:"name"`
+	Instance   string               `json:"instance"`
+	Enabled    *bool                `json:"enabled"` // Unknown when the config could not be loaded
+	Online     bool                 `json:"online"`  // At least one stream has a producer
+	Detect     *CameraDetectDetail  `json:"detect,omitempty"`
+	Resolution *CameraResolution    `json:"resolution,omitempty"`
+	Codecs     []string             `json:"codecs"`
+	BitrateBps *int64               `json:"bitrate_bps"` // Unknown until two samples were taken
+	Viewers    int                  `json:"viewers"`     // go2rtc consumers across all streams
+	Streams    []CameraStreamDetail `json:"streams"`
+}
+
+// CameraDetectDetail describes the detection config of a camera
+type CameraDetectDetail struct {
+	Enabled         bool  `json:"enabled"`
+	FPS             int   `json:"fps,omitempty"`
+	ObjectDetection *bool `json:"object_detection,omitempty"`
+	MaxLabels       int   `json:"max_labels,omitempty"`
+}
+
+// CameraResolution is the detect resolution of a camera
+type CameraResolution struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// CameraStreamDetail is one go2rtc stream of a camera, e.g. the main and the sub stream
+type CameraStreamDetail struct {
+	Name        string   `json:"name"`
+	Online      bool     `json:"online"`
+	Codecs      []string `json:"codecs"`
+	BitrateBps  *int64   `json:"bitrate_bps"`
+	Viewers     int      `json:"viewers"`
+	RemoteAddrs []string `json:"remote_addrs,omitempty"`
+}
@@ -109,3 +109,48 @@ type Go2RTCStream struct {
 
 // Go2RTCStreamsResponse represents the response from /api/go2rtc/streams
 type Go2RTCStreamsResponse map[string]Go2RTCStream
+
+// FrigateConfig is the part of Frigate's /api/config used by the server
+type FrigateConfig struct {
+	Cameras map[string]FrigateCameraConfig `json:"cameras"`
+}
+
+// FrigateCameraConfig is the configuration of one camera in /api/config
+type FrigateCameraConfig struct {
+	Enabled   bool                `json:"enabled"`
+	Detect    FrigateDetectConfig `json:"detect"`
+	Record    FrigateToggle       `json:"record"`
+	Snapshots FrigateToggle       `json:"snapshots"`
+	Live      FrigateLiveConfig   `json:"live"`
+	FFmpeg    FrigateFFmpeg       `json:"ffmpeg"`
+}
+
+// FrigateDetectConfig contains the detect settings of a camera
+type FrigateDetectConfig struct {
+	Enabled bool `json:"enabled"`
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+	FPS     int  `json:"fps"`
+}
+
+// FrigateToggle is a config section that can be switched on and off
+type FrigateToggle struct {
+	Enabled bool `json:"enabled"`
+}
+
+// FrigateLiveConfig maps the live view stream labels of a camera to go2rtc stream names
+type FrigateLiveConfig struct {
+	Streams map[string]string `json:"streams"`
+}
+
+// FrigateFFmpeg contains the ffmpeg inputs of a camera, e.g. go2rtc restreams
+type FrigateFFmpeg struct {
+	Inputs []FFmpegInput `json:"inputs"`
+}
+
+// PTZInfo represents the response from /api/<camera>/ptz/info
+type PTZInfo struct {
+	Name     string   `json:"name"`
+	Features []string `json:"features"` // e.g. pt, zoom, pt-r, zoom-r, zoom-a
+	Presets  []string `json:"presets"`
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"os"
+	"time"
 
 	"sotsukenn/go/models"
 
@@ -41,8 +44,8 @@ func RespondWithError(ctx *gin.Context, statusCode int, message string, details
 	ctx.JSON(statusCode, JsonResponse("err", statusCode, "", message, details))
 }
 
-func GetTokenStoreFromContext(ctx *gin.Context) *models.TokenStore {
-	return ctx.MustGet("token_store").(*models.TokenStore)
+func GetTokenStoreFromContext(ctx *gin.Context) models.TokenStore {
+	return ctx.MustGet("token_store").(models.TokenStore)
 }
 
 func GenerateJWT(jwtClaims *jwt.MapClaims) (string, error) {
@@ -75,3 +78,23 @@ func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
 	}
 	return nil, fmt.Errorf("invalid token or claims")
 }
+
+// GenerateSecureToken returns a URL-safe random token built from n random bytes
+func GenerateSecureToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// GetEnvDuration reads a duration such as "15m" or "720h" from the environment,
+// falling back to defaultValue when the variable is unset or invalid
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
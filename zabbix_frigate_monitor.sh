@@ -5,19 +5,31 @@
 
 # 配置
 API_URL="${API_URL:-http://localhost:8080}"
+API_KEY="${API_KEY:-}"
 JWT_TOKEN="${JWT_TOKEN:-}"
+# 关联了多个Frigate实例时指定要监控的实例（ID或名称），默认第一个启用的实例
+FRIGATE_INSTANCE="${FRIGATE_INSTANCE:-}"
 
-# 检查JWT Token
-if [ -z "$JWT_TOKEN" ]; then
-    echo "Error: JWT_TOKEN environment variable is required"
-    echo "Usage: JWT_TOKEN=your_token $0 [all|status|cameras|events|person]"
+# 认证方式：优先使用长期有效的API Key（需要 zabbix:read 权限），其次使用JWT Token
+if [ -n "$API_KEY" ]; then
+    AUTH_HEADER="X-API-Key: $API_KEY"
+elif [ -n "$JWT_TOKEN" ]; then
+    AUTH_HEADER="Authorization: Bearer $JWT_TOKEN"
+else
+    echo "Error: API_KEY or JWT_TOKEN environment variable is required"
+    echo "Usage: API_KEY=your_api_key $0 [all|status|cameras|events|person]"
     exit 1
 fi
 
+INSTANCE_QUERY=""
+if [ -n "$FRIGATE_INSTANCE" ]; then
+    INSTANCE_QUERY="?instance=$FRIGATE_INSTANCE"
+fi
+
 # 获取所有监控指标
 get_all_stats() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
-        "$API_URL/api/zabbix/all" | jq '
+    curl -s -H "$AUTH_HEADER" \
+        "$API_URL/api/zabbix/all$INSTANCE_QUERY" | jq '
 {
   "last_event_time": .body.last_event_time,
   "frigate_online": .body.frigate_status.is_online,
@@ -31,43 +43,43 @@ get_all_stats() {
 
 # 获取Frigate状态
 get_frigate_status() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
-        "$API_URL/api/zabbix/status" | jq -r '.body.is_online'
+    curl -s -H "$AUTH_HEADER" \
+        "$API_URL/api/zabbix/status$INSTANCE_QUERY" | jq -r '.body.is_online'
 }
 
 # 获取响应时间
 get_response_time() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
-        "$API_URL/api/zabbix/status" | jq -r '.body.response_time_ms'
+    curl -s -H "$AUTH_HEADER" \
+        "$API_URL/api/zabbix/status$INSTANCE_QUERY" | jq -r '.body.response_time_ms'
 }
 
 # 获取在线摄像头数量
 get_cameras_online() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
-        "$API_URL/api/zabbix/cameras" | jq -r '.body.online_count'
+    curl -s -H "$AUTH_HEADER" \
+        "$API_URL/api/zabbix/cameras$INSTANCE_QUERY" | jq -r '.body.online_count'
 }
 
 # 获取离线摄像头数量
 get_cameras_offline() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
-        "$API_URL/api/zabbix/cameras" | jq -r '.body.offline_count'
+    curl -s -H "$AUTH_HEADER" \
+        "$API_URL/api/zabbix/cameras$INSTANCE_QUERY" | jq -r '.body.offline_count'
 }
 
 # 获取最后事件时间
 get_last_event_time() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
+    curl -s -H "$AUTH_HEADER" \
         "$API_URL/api/zabbix/events/last" | jq -r '.body.last_event_time'
 }
 
 # 获取人类检测总数
 get_person_count() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
+    curl -s -H "$AUTH_HEADER" \
         "$API_URL/api/zabbix/stats/person" | jq -r '.body.total_detections'
 }
 
 # 获取识别到的人员数量
 get_recognized_people_count() {
-    curl -s -H "Authorization: Bearer $JWT_TOKEN" \
+    curl -s -H "$AUTH_HEADER" \
         "$API_URL/api/zabbix/stats/person" | jq -r '.body.unique_count'
 }
 